@@ -110,6 +110,70 @@ func routeAdminGetFeedbackScreenshot(w http.ResponseWriter, r *http.Request, p h
 	}
 }
 
+func routeAdminGetAuditLog(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	filter := data.AuditLogFilter{
+		UserID:    -1,
+		Event:     data.AuditEvent(r.FormValue("event")),
+		IPAddress: r.FormValue("ipAddress"),
+		Start:     -1,
+		End:       -1,
+	}
+
+	if r.FormValue("userId") != "" {
+		userID, err := strconv.Atoi(r.FormValue("userId"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+		filter.UserID = userID
+	} else if r.FormValue("email") != "" {
+		exists, userID, err := data.UserExistsWithEmail(r.FormValue("email"))
+		if err != nil {
+			errorlog.LogError("getting audit log", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+
+		if !exists {
+			writeJSON(w, http.StatusNotFound, errorResponse{"error", "not_found"})
+			return
+		}
+		filter.UserID = userID
+	}
+
+	if r.FormValue("start") != "" {
+		start, err := strconv.Atoi(r.FormValue("start"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+		filter.Start = start
+	}
+
+	if r.FormValue("end") != "" {
+		end, err := strconv.Atoi(r.FormValue("end"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+		filter.End = end
+	}
+
+	if !parseAuditLogPage(r, &filter) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	entries, err := data.QueryAuditLog(filter)
+	if err != nil {
+		errorlog.LogError("getting audit log", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, auditLogResponse{"ok", entries})
+}
+
 func routeAdminGetUserCount(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
 	rows, err := DB.Query("SELECT COUNT(*) FROM users")
 	if err != nil {
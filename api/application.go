@@ -1,8 +1,14 @@
 package api
 
 import (
+	"bytes"
 	"encoding/base64"
+	"image/png"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/MyHomeworkSpace/api-server/config"
 	"github.com/MyHomeworkSpace/api-server/data"
@@ -28,51 +34,267 @@ type multipleApplicationsResponse struct {
 	Status       string             `json:"status"`
 	Applications []data.Application `json:"applications"`
 }
+type applicationSecretResponse struct {
+	Status       string `json:"status"`
+	ClientSecret string `json:"clientSecret"`
+}
+type applicationStatsResponse struct {
+	Status string                `json:"status"`
+	Stats  data.ApplicationStats `json:"stats"`
+}
+
+// how long an application's old secret keeps working after it's rotated, if the developer doesn't say
+const applicationSecretDefaultOverlap = 24 * time.Hour
+const applicationSecretMaxOverlap = 7 * 24 * time.Hour
+
+// logos are shown small on the authorization page, so there's no need for huge ones
+const applicationLogoMaxDimension = 1024
+
+// old-style application tokens don't expire and aren't hashed, so they're being phased out in favor of oauth
+// after this date, no application can get a new one, but the ones that already exist keep working
+var applicationLegacyTokensEnd = time.Date(2027, time.July, 1, 0, 0, 0, 0, time.UTC)
+
+/*
+ * helpers
+ */
+
+// deleteApplicationAuthorization deletes the given authorization, along with any oauth tokens that were issued for it.
+func deleteApplicationAuthorization(id int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	// the application's webhooks for this user can't be sent anything anymore
+	rows, err := tx.Query("SELECT applicationId, userId FROM application_authorizations WHERE id = ?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	applicationID := -1
+	userID := -1
+	if rows.Next() {
+		rows.Scan(&applicationID, &userID)
+	}
+	rows.Close()
+
+	if applicationID != -1 {
+		err = data.DeleteWebhooksForApplication(tx, applicationID, userID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM oauth_tokens WHERE authorizationId = ?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM application_authorizations WHERE id = ?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// getManagedApplication gets the application given in the request, and checks that it belongs to the current user.
+// If something goes wrong, it responds to the request and returns false.
+func getManagedApplication(w http.ResponseWriter, r *http.Request, c RouteContext, action string) (data.Application, bool) {
+	if r.FormValue("id") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return data.Application{}, false
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return data.Application{}, false
+	}
+
+	application, err := data.GetApplicationForOwner(id, c.User.ID)
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return data.Application{}, false
+	} else if err != nil {
+		errorlog.LogError(action, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return data.Application{}, false
+	}
+
+	return application, true
+}
+
+// isValidRedirectURI checks a redirect URI that a developer wants to register.
+// Native apps can't get a certificate, so they can use plain http, but only to get the code back on the same device.
+func isValidRedirectURI(uri string) bool {
+	if len(uri) > 2048 {
+		return false
+	}
+
+	parsedURI, err := url.Parse(uri)
+	if err != nil || !parsedURI.IsAbs() || parsedURI.Fragment != "" {
+		return false
+	}
+
+	switch strings.ToLower(parsedURI.Scheme) {
+	case "javascript", "data", "vbscript", "file":
+		return false
+	case "http":
+		host := parsedURI.Hostname()
+		return (host == "localhost" || host == "127.0.0.1" || host == "::1")
+	}
+
+	return true
+}
+
+// isValidApplicationLogo checks that the given logo is a reasonably sized PNG.
+func isValidApplicationLogo(logo []byte) bool {
+	if len(logo) > data.MaxApplicationLogoSize {
+		return false
+	}
+
+	config, err := png.DecodeConfig(bytes.NewReader(logo))
+	if err != nil {
+		return false
+	}
+
+	return (config.Width <= applicationLogoMaxDimension && config.Height <= applicationLogoMaxDimension)
+}
+
+// saveApplicationAuthorization gives the application the given scopes on the user's account, on top of any it already had.
+// It returns the ID of the authorization, and whether the application got anything it didn't have before.
+func saveApplicationAuthorization(applicationID int, userID int, scopes []data.Scope) (int, bool, error) {
+	rows, err := DB.Query("SELECT id, scopes FROM application_authorizations WHERE applicationId = ? AND userId = ?", applicationID, userID)
+	if err != nil {
+		return -1, false, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		id := -1
+		existingText := ""
+		err = rows.Scan(&id, &existingText)
+		if err != nil {
+			return -1, false, err
+		}
+
+		existing, _ := data.ParseScopes(existingText)
+		if data.ScopesContainAll(existing, scopes) {
+			return id, false, nil
+		}
+
+		_, err = DB.Exec("UPDATE application_authorizations SET scopes = ? WHERE id = ?", data.FormatScopes(data.MergeScopes(existing, scopes)), id)
+		return id, true, err
+	}
+
+	// the old-style token is only made if the application asks for one
+	result, err := DB.Exec("INSERT INTO application_authorizations(applicationId, userId, token, scopes) VALUES(?, ?, NULL, ?)", applicationID, userID, data.FormatScopes(scopes))
+	if err != nil {
+		return -1, false, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, false, err
+	}
+
+	return int(id), true, nil
+}
+
+// canCreateLegacyApplicationToken returns whether the given application can still be given new old-style tokens.
+// Applications that have set up a client secret or redirect URIs are using oauth, and should get tokens from there.
+func canCreateLegacyApplicationToken(application data.Application, now time.Time) bool {
+	if application.IsConfidential() || len(application.RedirectURIs) > 0 {
+		return false
+	}
+
+	return now.Before(applicationLegacyTokensEnd)
+}
+
+// getLegacyApplicationToken returns the old-style token that the given user already has for the given application, or an empty string if they don't have one.
+func getLegacyApplicationToken(applicationID int, userID int) (string, error) {
+	rows, err := DB.Query("SELECT IFNULL(token, '') FROM application_authorizations WHERE applicationId = ? AND userId = ?", applicationID, userID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	token := ""
+	if rows.Next() {
+		err = rows.Scan(&token)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return token, nil
+}
+
+/*
+ * routes
+ */
 
 func routeApplicationCompleteAuth(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
 	// get the application
-	applicationRows, err := DB.Query("SELECT id, name, authorName, callbackUrl FROM applications WHERE clientId = ?", r.FormValue("clientId"))
-	if err != nil {
+	application, err := data.GetApplicationByClientID(r.FormValue("clientId"))
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusNotFound, errorResponse{"error", "not_found"})
+		return
+	} else if err != nil {
 		errorlog.LogError("completing application auth", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
-	defer applicationRows.Close()
 
-	if !applicationRows.Next() {
-		writeJSON(w, http.StatusNotFound, errorResponse{"error", "not_found"})
+	// check if we already have a token
+	token, err := getLegacyApplicationToken(application.ID, c.User.ID)
+	if err != nil {
+		errorlog.LogError("completing application auth", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
-	application := data.Application{}
-	applicationRows.Scan(&application.ID, &application.Name, &application.AuthorName, &application.CallbackURL)
+	canCreateToken := canCreateLegacyApplicationToken(application, time.Now())
+	if token == "" && !canCreateToken {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "oauth_required"})
+		return
+	}
 
-	// check if we've already authorized this application
-	tokenCheckRows, err := DB.Query("SELECT token FROM application_authorizations WHERE applicationId = ? AND userId = ?", application.ID, c.User.ID)
+	// the user was shown everything the application asks for
+	authorizationID, changed, err := saveApplicationAuthorization(application.ID, c.User.ID, application.Scopes)
 	if err != nil {
-		errorlog.LogError("completing application auth", err)
+		errorlog.LogError("authorizing application", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
-	defer tokenCheckRows.Close()
 
-	if tokenCheckRows.Next() {
+	if changed {
+		logAuditEvent(r, c.User.ID, data.AuditEventApplicationAuthorize, map[string]interface{}{
+			"applicationId": application.ID,
+			"name":          application.Name,
+			"scopes":        data.FormatScopes(application.Scopes),
+		})
+	}
+
+	if token != "" {
 		// if we have, just return that token
-		token := ""
-		tokenCheckRows.Scan(&token)
 		writeJSON(w, http.StatusOK, applicationTokenResponse{"ok", token})
 		return
 	}
 
-	// add the new authorization
-	token, err := util.GenerateRandomString(56)
+	// otherwise, it's new or was authorized through oauth, and needs an old-style token
+	token, err = util.GenerateRandomString(56)
 	if err != nil {
 		errorlog.LogError("generating application token", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
-	_, err = DB.Exec("INSERT INTO application_authorizations(applicationId, userId, token) VALUES(?, ?, ?)", application.ID, c.User.ID, token)
+	_, err = DB.Exec("UPDATE application_authorizations SET token = ? WHERE id = ?", token, authorizationID)
 	if err != nil {
 		errorlog.LogError("authorizing application", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -83,27 +305,21 @@ func routeApplicationCompleteAuth(w http.ResponseWriter, r *http.Request, p http
 }
 
 func routeApplicationGet(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
-	rows, err := DB.Query("SELECT id, name, authorName, callbackUrl FROM applications WHERE clientId = ?", p.ByName("id"))
-	if err != nil {
+	application, err := data.GetApplicationByClientID(p.ByName("id"))
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusNotFound, errorResponse{"error", "not_found"})
+		return
+	} else if err != nil {
 		errorlog.LogError("getting application information", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
-	defer rows.Close()
 
-	if !rows.Next() {
-		writeJSON(w, http.StatusNotFound, errorResponse{"error", "not_found"})
-		return
-	}
-
-	resp := data.Application{}
-	rows.Scan(&resp.ID, &resp.Name, &resp.AuthorName, &resp.CallbackURL)
-
-	writeJSON(w, http.StatusOK, singleApplicationResponse{"ok", resp})
+	writeJSON(w, http.StatusOK, singleApplicationResponse{"ok", application})
 }
 
 func routeApplicationGetAuthorizations(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
-	rows, err := DB.Query("SELECT application_authorizations.id, applications.id, applications.name, applications.authorName FROM application_authorizations INNER JOIN applications ON application_authorizations.applicationId = applications.id WHERE application_authorizations.userId = ?", c.User.ID)
+	rows, err := DB.Query("SELECT application_authorizations.id, applications.id, applications.name, applications.authorName, application_authorizations.scopes FROM application_authorizations INNER JOIN applications ON application_authorizations.applicationId = applications.id WHERE application_authorizations.userId = ?", c.User.ID)
 	if err != nil {
 		errorlog.LogError("getting authorizations", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -114,7 +330,9 @@ func routeApplicationGetAuthorizations(w http.ResponseWriter, r *http.Request, p
 	authorizations := []data.ApplicationAuthorization{}
 	for rows.Next() {
 		resp := data.ApplicationAuthorization{}
-		rows.Scan(&resp.ID, &resp.ApplicationID, &resp.Name, &resp.AuthorName)
+		scopes := ""
+		rows.Scan(&resp.ID, &resp.ApplicationID, &resp.Name, &resp.AuthorName, &scopes)
+		resp.Scopes, _ = data.ParseScopes(scopes)
 		authorizations = append(authorizations, resp)
 	}
 	writeJSON(w, http.StatusOK, applicationAuthorizationsResponse{"ok", authorizations})
@@ -131,7 +349,7 @@ func routeApplicationRequestAuth(w http.ResponseWriter, r *http.Request, p httpr
 
 func routeApplicationRevokeAuth(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
 	// find the authorization
-	rows, err := DB.Query("SELECT userId FROM application_authorizations WHERE id = ?", r.FormValue("id"))
+	rows, err := DB.Query("SELECT id, userId, applicationId FROM application_authorizations WHERE id = ?", r.FormValue("id"))
 	if err != nil {
 		errorlog.LogError("revoking authorization", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -144,8 +362,10 @@ func routeApplicationRevokeAuth(w http.ResponseWriter, r *http.Request, p httpro
 		return
 	}
 
+	authorizationID := -1
 	userID := -1
-	rows.Scan(&userID)
+	applicationID := -1
+	rows.Scan(&authorizationID, &userID, &applicationID)
 
 	if c.User.ID != userID {
 		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
@@ -153,13 +373,17 @@ func routeApplicationRevokeAuth(w http.ResponseWriter, r *http.Request, p httpro
 	}
 
 	// delete the authorization
-	_, err = DB.Exec("DELETE FROM application_authorizations WHERE id = ?", r.FormValue("id"))
+	err = deleteApplicationAuthorization(authorizationID)
 	if err != nil {
 		errorlog.LogError("revoking authorization", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
+	logAuditEvent(r, c.User.ID, data.AuditEventApplicationRevoke, map[string]interface{}{
+		"applicationId": applicationID,
+	})
+
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
 
@@ -169,14 +393,23 @@ func routeApplicationRevokeSelf(w http.ResponseWriter, r *http.Request, p httpro
 		return
 	}
 
-	// delete the authorization
-	_, err := DB.Exec("DELETE FROM application_authorizations WHERE token = ?", GetAuthToken(r))
+	// the token could be an access token from oauth, or an old-style one
+	token, err := data.GetOAuthToken(GetAuthToken(r))
+	if err == nil {
+		err = deleteApplicationAuthorization(token.AuthorizationID)
+	} else if err == data.ErrNotFound {
+		_, err = DB.Exec("DELETE FROM application_authorizations WHERE token = ?", GetAuthToken(r))
+	}
 	if err != nil {
 		errorlog.LogError("revoking authorization", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
+	logAuditEvent(r, c.User.ID, data.AuditEventApplicationRevoke, map[string]interface{}{
+		"self": true,
+	})
+
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
 
@@ -217,22 +450,30 @@ func routeApplicationManageCreate(w http.ResponseWriter, r *http.Request, p http
 }
 
 func routeApplicationManageGetAll(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
-	rows, err := DB.Query("SELECT id, name, authorName, clientId, callbackUrl FROM applications WHERE userId = ?", c.User.ID)
+	apps, err := data.GetApplicationsForOwner(c.User.ID)
 	if err != nil {
 		errorlog.LogError("getting user applications", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
-	defer rows.Close()
 
-	apps := []data.Application{}
-	for rows.Next() {
-		resp := data.Application{}
-		rows.Scan(&resp.ID, &resp.Name, &resp.AuthorName, &resp.ClientID, &resp.CallbackURL)
-		apps = append(apps, resp)
+	writeJSON(w, http.StatusOK, multipleApplicationsResponse{"ok", apps})
+}
+
+func routeApplicationManageGetStats(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	application, ok := getManagedApplication(w, r, c, "getting application stats")
+	if !ok {
+		return
 	}
 
-	writeJSON(w, http.StatusOK, multipleApplicationsResponse{"ok", apps})
+	stats, err := data.GetApplicationStats(application.ID, time.Now())
+	if err != nil {
+		errorlog.LogError("getting application stats", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, applicationStatsResponse{"ok", stats})
 }
 
 func routeApplicationManageUpdate(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
@@ -241,21 +482,79 @@ func routeApplicationManageUpdate(w http.ResponseWriter, r *http.Request, p http
 		return
 	}
 
+	scopes, err := data.ParseScopes(r.FormValue("scopes"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	description := strings.TrimSpace(r.FormValue("description"))
+	if len(description) > data.MaxApplicationDescriptionLength {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	redirectURIs := []string{}
+	for _, uri := range r.Form["redirectUris"] {
+		uri = strings.TrimSpace(uri)
+		if uri == "" || util.StringSliceContains(redirectURIs, uri) {
+			continue
+		}
+		if !isValidRedirectURI(uri) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_redirect_uri"})
+			return
+		}
+		redirectURIs = append(redirectURIs, uri)
+	}
+	if len(redirectURIs) > data.MaxApplicationRedirectURIs {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "too_many_redirect_uris"})
+		return
+	}
+
 	// check that you can actually edit the application
-	rows, err := DB.Query("SELECT id FROM applications WHERE userId = ? AND id = ?", c.User.ID, r.FormValue("id"))
+	application, ok := getManagedApplication(w, r, c, "updating application")
+	if !ok {
+		return
+	}
+
+	// older versions of the developer page only know about the name and callback url, so anything else is left alone unless given
+	if _, hasScopes := r.Form["scopes"]; hasScopes {
+		application.Scopes = scopes
+	}
+	if _, hasDescription := r.Form["description"]; hasDescription {
+		application.Description = description
+	}
+
+	tx, err := DB.Begin()
 	if err != nil {
 		errorlog.LogError("updating application", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
-	defer rows.Close()
-	if !rows.Next() {
-		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+
+	// update the application
+	_, err = tx.Exec(
+		"UPDATE applications SET name = ?, callbackUrl = ?, scopes = ?, description = ? WHERE id = ?",
+		r.FormValue("name"), r.FormValue("callbackUrl"), data.FormatScopes(application.Scopes), application.Description, application.ID,
+	)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("updating application", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
-	// update the application
-	_, err = DB.Exec("UPDATE applications SET name = ?, callbackUrl = ? WHERE id = ?", r.FormValue("name"), r.FormValue("callbackUrl"), r.FormValue("id"))
+	if _, hasRedirectURIs := r.Form["redirectUris"]; hasRedirectURIs {
+		err = data.SetApplicationRedirectURIs(tx, application.ID, redirectURIs)
+		if err != nil {
+			tx.Rollback()
+			errorlog.LogError("updating application", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		errorlog.LogError("updating application", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -283,12 +582,47 @@ func routeApplicationManageDelete(w http.ResponseWriter, r *http.Request, p http
 		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
 		return
 	}
+	applicationID := -1
+	rows.Scan(&applicationID)
 
 	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("deleting application", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// delete oauth tokens
+	_, err = tx.Exec("DELETE oauth_tokens FROM oauth_tokens INNER JOIN application_authorizations ON oauth_tokens.authorizationId = application_authorizations.id WHERE application_authorizations.applicationId = ?", r.FormValue("id"))
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("deleting application", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// delete redirect uris
+	_, err = tx.Exec("DELETE FROM application_redirect_uris WHERE applicationId = ?", r.FormValue("id"))
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("deleting application", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// delete webhooks
+	err = data.DeleteWebhooksForApplication(tx, applicationID, -1)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("deleting application", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
 
 	// delete authorizations
 	_, err = tx.Exec("DELETE FROM application_authorizations WHERE applicationId = ?", r.FormValue("id"))
 	if err != nil {
+		tx.Rollback()
 		errorlog.LogError("deleting application", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
@@ -297,6 +631,7 @@ func routeApplicationManageDelete(w http.ResponseWriter, r *http.Request, p http
 	// delete applications
 	_, err = tx.Exec("DELETE FROM applications WHERE id = ?", r.FormValue("id"))
 	if err != nil {
+		tx.Rollback()
 		errorlog.LogError("deleting application", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
@@ -312,3 +647,96 @@ func routeApplicationManageDelete(w http.ResponseWriter, r *http.Request, p http
 
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
+
+func routeApplicationManageRotateSecret(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	// the old secret keeps working for a while, so that the application can be updated without any downtime
+	overlap := applicationSecretDefaultOverlap
+	if r.FormValue("overlap") != "" {
+		overlapSeconds, err := strconv.Atoi(r.FormValue("overlap"))
+		if err != nil || overlapSeconds < 0 || time.Duration(overlapSeconds)*time.Second > applicationSecretMaxOverlap {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+		overlap = time.Duration(overlapSeconds) * time.Second
+	}
+
+	application, ok := getManagedApplication(w, r, c, "rotating application secret")
+	if !ok {
+		return
+	}
+
+	// only the hash is kept, so this is the one chance to see the secret
+	secret, err := util.GenerateRandomString(48)
+	if err != nil {
+		errorlog.LogError("rotating application secret", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	var previousHash, previousExpiresAt interface{}
+	if application.IsConfidential() && overlap > 0 {
+		previousHash = application.ClientSecretHash
+		previousExpiresAt = time.Now().Add(overlap).Unix()
+	}
+
+	_, err = DB.Exec(
+		"UPDATE applications SET clientSecretHash = ?, previousClientSecretHash = ?, previousClientSecretExpiresAt = ? WHERE id = ?",
+		util.HashToken(secret), previousHash, previousExpiresAt, application.ID,
+	)
+	if err != nil {
+		errorlog.LogError("rotating application secret", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, applicationSecretResponse{"ok", secret})
+}
+
+func routeApplicationManageSetLogo(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	application, ok := getManagedApplication(w, r, c, "setting application logo")
+	if !ok {
+		return
+	}
+
+	// an empty logo removes it
+	var logo []byte
+	if r.FormValue("logo") != "" {
+		var err error
+		logo, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(r.FormValue("logo"), "data:image/png;base64,"))
+		if err != nil || !isValidApplicationLogo(logo) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_logo"})
+			return
+		}
+	}
+
+	_, err := DB.Exec("UPDATE applications SET logo = ? WHERE id = ?", logo, application.ID)
+	if err != nil {
+		errorlog.LogError("setting application logo", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeApplicationGetLogo(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	logo, err := data.GetApplicationLogo(p.ByName("id"))
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusNotFound, errorResponse{"error", "not_found"})
+		return
+	} else if err != nil {
+		errorlog.LogError("getting application logo", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+	w.Write(logo)
+}
+
+func routeApplicationGetScopes(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	writeJSON(w, http.StatusOK, scopesResponse{"ok", data.AllScopes})
+}
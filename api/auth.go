@@ -13,8 +13,6 @@ import (
 
 	"golang.org/x/crypto/bcrypt"
 
-	"github.com/pquerna/otp/totp"
-
 	"github.com/MyHomeworkSpace/api-server/auth"
 	"github.com/MyHomeworkSpace/api-server/config"
 	"github.com/MyHomeworkSpace/api-server/data"
@@ -110,14 +108,14 @@ func GetSessionInfo(r *http.Request) auth.SessionInfo {
 		// we have an authorization header, use that
 		token := GetAuthToken(r)
 		if token == "" {
-			return auth.SessionInfo{-1}
+			return auth.SessionInfo{UserID: -1}
 		}
 		return auth.GetSessionFromAuthToken(token)
 	}
 
 	cookie, err := r.Cookie("session")
 	if err != nil {
-		return auth.SessionInfo{-1}
+		return auth.SessionInfo{UserID: -1}
 	}
 	return auth.GetSession(cookie.Value)
 }
@@ -147,7 +145,13 @@ func validatePassword(password string) bool {
 	return strings.ContainsAny(strings.ToLower(password), "abcdefghijklmnopqrstuvwxyz") && strings.ContainsAny(password, "0123456789") && len(password) >= 8
 }
 
-func handlePasswordChange(user *data.User) error {
+// handlePasswordChange signs the user out everywhere except for the current request's session, and lets them know about the change.
+func handlePasswordChange(r *http.Request, user *data.User) error {
+	err := auth.RevokeOtherSessions(user.ID, getCurrentSessionName(r))
+	if err != nil {
+		return err
+	}
+
 	return email.Send("", user, "passwordChange", map[string]interface{}{})
 }
 
@@ -207,6 +211,10 @@ func routeAuthChangeEmail(w http.ResponseWriter, r *http.Request, p httprouter.P
 		return
 	}
 
+	logAuditEvent(r, c.User.ID, data.AuditEventEmailChangeRequest, map[string]interface{}{
+		"newEmail": new,
+	})
+
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
 
@@ -270,7 +278,9 @@ func routeAuthChangePassword(w http.ResponseWriter, r *http.Request, p httproute
 		return
 	}
 
-	err = handlePasswordChange(c.User)
+	logAuditEvent(r, c.User.ID, data.AuditEventPasswordChange, nil)
+
+	err = handlePasswordChange(r, c.User)
 	if err != nil {
 		errorlog.LogError("changing password", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -346,7 +356,9 @@ func routeAuthCompleteEmail(w http.ResponseWriter, r *http.Request, p httprouter
 			return
 		}
 
-		err = handlePasswordChange(&user)
+		logAuditEvent(r, token.UserID, data.AuditEventPasswordReset, nil)
+
+		err = handlePasswordChange(r, &user)
 		if err != nil {
 			errorlog.LogError("completing email", err)
 			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -362,6 +374,10 @@ func routeAuthCompleteEmail(w http.ResponseWriter, r *http.Request, p httprouter
 			return
 		}
 
+		logAuditEvent(r, token.UserID, data.AuditEventEmailChange, map[string]interface{}{
+			"newEmail": token.Metadata,
+		})
+
 		writeJSON(w, http.StatusOK, tokenResponse{"ok", token, false})
 	} else if token.Type == data.EmailTokenVerifyEmail {
 		_, err = DB.Exec("UPDATE users SET emailVerified = 1 WHERE id = ?", token.UserID)
@@ -371,6 +387,43 @@ func routeAuthCompleteEmail(w http.ResponseWriter, r *http.Request, p httprouter
 			return
 		}
 
+		writeJSON(w, http.StatusOK, tokenResponse{"ok", token, false})
+	} else if token.Type == data.EmailTokenReset2FA {
+		// remove every second factor, so that they can log in with just their password and set it up again
+		tx, err := DB.Begin()
+		if err != nil {
+			errorlog.LogError("completing email", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+
+		for _, table := range []string{"totp", "totp_recovery_codes", "webauthn_credentials"} {
+			_, err = tx.Exec("DELETE FROM "+table+" WHERE userId = ?", token.UserID)
+			if err != nil {
+				tx.Rollback()
+				errorlog.LogError("completing email", err)
+				writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+				return
+			}
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			errorlog.LogError("completing email", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+
+		// whoever got around the second factor shouldn't stay signed in
+		err = auth.RevokeOtherSessions(token.UserID, getCurrentSessionName(r))
+		if err != nil {
+			errorlog.LogError("completing email", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+
+		logAuditEvent(r, token.UserID, data.AuditEvent2FAReset, nil)
+
 		writeJSON(w, http.StatusOK, tokenResponse{"ok", token, false})
 	} else {
 		writeJSON(w, http.StatusNotFound, errorResponse{"error", "not_found"})
@@ -541,11 +594,10 @@ func routeAuthCreateAccount(w http.ResponseWriter, r *http.Request, p httprouter
 	}
 
 	// sign them in
-	session := auth.SessionInfo{
-		UserID: int(userID),
-	}
 	cookie, _ := r.Cookie("session")
-	auth.SetSession(cookie.Value, session)
+	auth.StartSession(cookie.Value, int(userID), getRequestRemoteAddr(r), r.UserAgent())
+
+	logAuditEvent(r, int(userID), data.AuditEventAccountCreate, nil)
 
 	// send a verification email
 	user, err := data.GetUserByID(int(userID))
@@ -571,7 +623,8 @@ func routeAuthCsrf(w http.ResponseWriter, r *http.Request, p httprouter.Params,
 }
 
 func routeAuthLogin(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
-	if r.FormValue("email") == "" || r.FormValue("password") == "" {
+	// a password isn't needed if they're logging in with just a security key
+	if r.FormValue("email") == "" || (r.FormValue("password") == "" && r.FormValue("webauthnResponse") == "") {
 		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
 		return
 	}
@@ -604,67 +657,65 @@ func routeAuthLogin(w http.ResponseWriter, r *http.Request, p httprouter.Params,
 			return
 		}
 
-		// first we check for the easy path: they have a hash stored with us
-		if user.PasswordHash != "" {
-			// they do
-			err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
-			if err == bcrypt.ErrMismatchedHashAndPassword {
-				// bye
-				writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "password_incorrect"})
-				return
-			} else if err != nil {
-				errorlog.LogError("user login", err)
+		method := ""
+		if password == "" {
+			// passwordless login, where the key has to verify the user itself
+			credentials, err := data.GetWebAuthnCredentialsForUser(userID)
+			if err != nil {
+				errorlog.LogError("getting user WebAuthn keys", err)
 				writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 				return
 			}
 
-			// if we got here, no error -> password correct
-		} else {
-			// they do not, are they a dalton member? (that is, do they have a username?)
-			isDalton := strings.HasSuffix(user.Email, "@dalton.org")
-			if isDalton {
-				// they are
-				// we can't really authenticate them anymore
-				// just tell them the password is wrong and hope that they reset it
-				writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "password_incorrect"})
-				return
-			} else {
-				errorlog.LogError("user login", errors.New("user is missing password hash"))
-				writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			if !finishWebAuthnLogin(w, r, &user, credentials, true) {
 				return
 			}
-		}
 
-		// now we check for totp
-		enrolled2fa, err := isUser2FAEnrolled(userID)
-		if err != nil {
-			errorlog.LogError("getting user 2fa enrollment status", err)
-			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
-			return
-		}
-
-		if enrolled2fa {
-			if r.FormValue("code") == "" {
-				writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "totp_required"})
-				return
+			method = "webauthn"
+		} else {
+			// first we check for the easy path: they have a hash stored with us
+			if user.PasswordHash != "" {
+				// they do
+				err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+				if err == bcrypt.ErrMismatchedHashAndPassword {
+					// bye
+					logAuditEvent(r, userID, data.AuditEventLoginFailed, map[string]interface{}{
+						"reason": "password_incorrect",
+					})
+					writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "password_incorrect"})
+					return
+				} else if err != nil {
+					errorlog.LogError("user login", err)
+					writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+					return
+				}
+
+				// if we got here, no error -> password correct
+			} else {
+				// they do not, are they a dalton member? (that is, do they have a username?)
+				isDalton := strings.HasSuffix(user.Email, "@dalton.org")
+				if isDalton {
+					// they are
+					// we can't really authenticate them anymore
+					// just tell them the password is wrong and hope that they reset it
+					writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "password_incorrect"})
+					return
+				} else {
+					errorlog.LogError("user login", errors.New("user is missing password hash"))
+					writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+					return
+				}
 			}
 
-			secretRows, err := DB.Query("SELECT secret FROM totp WHERE userId = ?", userID)
-			if err != nil {
-				errorlog.LogError("getting user 2fa secret", err)
-				writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			// now we check for a second factor
+			secondFactor, ok := checkLoginSecondFactor(w, r, &user)
+			if !ok {
 				return
 			}
-			defer secretRows.Close()
-
-			secret := ""
-
-			secretRows.Next()
-			secretRows.Scan(&secret)
 
-			if !totp.Validate(r.FormValue("code"), secret) {
-				writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "bad_totp_code"})
-				return
+			method = "password"
+			if secondFactor != "" {
+				method += "+" + secondFactor
 			}
 		}
 
@@ -678,11 +729,12 @@ func routeAuthLogin(w http.ResponseWriter, r *http.Request, p httprouter.Params,
 		}
 
 		// now set their session cookie
-		session := auth.SessionInfo{
-			UserID: userID,
-		}
 		cookie, _ := r.Cookie("session")
-		auth.SetSession(cookie.Value, session)
+		auth.StartSession(cookie.Value, userID, getRequestRemoteAddr(r), r.UserAgent())
+
+		logAuditEvent(r, userID, data.AuditEventLogin, map[string]interface{}{
+			"method": method,
+		})
 
 		writeJSON(w, http.StatusOK, statusResponse{"ok"})
 	} else {
@@ -714,9 +766,15 @@ func routeAuthMe(w http.ResponseWriter, r *http.Request, p httprouter.Params, c
 }
 
 func routeAuthLogout(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	logAuditEvent(r, c.User.ID, data.AuditEventLogout, nil)
+
 	cookie, _ := r.Cookie("session")
-	newSession := auth.SessionInfo{-1}
-	auth.SetSession(cookie.Value, newSession)
+	err := auth.EndSession(cookie.Value)
+	if err != nil {
+		errorlog.LogError("logging out", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
 
@@ -767,6 +825,10 @@ func routeAuthRequestAccountDelete(w http.ResponseWriter, r *http.Request, p htt
 		return
 	}
 
+	logAuditEvent(r, c.User.ID, data.AuditEventAccountDeleteRequest, map[string]interface{}{
+		"clientType": clientType,
+	})
+
 	adminEmail := "astuder@myhomework.space"
 	err = email.Send(adminEmail, c.User, "accountDeletionRequest", map[string]interface{}{
 		"DeviceText": deviceText,
@@ -856,6 +918,8 @@ func routeAuthResetPassword(w http.ResponseWriter, r *http.Request, p httprouter
 			return
 		}
 
+		logAuditEvent(r, user.ID, data.AuditEventPasswordResetRequest, nil)
+
 		writeJSON(w, http.StatusOK, statusResponse{"ok"})
 	} else {
 		// email is not registered, bye
@@ -8,16 +8,37 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/MyHomeworkSpace/api-server/config"
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/email"
 	"github.com/MyHomeworkSpace/api-server/errorlog"
+	"github.com/MyHomeworkSpace/api-server/util"
+
+	"github.com/duo-labs/webauthn/protocol"
 
 	"github.com/julienschmidt/httprouter"
 
 	"github.com/pquerna/otp/totp"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 type enrollmentResponse struct {
-	Status   string `json:"status"`
-	Enrolled bool   `json:"enrolled"`
+	Status        string `json:"status"`
+	Enrolled      bool   `json:"enrolled"`
+	RecoveryCodes int    `json:"recoveryCodes"`
+}
+
+type recoveryCodesResponse struct {
+	Status string   `json:"status"`
+	Codes  []string `json:"codes"`
+}
+
+type secondFactorRequiredResponse struct {
+	Status   string                        `json:"status"`
+	Error    string                        `json:"error"`
+	Methods  []string                      `json:"methods"`
+	WebAuthn *protocol.CredentialAssertion `json:"webauthn"`
 }
 
 type totpSecretResponse struct {
@@ -48,6 +69,110 @@ func isUser2FAEnrolled(userID int) (bool, error) {
 	return true, nil
 }
 
+// checkTOTPCode checks the given code against the user's TOTP secret.
+// If it looks like a recovery code instead, it's checked against those, and can't be used again.
+// It returns the kind of code that was accepted, which is empty if it wasn't.
+func checkTOTPCode(userID int, code string) (string, error) {
+	rows, err := DB.Query("SELECT secret FROM totp WHERE userId = ?", userID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	secret := ""
+
+	rows.Next()
+	rows.Scan(&secret)
+
+	if totp.Validate(code, secret) {
+		return "totp", nil
+	}
+
+	// every recovery code has to be checked with bcrypt, so wrong TOTP codes shouldn't get that far
+	if !data.IsTOTPRecoveryCode(code) {
+		return "", nil
+	}
+
+	used, err := data.UseTOTPRecoveryCode(userID, code, int(time.Now().Unix()))
+	if err != nil {
+		return "", err
+	}
+
+	if used {
+		return "recovery_code", nil
+	}
+
+	return "", nil
+}
+
+// checkLoginSecondFactor makes sure that a user logging in with their password has given a second factor, if they've set one up.
+// It returns the second factor that was used, which is empty if they don't have one, and false after writing an error response if the login should not continue.
+func checkLoginSecondFactor(w http.ResponseWriter, r *http.Request, user *data.User) (string, bool) {
+	enrolled2fa, err := isUser2FAEnrolled(user.ID)
+	if err != nil {
+		errorlog.LogError("getting user 2fa enrollment status", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return "", false
+	}
+
+	credentials, err := data.GetWebAuthnCredentialsForUser(user.ID)
+	if err != nil {
+		errorlog.LogError("getting user WebAuthn keys", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return "", false
+	}
+
+	if !enrolled2fa && len(credentials) == 0 {
+		return "", true
+	}
+
+	if r.FormValue("webauthnResponse") != "" && len(credentials) > 0 {
+		return "webauthn", finishWebAuthnLogin(w, r, user, credentials, false)
+	}
+
+	// the code can also be one of their recovery codes
+	if r.FormValue("code") != "" && enrolled2fa {
+		method, err := checkTOTPCode(user.ID, r.FormValue("code"))
+		if err != nil {
+			errorlog.LogError("checking user 2fa code", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return "", false
+		}
+
+		if method == "" {
+			logAuditEvent(r, user.ID, data.AuditEventLoginFailed, map[string]interface{}{
+				"reason": "bad_totp_code",
+			})
+			writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "bad_totp_code"})
+			return "", false
+		}
+
+		return method, true
+	}
+
+	if len(credentials) == 0 {
+		// older clients only know about this error, so keep using it when totp is all they have
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "totp_required"})
+		return "", false
+	}
+
+	// they have a key, so give them a challenge to answer with it
+	options, err := beginWebAuthnLogin(r, user, credentials, false)
+	if err != nil {
+		errorlog.LogError("starting WebAuthn login", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return "", false
+	}
+
+	methods := []string{"webauthn"}
+	if enrolled2fa {
+		methods = append(methods, "totp")
+	}
+
+	writeJSON(w, http.StatusUnauthorized, secondFactorRequiredResponse{"error", "second_factor_required", methods, options})
+	return "", false
+}
+
 /*
  * routes
  */
@@ -144,16 +269,41 @@ func routeAuth2faCompleteEnroll(w http.ResponseWriter, r *http.Request, p httpro
 		return
 	}
 
-	// store the secret and remove it from redis
-	_, err = DB.Exec("INSERT INTO totp(userId, secret) VALUES(?, ?)", c.User.ID, secret)
+	// store the secret along with their recovery codes, and remove it from redis
+	tx, err := DB.Begin()
 	if err != nil {
-		errorlog.LogError("starting TOTP enrollment", err)
+		errorlog.LogError("completing TOTP enrollment", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	_, err = tx.Exec("INSERT INTO totp(userId, secret) VALUES(?, ?)", c.User.ID, secret)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("completing TOTP enrollment", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	codes, err := data.ReplaceTOTPRecoveryCodes(tx, c.User.ID, int(time.Now().Unix()))
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("completing TOTP enrollment", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("completing TOTP enrollment", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 	RedisClient.Del(redisKeyName)
 
-	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+	logAuditEvent(r, c.User.ID, data.AuditEventTOTPEnroll, nil)
+
+	writeJSON(w, http.StatusOK, recoveryCodesResponse{"ok", codes})
 }
 
 func routeAuth2faStatus(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
@@ -164,7 +314,17 @@ func routeAuth2faStatus(w http.ResponseWriter, r *http.Request, p httprouter.Par
 		return
 	}
 
-	writeJSON(w, http.StatusOK, enrollmentResponse{"ok", enrolled})
+	recoveryCodes := 0
+	if enrolled {
+		recoveryCodes, err = data.GetUnusedTOTPRecoveryCodeCount(c.User.ID)
+		if err != nil {
+			errorlog.LogError("getting TOTP enrollment status", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, enrollmentResponse{"ok", enrolled, recoveryCodes})
 }
 
 func routeAuth2faUnenroll(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
@@ -190,34 +350,167 @@ func routeAuth2faUnenroll(w http.ResponseWriter, r *http.Request, p httprouter.P
 		return
 	}
 
-	// get their secret
-	rows, err := DB.Query("SELECT secret FROM totp WHERE userId = ?", userID)
+	// verify the sample code
+	method, err := checkTOTPCode(userID, code)
 	if err != nil {
 		errorlog.LogError("handling TOTP unenrollment", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
-	secret := ""
+	if method == "" {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "bad_totp_code"})
+		return
+	}
 
-	rows.Next()
-	rows.Scan(&secret)
+	// remove their secret and recovery codes
+	_, err = DB.Exec("DELETE FROM totp WHERE userID = ?", userID)
+	if err != nil {
+		errorlog.LogError("handling TOTP unenrollment", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
 
-	// verify the sample code
-	validated := totp.Validate(code, secret)
+	_, err = DB.Exec("DELETE FROM totp_recovery_codes WHERE userId = ?", userID)
+	if err != nil {
+		errorlog.LogError("handling TOTP unenrollment", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
 
-	if !validated {
+	logAuditEvent(r, userID, data.AuditEventTOTPUnenroll, nil)
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeAuth2faRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("code") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	enrolled, err := isUser2FAEnrolled(c.User.ID)
+	if err != nil {
+		errorlog.LogError("regenerating TOTP recovery codes", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	if !enrolled {
+		writeJSON(w, http.StatusNotFound, errorResponse{"error", "not_found"})
+		return
+	}
+
+	method, err := checkTOTPCode(c.User.ID, r.FormValue("code"))
+	if err != nil {
+		errorlog.LogError("regenerating TOTP recovery codes", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	if method == "" {
 		writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "bad_totp_code"})
 		return
 	}
 
-	// remove their secret
-	_, err = DB.Exec("DELETE FROM totp WHERE userID = ?", userID)
+	tx, err := DB.Begin()
 	if err != nil {
-		errorlog.LogError("handling TOTP unenrollment", err)
+		errorlog.LogError("regenerating TOTP recovery codes", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	codes, err := data.ReplaceTOTPRecoveryCodes(tx, c.User.ID, int(time.Now().Unix()))
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("regenerating TOTP recovery codes", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("regenerating TOTP recovery codes", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	logAuditEvent(r, c.User.ID, data.AuditEventRecoveryCodesRegenerate, nil)
+
+	writeJSON(w, http.StatusOK, recoveryCodesResponse{"ok", codes})
+}
+
+func routeAuth2faRequestReset(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("email") == "" || r.FormValue("password") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	exists, userID, err := data.UserExistsWithEmail(r.FormValue("email"))
+	if err != nil {
+		errorlog.LogError("requesting 2fa reset", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
+	if !exists {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "no_account"})
+		return
+	}
+
+	user, err := data.GetUserByID(userID)
+	if err != nil {
+		errorlog.LogError("requesting 2fa reset", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// they still need to know their password, the email only stands in for the second factor
+	if user.PasswordHash == "" {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "password_incorrect"})
+		return
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(r.FormValue("password")))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "password_incorrect"})
+		return
+	} else if err != nil {
+		errorlog.LogError("requesting 2fa reset", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	tokenString, err := util.GenerateRandomString(64)
+	if err != nil {
+		errorlog.LogError("requesting 2fa reset", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = data.SaveEmailToken(data.EmailToken{
+		Token:    tokenString,
+		Type:     data.EmailTokenReset2FA,
+		Metadata: "",
+		UserID:   user.ID,
+	})
+	if err != nil {
+		errorlog.LogError("requesting 2fa reset", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = email.Send("", &user, "twoFactorReset", map[string]interface{}{
+		"url":       config.GetCurrent().Server.APIURLBase + "auth/completeEmailStart/" + tokenString,
+		"IPAddress": getRequestRemoteAddr(r),
+	})
+	if err != nil {
+		errorlog.LogError("requesting 2fa reset", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	logAuditEvent(r, user.ID, data.AuditEvent2FAResetRequest, nil)
+
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type auditLogResponse struct {
+	Status  string               `json:"status"`
+	Entries []data.AuditLogEntry `json:"entries"`
+}
+
+/*
+ * helpers
+ */
+
+// logAuditEvent adds an entry to the given user's audit log, recording where the request came from.
+// A failure to log is reported, but doesn't stop the request.
+func logAuditEvent(r *http.Request, userID int, event data.AuditEvent, details map[string]interface{}) {
+	err := data.AddAuditLogEntry(data.AuditLogEntry{
+		UserID:    userID,
+		Event:     event,
+		IPAddress: getRequestRemoteAddr(r),
+		UserAgent: r.UserAgent(),
+		Timestamp: int(time.Now().Unix()),
+	}, details)
+	if err != nil {
+		errorlog.LogError("adding audit log entry", err)
+	}
+}
+
+// parseAuditLogPage reads the before and limit parameters used to page through the audit log.
+func parseAuditLogPage(r *http.Request, filter *data.AuditLogFilter) bool {
+	filter.Before = -1
+	filter.Limit = data.MaxAuditLogPageSize
+
+	if r.FormValue("before") != "" {
+		before, err := strconv.Atoi(r.FormValue("before"))
+		if err != nil {
+			return false
+		}
+		filter.Before = before
+	}
+
+	if r.FormValue("limit") != "" {
+		limit, err := strconv.Atoi(r.FormValue("limit"))
+		if err != nil || limit <= 0 || limit > data.MaxAuditLogPageSize {
+			return false
+		}
+		filter.Limit = limit
+	}
+
+	return true
+}
+
+/*
+ * routes
+ */
+
+func routeAuthGetAuditLog(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	filter := data.AuditLogFilter{
+		UserID: c.User.ID,
+		Start:  -1,
+		End:    -1,
+	}
+
+	if !parseAuditLogPage(r, &filter) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	entries, err := data.QueryAuditLog(filter)
+	if err != nil {
+		errorlog.LogError("getting audit log", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, auditLogResponse{"ok", entries})
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/MyHomeworkSpace/api-server/auth"
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type sessionsResponse struct {
+	Status   string                 `json:"status"`
+	Sessions []auth.SessionMetadata `json:"sessions"`
+}
+
+/*
+ * helpers
+ */
+
+// getCurrentSessionName returns the name of the request's session, or an empty string if it's using an auth token.
+func getCurrentSessionName(r *http.Request) string {
+	if HasAuthToken(r) {
+		return ""
+	}
+
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		return ""
+	}
+
+	return cookie.Value
+}
+
+/*
+ * routes
+ */
+
+func routeAuthSessionsGetAll(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	sessions, err := auth.GetSessionsForUser(c.User.ID, getCurrentSessionName(r))
+	if err != nil {
+		errorlog.LogError("getting sessions", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, sessionsResponse{"ok", sessions})
+}
+
+func routeAuthSessionsRevoke(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("id") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	found, err := auth.RevokeSession(c.User.ID, r.FormValue("id"))
+	if err != nil {
+		errorlog.LogError("revoking session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	if !found {
+		writeJSON(w, http.StatusNotFound, errorResponse{"error", "not_found"})
+		return
+	}
+
+	logAuditEvent(r, c.User.ID, data.AuditEventSessionRevoke, map[string]interface{}{
+		"id": r.FormValue("id"),
+	})
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeAuthSessionsRevokeOthers(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	err := auth.RevokeOtherSessions(c.User.ID, getCurrentSessionName(r))
+	if err != nil {
+		errorlog.LogError("revoking other sessions", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	logAuditEvent(r, c.User.ID, data.AuditEventSessionRevokeOthers, nil)
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type personalAccessTokensResponse struct {
+	Status string                     `json:"status"`
+	Tokens []data.PersonalAccessToken `json:"tokens"`
+}
+
+type newPersonalAccessTokenResponse struct {
+	Status string `json:"status"`
+	Token  string `json:"token"`
+}
+
+/*
+ * routes
+ */
+
+func routeAuthTokensGetAll(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	tokens, err := data.GetPersonalAccessTokensForUser(c.User.ID)
+	if err != nil {
+		errorlog.LogError("getting personal access tokens", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, personalAccessTokensResponse{"ok", tokens})
+}
+
+func routeAuthTokensCreate(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("name") == "" || r.FormValue("scopes") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" || len(name) > data.MaxPersonalAccessTokenNameLength {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	scopes, err := data.ParseScopes(r.FormValue("scopes"))
+	if err != nil || len(scopes) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	now := int(time.Now().Unix())
+
+	// leaving out the expiry makes a token that lasts until it's revoked
+	expiresAt := -1
+	if r.FormValue("expiresAt") != "" {
+		expiresAt, err = strconv.Atoi(r.FormValue("expiresAt"))
+		if err != nil || expiresAt <= now {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+	}
+
+	existing, err := data.GetPersonalAccessTokensForUser(c.User.ID)
+	if err != nil {
+		errorlog.LogError("creating personal access token", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	if len(existing) >= data.MaxPersonalAccessTokens {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "too_many_tokens"})
+		return
+	}
+
+	// only the hash is kept, so this is the one chance to see the token
+	token, err := data.AddPersonalAccessToken(data.PersonalAccessToken{
+		Name:      name,
+		Scopes:    scopes,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+		UserID:    c.User.ID,
+	})
+	if err != nil {
+		errorlog.LogError("creating personal access token", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	logAuditEvent(r, c.User.ID, data.AuditEventPersonalTokenCreate, map[string]interface{}{
+		"name":      name,
+		"scopes":    data.FormatScopes(scopes),
+		"expiresAt": expiresAt,
+	})
+
+	writeJSON(w, http.StatusOK, newPersonalAccessTokenResponse{"ok", token})
+}
+
+func routeAuthTokensRevoke(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("id") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("revoking personal access token", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	found, err := data.DeletePersonalAccessToken(tx, id, c.User.ID)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("revoking personal access token", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	if !found {
+		tx.Rollback()
+		writeJSON(w, http.StatusNotFound, errorResponse{"error", "not_found"})
+		return
+	}
+
+	// like with applications, the token's webhooks go away with it
+	err = data.DeleteWebhooksForPersonalAccessToken(tx, id)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("revoking personal access token", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("revoking personal access token", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	logAuditEvent(r, c.User.ID, data.AuditEventPersonalTokenRevoke, map[string]interface{}{
+		"id": id,
+	})
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
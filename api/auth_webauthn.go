@@ -0,0 +1,435 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+
+	"github.com/duo-labs/webauthn/protocol"
+	"github.com/duo-labs/webauthn/webauthn"
+
+	"github.com/julienschmidt/httprouter"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/redis.v5"
+)
+
+// the most keys that one user can register
+const maxWebAuthnCredentials = 20
+
+// how long someone has to answer a WebAuthn challenge
+const webAuthnChallengeTimeout = 10 * time.Minute
+
+type webAuthnOptionsResponse struct {
+	Status  string      `json:"status"`
+	Options interface{} `json:"options"`
+}
+
+type webAuthnKeysResponse struct {
+	Status string                    `json:"status"`
+	Keys   []data.WebAuthnCredential `json:"keys"`
+}
+
+type webAuthnKeyAddedResponse struct {
+	Status string `json:"status"`
+	ID     int    `json:"id"`
+}
+
+var errWebAuthnNoSession = errors.New("api: no session cookie for WebAuthn login")
+
+/*
+ * helpers
+ */
+
+func getWebAuthnLoginKey(r *http.Request) (string, error) {
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		return "", errWebAuthnNoSession
+	}
+
+	return "webauthn_login:" + cookie.Value, nil
+}
+
+// beginWebAuthnLogin creates a challenge for the given user to answer with one of their keys, and stores it for the current session.
+// If passwordless is set, the key must verify the user (with a PIN or biometric), since it's the only factor.
+func beginWebAuthnLogin(r *http.Request, user *data.User, credentials []data.WebAuthnCredential, passwordless bool) (*protocol.CredentialAssertion, error) {
+	redisKeyName, err := getWebAuthnLoginKey(r)
+	if err != nil {
+		return nil, err
+	}
+
+	verification := protocol.VerificationPreferred
+	if passwordless {
+		verification = protocol.VerificationRequired
+	}
+
+	options, sessionData, err := WebAuthnHandler.BeginLogin(data.WebAuthnUser{User: user, Credentials: credentials}, webauthn.WithUserVerification(verification))
+	if err != nil {
+		return nil, err
+	}
+
+	sessionJSON, err := json.Marshal(sessionData)
+	if err != nil {
+		return nil, err
+	}
+
+	err = RedisClient.Set(redisKeyName, string(sessionJSON), webAuthnChallengeTimeout).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+// finishWebAuthnLogin checks the webauthnResponse of the request against the challenge from beginWebAuthnLogin.
+// It returns false, after writing an error response, if the login should not continue.
+func finishWebAuthnLogin(w http.ResponseWriter, r *http.Request, user *data.User, credentials []data.WebAuthnCredential, passwordless bool) bool {
+	redisKeyName, err := getWebAuthnLoginKey(r)
+	if err != nil {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "webauthn_challenge_expired"})
+		return false
+	}
+
+	sessionJSON, err := RedisClient.Get(redisKeyName).Result()
+	if err == redis.Nil {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "webauthn_challenge_expired"})
+		return false
+	} else if err != nil {
+		errorlog.LogError("finishing WebAuthn login", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return false
+	}
+
+	// a challenge can only be answered once
+	RedisClient.Del(redisKeyName)
+
+	sessionData := webauthn.SessionData{}
+	err = json.Unmarshal([]byte(sessionJSON), &sessionData)
+	if err != nil {
+		errorlog.LogError("finishing WebAuthn login", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return false
+	}
+
+	// a challenge made for a second factor doesn't require user verification, so it can't be used without a password
+	if passwordless && sessionData.UserVerification != protocol.VerificationRequired {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "bad_webauthn_response"})
+		return false
+	}
+
+	parsedResponse, err := protocol.ParseCredentialRequestResponseBody(strings.NewReader(r.FormValue("webauthnResponse")))
+	if err != nil {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "bad_webauthn_response"})
+		return false
+	}
+
+	// this also makes sure that the challenge was for this user
+	credential, err := WebAuthnHandler.ValidateLogin(data.WebAuthnUser{User: user, Credentials: credentials}, sessionData, parsedResponse)
+	if err != nil {
+		logAuditEvent(r, user.ID, data.AuditEventLoginFailed, map[string]interface{}{
+			"reason": "bad_webauthn_response",
+		})
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "bad_webauthn_response"})
+		return false
+	}
+
+	if credential.Authenticator.CloneWarning {
+		// the sign counter went backwards, so the key might have been cloned
+		errorlog.LogError("finishing WebAuthn login", fmt.Errorf("sign counter did not increase for a key of user %d", user.ID))
+		logAuditEvent(r, user.ID, data.AuditEventLoginFailed, map[string]interface{}{
+			"reason": "webauthn_clone_warning",
+		})
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "bad_webauthn_response"})
+		return false
+	}
+
+	err = data.UpdateWebAuthnCredentialUse(credential.ID, credential.Authenticator.SignCount, int(time.Now().Unix()))
+	if err != nil {
+		errorlog.LogError("finishing WebAuthn login", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return false
+	}
+
+	return true
+}
+
+/*
+ * routes
+ */
+
+func routeAuthWebauthnBeginLogin(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("email") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	exists, userID, err := data.UserExistsWithEmail(r.FormValue("email"))
+	if err != nil {
+		errorlog.LogError("starting WebAuthn login", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	if !exists {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "no_account"})
+		return
+	}
+
+	user, err := data.GetUserByID(userID)
+	if err != nil {
+		errorlog.LogError("starting WebAuthn login", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	credentials, err := data.GetWebAuthnCredentialsForUser(user.ID)
+	if err != nil {
+		errorlog.LogError("starting WebAuthn login", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	if len(credentials) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "no_webauthn_keys"})
+		return
+	}
+
+	options, err := beginWebAuthnLogin(r, &user, credentials, true)
+	if err != nil {
+		errorlog.LogError("starting WebAuthn login", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, webAuthnOptionsResponse{"ok", options})
+}
+
+func routeAuthWebauthnBeginRegister(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	credentials, err := data.GetWebAuthnCredentialsForUser(c.User.ID)
+	if err != nil {
+		errorlog.LogError("starting WebAuthn registration", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	if len(credentials) >= maxWebAuthnCredentials {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "too_many_keys"})
+		return
+	}
+
+	// don't let them register the same key twice
+	exclusions := []protocol.CredentialDescriptor{}
+	for _, credential := range credentials {
+		exclusions = append(exclusions, protocol.CredentialDescriptor{
+			Type:         protocol.PublicKeyCredentialType,
+			CredentialID: credential.Credential.ID,
+		})
+	}
+
+	options, sessionData, err := WebAuthnHandler.BeginRegistration(data.WebAuthnUser{User: c.User, Credentials: credentials}, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		errorlog.LogError("starting WebAuthn registration", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	sessionJSON, err := json.Marshal(sessionData)
+	if err != nil {
+		errorlog.LogError("starting WebAuthn registration", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	redisKeyName := fmt.Sprintf("user:%d:webauthn_tmp", c.User.ID)
+	err = RedisClient.Set(redisKeyName, string(sessionJSON), webAuthnChallengeTimeout).Err()
+	if err != nil {
+		errorlog.LogError("starting WebAuthn registration", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, webAuthnOptionsResponse{"ok", options})
+}
+
+func routeAuthWebauthnCompleteRegister(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("name") == "" || r.FormValue("response") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" || len(name) > data.MaxWebAuthnCredentialNameLength {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	// do they have a challenge in redis?
+	redisKeyName := fmt.Sprintf("user:%d:webauthn_tmp", c.User.ID)
+	sessionJSON, err := RedisClient.Get(redisKeyName).Result()
+	if err == redis.Nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "webauthn_challenge_expired"})
+		return
+	} else if err != nil {
+		errorlog.LogError("completing WebAuthn registration", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	sessionData := webauthn.SessionData{}
+	err = json.Unmarshal([]byte(sessionJSON), &sessionData)
+	if err != nil {
+		errorlog.LogError("completing WebAuthn registration", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	credentials, err := data.GetWebAuthnCredentialsForUser(c.User.ID)
+	if err != nil {
+		errorlog.LogError("completing WebAuthn registration", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	if len(credentials) >= maxWebAuthnCredentials {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "too_many_keys"})
+		return
+	}
+
+	parsedResponse, err := protocol.ParseCredentialCreationResponseBody(strings.NewReader(r.FormValue("response")))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "bad_webauthn_response"})
+		return
+	}
+
+	credential, err := WebAuthnHandler.CreateCredential(data.WebAuthnUser{User: c.User, Credentials: credentials}, sessionData, parsedResponse)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "bad_webauthn_response"})
+		return
+	}
+
+	// store the key and remove the challenge from redis
+	id, err := data.AddWebAuthnCredential(c.User.ID, name, *credential, int(time.Now().Unix()))
+	if err != nil {
+		errorlog.LogError("completing WebAuthn registration", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	RedisClient.Del(redisKeyName)
+
+	logAuditEvent(r, c.User.ID, data.AuditEventWebAuthnKeyAdd, map[string]interface{}{
+		"id":   id,
+		"name": name,
+	})
+
+	writeJSON(w, http.StatusOK, webAuthnKeyAddedResponse{"ok", id})
+}
+
+func routeAuthWebauthnGetKeys(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	credentials, err := data.GetWebAuthnCredentialsForUser(c.User.ID)
+	if err != nil {
+		errorlog.LogError("getting WebAuthn keys", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, webAuthnKeysResponse{"ok", credentials})
+}
+
+func routeAuthWebauthnRenameKey(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("id") == "" || r.FormValue("name") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" || len(name) > data.MaxWebAuthnCredentialNameLength {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	_, err = data.GetWebAuthnCredentialByID(id, c.User.ID)
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	} else if err != nil {
+		errorlog.LogError("renaming WebAuthn key", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	_, err = DB.Exec("UPDATE webauthn_credentials SET name = ? WHERE id = ? AND userId = ?", name, id, c.User.ID)
+	if err != nil {
+		errorlog.LogError("renaming WebAuthn key", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeAuthWebauthnRemoveKey(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("id") == "" || r.FormValue("password") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	if c.User.PasswordHash == "" {
+		errorlog.LogError("removing WebAuthn key", errors.New("user is missing password hash"))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// removing a key makes the account less secure, so make sure it's really them
+	err = bcrypt.CompareHashAndPassword([]byte(c.User.PasswordHash), []byte(r.FormValue("password")))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"error", "password_incorrect"})
+		return
+	} else if err != nil {
+		errorlog.LogError("removing WebAuthn key", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	credential, err := data.GetWebAuthnCredentialByID(id, c.User.ID)
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	} else if err != nil {
+		errorlog.LogError("removing WebAuthn key", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	_, err = DB.Exec("DELETE FROM webauthn_credentials WHERE id = ? AND userId = ?", id, c.User.ID)
+	if err != nil {
+		errorlog.LogError("removing WebAuthn key", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	logAuditEvent(r, c.User.ID, data.AuditEventWebAuthnKeyRemove, map[string]interface{}{
+		"id":   id,
+		"name": credential.Name,
+	})
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
@@ -3,7 +3,9 @@ package api
 import (
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/MyHomeworkSpace/api-server/calendar"
 	"github.com/MyHomeworkSpace/api-server/data"
 	"github.com/MyHomeworkSpace/api-server/errorlog"
 
@@ -24,7 +26,7 @@ func routeCalendarEventChangesGet(w http.ResponseWriter, r *http.Request, p http
 
 	eventID := r.FormValue("eventID")
 
-	rows, err := DB.Query("SELECT eventID, cancel, userID FROM calendar_event_changes WHERE eventID = ? AND userID = ?", eventID, c.User.ID)
+	rows, err := DB.Query("SELECT eventID, cancel, IFNULL(`start`, -1), IFNULL(`end`, -1), userID FROM calendar_event_changes WHERE eventID = ? AND userID = ?", eventID, c.User.ID)
 	if err != nil {
 		errorlog.LogError("getting calendar event change", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -38,7 +40,7 @@ func routeCalendarEventChangesGet(w http.ResponseWriter, r *http.Request, p http
 	}
 
 	eventChange := data.EventChange{}
-	rows.Scan(&eventChange.EventID, &eventChange.Cancel, &eventChange.UserID)
+	rows.Scan(&eventChange.EventID, &eventChange.Cancel, &eventChange.Start, &eventChange.End, &eventChange.UserID)
 
 	writeJSON(w, http.StatusOK, eventChangeResponse{"ok", &eventChange})
 }
@@ -62,6 +64,32 @@ func routeCalendarEventChangesSet(w http.ResponseWriter, r *http.Request, p http
 		cancelInt = 1
 	}
 
+	// the event can also be moved, by giving both a new start and end
+	var start, end *int
+	if r.FormValue("start") != "" || r.FormValue("end") != "" {
+		startInt, err := strconv.Atoi(r.FormValue("start"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+
+		endInt, err := strconv.Atoi(r.FormValue("end"))
+		if err != nil || endInt < startInt {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+
+		start = &startInt
+		end = &endInt
+	}
+
+	timeZone, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		errorlog.LogError("timezone info", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
 	rows, err := DB.Query("SELECT eventID FROM calendar_event_changes WHERE eventID = ? AND userID = ?", eventID, c.User.ID)
 	if err != nil {
 		errorlog.LogError("getting calendar event change", err)
@@ -73,8 +101,8 @@ func routeCalendarEventChangesSet(w http.ResponseWriter, r *http.Request, p http
 	if !rows.Next() {
 		// doesn't exist, add it
 		_, err = DB.Exec(
-			"INSERT INTO calendar_event_changes(eventID, cancel, userID) VALUES(?, ?, ?)",
-			eventID, cancelInt, c.User.ID,
+			"INSERT INTO calendar_event_changes(eventID, cancel, `start`, `end`, userID) VALUES(?, ?, ?, ?, ?)",
+			eventID, cancelInt, start, end, c.User.ID,
 		)
 		if err != nil {
 			errorlog.LogError("inserting calendar event change", err)
@@ -82,10 +110,10 @@ func routeCalendarEventChangesSet(w http.ResponseWriter, r *http.Request, p http
 			return
 		}
 	} else {
-		// exists already, update it
+		// exists already, update it, keeping any move that this request didn't say anything about
 		_, err = DB.Exec(
-			"UPDATE calendar_event_changes SET cancel = ? WHERE eventID = ? AND userID = ?",
-			cancelInt, eventID, c.User.ID,
+			"UPDATE calendar_event_changes SET cancel = ?, `start` = IFNULL(?, `start`), `end` = IFNULL(?, `end`) WHERE eventID = ? AND userID = ?",
+			cancelInt, start, end, eventID, c.User.ID,
 		)
 		if err != nil {
 			errorlog.LogError("updating calendar event change", err)
@@ -94,5 +122,36 @@ func routeCalendarEventChangesSet(w http.ResponseWriter, r *http.Request, p http
 		}
 	}
 
+	// read back the whole change, since parts of it could be from earlier requests
+	changeRows, err := DB.Query("SELECT eventID, cancel, IFNULL(`start`, -1), IFNULL(`end`, -1), userID FROM calendar_event_changes WHERE eventID = ? AND userID = ?", eventID, c.User.ID)
+	if err != nil {
+		errorlog.LogError("getting calendar event change", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	defer changeRows.Close()
+
+	eventChange := data.EventChange{}
+	if changeRows.Next() {
+		err = changeRows.Scan(&eventChange.EventID, &eventChange.Cancel, &eventChange.Start, &eventChange.End, &eventChange.UserID)
+	}
+	if err != nil || eventChange.EventID == "" {
+		errorlog.LogError("getting calendar event change", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	changeRows.Close()
+
+	// any homework that's due at this event should follow it
+	movedHomework, err := calendar.UpdateHomeworkForEvent(DB, c.User, timeZone, eventChange)
+	if err != nil {
+		errorlog.LogError("updating homework for calendar event change", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	queueWebhookEvent(c.User.ID, data.WebhookEventCalendarEventChanged, eventChange)
+	queueHomeworkWebhookEvents(data.WebhookEventHomeworkUpdated, movedHomework)
+
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
@@ -252,6 +252,8 @@ func routeCalendarEventsAdd(w http.ResponseWriter, r *http.Request, p httprouter
 		}
 	}
 
+	queueWebhookEvent(c.User.ID, data.WebhookEventCalendarEventCreated, webhookItem{int(eventID), r.FormValue("name")})
+
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
 
@@ -276,7 +278,7 @@ func routeCalendarEventsEdit(w http.ResponseWriter, r *http.Request, p httproute
 	}
 
 	// check if you are allowed to edit the given id
-	idRows, err := DB.Query("SELECT id FROM calendar_events WHERE userId = ? AND id = ?", c.User.ID, r.FormValue("id"))
+	idRows, err := DB.Query("SELECT id FROM calendar_events WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, r.FormValue("id"))
 	if err != nil {
 		errorlog.LogError("editing calendar event", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -361,6 +363,9 @@ func routeCalendarEventsEdit(w http.ResponseWriter, r *http.Request, p httproute
 		}
 	}
 
+	id, _ := strconv.Atoi(r.FormValue("id"))
+	queueWebhookEvent(c.User.ID, data.WebhookEventCalendarEventUpdated, webhookItem{id, r.FormValue("name")})
+
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
 
@@ -370,8 +375,14 @@ func routeCalendarEventsDelete(w http.ResponseWriter, r *http.Request, p httprou
 		return
 	}
 
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
 	// check if you are allowed to edit the given id
-	idRows, err := DB.Query("SELECT id FROM calendar_events WHERE userId = ? AND id = ?", c.User.ID, r.FormValue("id"))
+	idRows, err := DB.Query("SELECT name FROM calendar_events WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, id)
 	if err != nil {
 		errorlog.LogError("deleting calendar event", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -383,28 +394,43 @@ func routeCalendarEventsDelete(w http.ResponseWriter, r *http.Request, p httprou
 		return
 	}
 
-	// delete the event
-	_, err = DB.Exec(
-		"DELETE FROM calendar_events WHERE id = ?",
-		r.FormValue("id"),
-	)
+	name := ""
+	idRows.Scan(&name)
+
+	tx, err := DB.Begin()
 	if err != nil {
 		errorlog.LogError("deleting calendar event", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
-	// delete any recur rules associated with the event
-	_, err = DB.Exec(
-		"DELETE FROM calendar_event_rules WHERE eventId = ?",
-		r.FormValue("id"),
-	)
+	// move the event to the trash
+	// its recur rules are left alone, and get cleaned up when the trash is purged
+	trashID, err := data.AddToTrash(tx, data.TrashItemEvent, id, name, c.User.ID)
 	if err != nil {
+		tx.Rollback()
 		errorlog.LogError("deleting calendar event", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
+	_, err = tx.Exec("UPDATE calendar_events SET trashId = ? WHERE id = ?", trashID, id)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("deleting calendar event", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("deleting calendar event", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	queueWebhookEvent(c.User.ID, data.WebhookEventCalendarEventDeleted, webhookItem{id, name})
+
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
 
@@ -422,7 +448,7 @@ func routeCalendarHWEventsAdd(w http.ResponseWriter, r *http.Request, p httprout
 	}
 
 	// check you own the homework you're trying to associate this with
-	rows, err := DB.Query("SELECT id FROM homework WHERE userId = ? AND id = ?", c.User.ID, r.FormValue("homeworkId"))
+	rows, err := DB.Query("SELECT id FROM homework WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, r.FormValue("homeworkId"))
 	if err != nil {
 		errorlog.LogError("adding calendar homework event", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -461,7 +487,7 @@ func routeCalendarHWEventsEdit(w http.ResponseWriter, r *http.Request, p httprou
 	}
 
 	// check if you are allowed to edit the given id
-	idRows, err := DB.Query("SELECT id FROM calendar_hwevents WHERE userId = ? AND id = ?", c.User.ID, r.FormValue("id"))
+	idRows, err := DB.Query("SELECT id FROM calendar_hwevents WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, r.FormValue("id"))
 	if err != nil {
 		errorlog.LogError("editing calendar homework event", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -474,7 +500,7 @@ func routeCalendarHWEventsEdit(w http.ResponseWriter, r *http.Request, p httprou
 	}
 
 	// check you own the homework you're trying to associate this with
-	rows, err := DB.Query("SELECT id FROM homework WHERE userId = ? AND id = ?", c.User.ID, r.FormValue("homeworkId"))
+	rows, err := DB.Query("SELECT id FROM homework WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, r.FormValue("homeworkId"))
 	if err != nil {
 		errorlog.LogError("adding calendar homework event", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -506,7 +532,7 @@ func routeCalendarHWEventsDelete(w http.ResponseWriter, r *http.Request, p httpr
 	}
 
 	// check if you are allowed to edit the given id
-	idRows, err := DB.Query("SELECT id FROM calendar_hwevents WHERE userId = ? AND id = ?", c.User.ID, r.FormValue("id"))
+	idRows, err := DB.Query("SELECT id FROM calendar_hwevents WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, r.FormValue("id"))
 	if err != nil {
 		errorlog.LogError("deleting calendar homework event", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -0,0 +1,398 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// responses
+type classDetailsResponse struct {
+	Status      string                  `json:"status"`
+	Contacts    []data.ClassContact     `json:"contacts"`
+	OfficeHours []data.ClassOfficeHours `json:"officeHours"`
+}
+
+var classContactRoles = map[string]data.ClassContactRole{
+	"instructor": data.ClassContactRoleInstructor,
+	"ta":         data.ClassContactRoleTA,
+}
+
+/*
+ * helpers
+ */
+
+func isValidClassLink(link string) bool {
+	if link == "" {
+		return true
+	}
+
+	if len(link) > 512 {
+		return false
+	}
+
+	parsedLink, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	return (parsedLink.Scheme == "http" || parsedLink.Scheme == "https") && parsedLink.Host != ""
+}
+
+// getOwnedClassID reads a class ID from the given form key and checks that it belongs to the current user.
+func getOwnedClassID(w http.ResponseWriter, r *http.Request, c RouteContext, formKey string, action string) (int, bool) {
+	if r.FormValue(formKey) == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return -1, false
+	}
+
+	classID, err := strconv.Atoi(r.FormValue(formKey))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return -1, false
+	}
+
+	owned, err := isClassOwnedByUser(classID, c.User.ID)
+	if err != nil {
+		errorlog.LogError(action, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return -1, false
+	}
+	if !owned {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return -1, false
+	}
+
+	return classID, true
+}
+
+// getOwnedClassDetailID reads the ID of a row in the given table, which is either class_contacts or class_office_hours, and checks that it belongs to the current user.
+// It returns the ID and the class that the row is part of.
+func getOwnedClassDetailID(w http.ResponseWriter, r *http.Request, c RouteContext, table string, action string) (int, int, bool) {
+	if r.FormValue("id") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return -1, -1, false
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return -1, -1, false
+	}
+
+	rows, err := DB.Query(
+		"SELECT "+table+".classId FROM "+table+" INNER JOIN classes ON "+table+".classId = classes.id WHERE "+table+".id = ? AND classes.userId = ? AND classes.trashId IS NULL",
+		id, c.User.ID,
+	)
+	if err != nil {
+		errorlog.LogError(action, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return -1, -1, false
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return -1, -1, false
+	}
+
+	classID := -1
+	err = rows.Scan(&classID)
+	if err != nil {
+		errorlog.LogError(action, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return -1, -1, false
+	}
+
+	return id, classID, true
+}
+
+func parseClassContactForm(w http.ResponseWriter, r *http.Request) (string, data.ClassContactRole, string, bool) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" || r.FormValue("role") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return "", 0, "", false
+	}
+
+	role, validRole := classContactRoles[r.FormValue("role")]
+	email := strings.TrimSpace(r.FormValue("email"))
+	if !validRole || len(name) > 128 || len(email) > 255 || (email != "" && !strings.Contains(email, "@")) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return "", 0, "", false
+	}
+
+	return name, role, email, true
+}
+
+// parseClassOfficeHoursForm reads the fields of ClassOfficeHours from the form. The contact ID is nil if it wasn't given.
+func parseClassOfficeHoursForm(w http.ResponseWriter, r *http.Request, classID int, action string) (*int, int, int, int, string, bool) {
+	if r.FormValue("dayOfWeek") == "" || r.FormValue("start") == "" || r.FormValue("end") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return nil, 0, 0, 0, "", false
+	}
+
+	dayOfWeek, err := strconv.Atoi(r.FormValue("dayOfWeek"))
+	if err != nil || dayOfWeek < 0 || dayOfWeek > 6 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return nil, 0, 0, 0, "", false
+	}
+
+	start, err := strconv.Atoi(r.FormValue("start"))
+	if err != nil || start < 0 || start >= 24*60*60 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return nil, 0, 0, 0, "", false
+	}
+
+	end, err := strconv.Atoi(r.FormValue("end"))
+	if err != nil || end <= start || end > 24*60*60 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return nil, 0, 0, 0, "", false
+	}
+
+	location := strings.TrimSpace(r.FormValue("location"))
+	if len(location) > 255 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return nil, 0, 0, 0, "", false
+	}
+
+	var contactID *int
+	if r.FormValue("contactId") != "" {
+		contactIDInt, err := strconv.Atoi(r.FormValue("contactId"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return nil, 0, 0, 0, "", false
+		}
+
+		// the contact has to be from the same class
+		contactRows, err := DB.Query("SELECT id FROM class_contacts WHERE classId = ? AND id = ?", classID, contactIDInt)
+		if err != nil {
+			errorlog.LogError(action, err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return nil, 0, 0, 0, "", false
+		}
+		defer contactRows.Close()
+		if !contactRows.Next() {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return nil, 0, 0, 0, "", false
+		}
+
+		contactID = &contactIDInt
+	}
+
+	return contactID, dayOfWeek, start, end, location, true
+}
+
+/*
+ * routes
+ */
+
+func routeClassesGetDetails(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	classID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	owned, err := isClassOwnedByUser(classID, c.User.ID)
+	if err != nil {
+		errorlog.LogError("getting class details", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	if !owned {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
+
+	contacts, err := data.GetContactsForClass(classID)
+	if err != nil {
+		errorlog.LogError("getting class details", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	officeHours, err := data.GetOfficeHoursForClass(classID)
+	if err != nil {
+		errorlog.LogError("getting class details", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, classDetailsResponse{"ok", contacts, officeHours})
+}
+
+func routeClassesSetLinks(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	website := strings.TrimSpace(r.FormValue("website"))
+	syllabus := strings.TrimSpace(r.FormValue("syllabus"))
+	meetingLink := strings.TrimSpace(r.FormValue("meetingLink"))
+
+	if !isValidClassLink(website) || !isValidClassLink(syllabus) || !isValidClassLink(meetingLink) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	classID, ok := getOwnedClassID(w, r, c, "id", "setting class links")
+	if !ok {
+		return
+	}
+
+	_, err := DB.Exec("UPDATE classes SET website = ?, syllabus = ?, meetingLink = ? WHERE id = ?", website, syllabus, meetingLink, classID)
+	if err != nil {
+		errorlog.LogError("setting class links", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeClassesContactsAdd(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	name, role, email, ok := parseClassContactForm(w, r)
+	if !ok {
+		return
+	}
+
+	classID, ok := getOwnedClassID(w, r, c, "classId", "adding class contact")
+	if !ok {
+		return
+	}
+
+	_, err := DB.Exec("INSERT INTO class_contacts(classId, name, role, email, userId) VALUES(?, ?, ?, ?, ?)", classID, name, role, email, c.User.ID)
+	if err != nil {
+		errorlog.LogError("adding class contact", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeClassesContactsEdit(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	name, role, email, ok := parseClassContactForm(w, r)
+	if !ok {
+		return
+	}
+
+	id, _, ok := getOwnedClassDetailID(w, r, c, "class_contacts", "editing class contact")
+	if !ok {
+		return
+	}
+
+	_, err := DB.Exec("UPDATE class_contacts SET name = ?, role = ?, email = ? WHERE id = ?", name, role, email, id)
+	if err != nil {
+		errorlog.LogError("editing class contact", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeClassesContactsDelete(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	id, _, ok := getOwnedClassDetailID(w, r, c, "class_contacts", "deleting class contact")
+	if !ok {
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("deleting class contact", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// office hours they held are kept, but aren't theirs anymore
+	_, err = tx.Exec("UPDATE class_office_hours SET contactId = NULL WHERE contactId = ?", id)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("deleting class contact", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	_, err = tx.Exec("DELETE FROM class_contacts WHERE id = ?", id)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("deleting class contact", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("deleting class contact", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeClassesOfficeHoursAdd(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	classID, ok := getOwnedClassID(w, r, c, "classId", "adding office hours")
+	if !ok {
+		return
+	}
+
+	contactID, dayOfWeek, start, end, location, ok := parseClassOfficeHoursForm(w, r, classID, "adding office hours")
+	if !ok {
+		return
+	}
+
+	_, err := DB.Exec(
+		"INSERT INTO class_office_hours(classId, contactId, dayOfWeek, `start`, `end`, location, userId) VALUES(?, ?, ?, ?, ?, ?, ?)",
+		classID, contactID, dayOfWeek, start, end, location, c.User.ID,
+	)
+	if err != nil {
+		errorlog.LogError("adding office hours", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeClassesOfficeHoursEdit(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	id, classID, ok := getOwnedClassDetailID(w, r, c, "class_office_hours", "editing office hours")
+	if !ok {
+		return
+	}
+
+	contactID, dayOfWeek, start, end, location, ok := parseClassOfficeHoursForm(w, r, classID, "editing office hours")
+	if !ok {
+		return
+	}
+
+	_, err := DB.Exec(
+		"UPDATE class_office_hours SET contactId = ?, dayOfWeek = ?, `start` = ?, `end` = ?, location = ? WHERE id = ?",
+		contactID, dayOfWeek, start, end, location, id,
+	)
+	if err != nil {
+		errorlog.LogError("editing office hours", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeClassesOfficeHoursDelete(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	id, _, ok := getOwnedClassDetailID(w, r, c, "class_office_hours", "deleting office hours")
+	if !ok {
+		return
+	}
+
+	_, err := DB.Exec("DELETE FROM class_office_hours WHERE id = ?", id)
+	if err != nil {
+		errorlog.LogError("deleting office hours", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
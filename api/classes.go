@@ -24,8 +24,43 @@ type hwInfoResponse struct {
 	HWItems int    `json:"hwItems"`
 }
 
+/*
+ * helpers
+ */
+
+// isClassOwnedByUser checks that the given class exists, belongs to the given user, and isn't in the trash.
+func isClassOwnedByUser(classID int, userID int) (bool, error) {
+	rows, err := DB.Query("SELECT id FROM classes WHERE userId = ? AND trashId IS NULL AND id = ?", userID, classID)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	return rows.Next(), nil
+}
+
+/*
+ * routes
+ */
+
 func routeClassesGet(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
-	classes, err := data.GetClassesForUser(c.User)
+	includeArchived := false
+	if r.FormValue("includeArchived") != "" {
+		var err error
+		includeArchived, err = strconv.ParseBool(r.FormValue("includeArchived"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+	}
+
+	var classes []data.HomeworkClass
+	var err error
+	if includeArchived {
+		classes, err = data.GetAllClassesForUser(c.User)
+	} else {
+		classes, err = data.GetClassesForUser(c.User)
+	}
 	if err != nil {
 		errorlog.LogError("getting list of classes", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -36,7 +71,7 @@ func routeClassesGet(w http.ResponseWriter, r *http.Request, p httprouter.Params
 }
 
 func routeClassesGetID(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
-	rows, err := DB.Query("SELECT id, name, teacher, color, sortIndex, userId FROM classes WHERE id = ? AND userId = ?", p.ByName("id"), c.User.ID)
+	rows, err := DB.Query("SELECT id, name, teacher, color, website, syllabus, meetingLink, sortIndex, userId, IFNULL(termId, -1), archived, IFNULL(schoolId, ''), IFNULL(schoolClassId, '') FROM classes WHERE id = ? AND userId = ? AND trashId IS NULL", p.ByName("id"), c.User.ID)
 	if err != nil {
 		errorlog.LogError("getting class information", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -49,19 +84,21 @@ func routeClassesGetID(w http.ResponseWriter, r *http.Request, p httprouter.Para
 		return
 	}
 
-	resp := data.HomeworkClass{-1, "", "", "", -1, -1}
-	err = rows.Scan(&resp.ID, &resp.Name, &resp.Teacher, &resp.Color, &resp.SortIndex, &resp.UserID)
+	resp := data.HomeworkClass{-1, "", "", "", "", "", "", -1, -1, -1, false, "", ""}
+	archivedInt := -1
+	err = rows.Scan(&resp.ID, &resp.Name, &resp.Teacher, &resp.Color, &resp.Website, &resp.Syllabus, &resp.MeetingLink, &resp.SortIndex, &resp.UserID, &resp.TermID, &archivedInt, &resp.SchoolID, &resp.SchoolClassID)
 	if err != nil {
 		errorlog.LogError("getting class information", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
+	resp.Archived = (archivedInt == 1)
 
 	writeJSON(w, http.StatusOK, singleClassResponse{"ok", resp})
 }
 
 func routeClassesHWInfo(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
-	rows, err := DB.Query("SELECT COUNT(*) FROM homework WHERE classId = ? AND userId = ?", p.ByName("id"), c.User.ID)
+	rows, err := DB.Query("SELECT COUNT(*) FROM homework WHERE classId = ? AND userId = ? AND trashId IS NULL", p.ByName("id"), c.User.ID)
 	if err != nil {
 		errorlog.LogError("getting class information", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -91,9 +128,10 @@ func routeClassesAdd(w http.ResponseWriter, r *http.Request, p httprouter.Params
 		return
 	}
 
-	_, err := DB.Exec(
-		"INSERT INTO classes(name, teacher, color, sortIndex, userId) VALUES(?, ?, ?, (SELECT * FROM (SELECT COUNT(*) FROM classes WHERE userId = ?) AS sortIndex), ?)",
-		r.FormValue("name"), r.FormValue("teacher"), r.FormValue("color"), c.User.ID, c.User.ID,
+	// new classes go in the current term, if there is one
+	result, err := DB.Exec(
+		"INSERT INTO classes(name, teacher, color, sortIndex, termId, userId) VALUES(?, ?, ?, (SELECT * FROM (SELECT COUNT(*) FROM classes WHERE userId = ? AND trashId IS NULL) AS sortIndex), (SELECT id FROM terms WHERE userId = ? ORDER BY startedAt DESC, id DESC LIMIT 1), ?)",
+		r.FormValue("name"), r.FormValue("teacher"), r.FormValue("color"), c.User.ID, c.User.ID, c.User.ID,
 	)
 	if err != nil {
 		errorlog.LogError("adding class", err)
@@ -101,6 +139,15 @@ func routeClassesAdd(w http.ResponseWriter, r *http.Request, p httprouter.Params
 		return
 	}
 
+	classID, err := result.LastInsertId()
+	if err != nil {
+		errorlog.LogError("adding class", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	queueClassWebhookEvent(c.User.ID, data.WebhookEventClassCreated, int(classID))
+
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
 
@@ -111,7 +158,7 @@ func routeClassesEdit(w http.ResponseWriter, r *http.Request, p httprouter.Param
 	}
 
 	// check if you are allowed to edit the given id
-	idRows, err := DB.Query("SELECT id FROM classes WHERE userId = ? AND id = ?", c.User.ID, r.FormValue("id"))
+	idRows, err := DB.Query("SELECT id FROM classes WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, r.FormValue("id"))
 	if err != nil {
 		errorlog.LogError("editing classes", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -133,6 +180,48 @@ func routeClassesEdit(w http.ResponseWriter, r *http.Request, p httprouter.Param
 		return
 	}
 
+	id, _ := strconv.Atoi(r.FormValue("id"))
+	queueClassWebhookEvent(c.User.ID, data.WebhookEventClassUpdated, id)
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeClassesSetArchived(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("id") == "" || r.FormValue("archived") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	archived, err := strconv.ParseBool(r.FormValue("archived"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	// check if you are allowed to edit the given id
+	idRows, err := DB.Query("SELECT id FROM classes WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, r.FormValue("id"))
+	if err != nil {
+		errorlog.LogError("setting class archived status", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	defer idRows.Close()
+	if !idRows.Next() {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
+
+	// archived classes keep their sortIndex, so that they go back to the same place if they're unarchived
+	_, err = DB.Exec("UPDATE classes SET archived = ? WHERE id = ?", archived, r.FormValue("id"))
+	if err != nil {
+		errorlog.LogError("setting class archived status", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	id, _ := strconv.Atoi(r.FormValue("id"))
+	queueClassWebhookEvent(c.User.ID, data.WebhookEventClassUpdated, id)
+
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
 
@@ -149,7 +238,7 @@ func routeClassesDelete(w http.ResponseWriter, r *http.Request, p httprouter.Par
 	}
 
 	// check if you are allowed to delete the given id
-	idRows, err := DB.Query("SELECT id FROM classes WHERE userId = ? AND id = ?", c.User.ID, id)
+	idRows, err := DB.Query("SELECT name FROM classes WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, id)
 	if err != nil {
 		errorlog.LogError("deleting classes", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -159,11 +248,13 @@ func routeClassesDelete(w http.ResponseWriter, r *http.Request, p httprouter.Par
 		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
 		return
 	}
+	name := ""
+	idRows.Scan(&name)
 	idRows.Close()
 
 	// get the user's current classes so that we can renumber the sort indices
 	currentClasses := []int{}
-	allIDRows, err := DB.Query("SELECT id FROM classes WHERE userId = ? ORDER BY sortIndex ASC", c.User.ID)
+	allIDRows, err := DB.Query("SELECT id FROM classes WHERE userId = ? AND trashId IS NULL ORDER BY sortIndex ASC", c.User.ID)
 	if err != nil {
 		errorlog.LogError("deleting classes", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -191,8 +282,8 @@ func routeClassesDelete(w http.ResponseWriter, r *http.Request, p httprouter.Par
 		return
 	}
 
-	// delete HW calendar events
-	_, err = tx.Exec("DELETE calendar_hwevents FROM calendar_hwevents INNER JOIN homework ON calendar_hwevents.homeworkId = homework.id WHERE homework.classId = ?", id)
+	// everything goes into the trash under the same entry, so that it can be restored together
+	trashID, err := data.AddToTrash(tx, data.TrashItemClass, id, name, c.User.ID)
 	if err != nil {
 		tx.Rollback()
 		errorlog.LogError("deleting class", err)
@@ -200,8 +291,8 @@ func routeClassesDelete(w http.ResponseWriter, r *http.Request, p httprouter.Par
 		return
 	}
 
-	// delete HW
-	_, err = tx.Exec("DELETE FROM homework WHERE classId = ?", id)
+	// trash HW calendar events
+	_, err = tx.Exec("UPDATE calendar_hwevents INNER JOIN homework ON calendar_hwevents.homeworkId = homework.id SET calendar_hwevents.trashId = ? WHERE homework.classId = ? AND homework.trashId IS NULL AND calendar_hwevents.trashId IS NULL", trashID, id)
 	if err != nil {
 		tx.Rollback()
 		errorlog.LogError("deleting class", err)
@@ -209,8 +300,17 @@ func routeClassesDelete(w http.ResponseWriter, r *http.Request, p httprouter.Par
 		return
 	}
 
-	// delete class
-	_, err = tx.Exec("DELETE FROM classes WHERE id = ?", id)
+	// trash HW
+	_, err = tx.Exec("UPDATE homework SET trashId = ? WHERE classId = ? AND trashId IS NULL", trashID, id)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("deleting class", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// trash class
+	_, err = tx.Exec("UPDATE classes SET trashId = ? WHERE id = ?", trashID, id)
 	if err != nil {
 		tx.Rollback()
 		errorlog.LogError("deleting class", err)
@@ -244,6 +344,8 @@ func routeClassesDelete(w http.ResponseWriter, r *http.Request, p httprouter.Par
 		return
 	}
 
+	queueWebhookEvent(c.User.ID, data.WebhookEventClassDeleted, webhookItem{id, name})
+
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
 
@@ -265,7 +367,7 @@ func routeClassesSwap(w http.ResponseWriter, r *http.Request, p httprouter.Param
 	}
 
 	// get class 1, check it's yours
-	class1Row, err := DB.Query("SELECT sortIndex FROM classes WHERE userId = ? AND id = ?", c.User.ID, id1)
+	class1Row, err := DB.Query("SELECT sortIndex FROM classes WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, id1)
 	if err != nil {
 		errorlog.LogError("deleting classes", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -281,7 +383,7 @@ func routeClassesSwap(w http.ResponseWriter, r *http.Request, p httprouter.Param
 	class1Row.Close()
 
 	// get class 2, check it's yours
-	class2Row, err := DB.Query("SELECT sortIndex FROM classes WHERE userId = ? AND id = ?", c.User.ID, id2)
+	class2Row, err := DB.Query("SELECT sortIndex FROM classes WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, id2)
 	if err != nil {
 		errorlog.LogError("deleting classes", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -325,5 +427,8 @@ func routeClassesSwap(w http.ResponseWriter, r *http.Request, p httprouter.Param
 		return
 	}
 
+	queueClassWebhookEvent(c.User.ID, data.WebhookEventClassUpdated, id1)
+	queueClassWebhookEvent(c.User.ID, data.WebhookEventClassUpdated, id2)
+
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/auth"
+	"github.com/MyHomeworkSpace/api-server/config"
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// responses
+type digestSettingsResponse struct {
+	Status   string              `json:"status"`
+	Settings data.DigestSettings `json:"settings"`
+}
+
+/*
+ * helpers
+ */
+
+// getDigestSettings returns the user's DigestSettings, or the defaults if they don't have any yet.
+func getDigestSettings(user *data.User) (data.DigestSettings, error) {
+	settings, err := data.GetDigestSettingsForUser(user.ID)
+	if err == data.ErrNotFound {
+		settings = data.DefaultDigestSettings
+		settings.UserID = user.ID
+		return settings, nil
+	}
+
+	return settings, err
+}
+
+/*
+ * routes
+ */
+
+func routeDigestGetSettings(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	settings, err := getDigestSettings(c.User)
+	if err != nil {
+		errorlog.LogError("getting digest settings", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, digestSettingsResponse{"ok", settings})
+}
+
+func routeDigestSetSettings(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("daily") == "" || r.FormValue("weekly") == "" || r.FormValue("weeklyDay") == "" || r.FormValue("sendTime") == "" || r.FormValue("timezone") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	daily, err := strconv.ParseBool(r.FormValue("daily"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	weekly, err := strconv.ParseBool(r.FormValue("weekly"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	weeklyDay, err := strconv.Atoi(r.FormValue("weeklyDay"))
+	if err != nil || weeklyDay < 0 || weeklyDay > 6 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	sendTime, err := strconv.Atoi(r.FormValue("sendTime"))
+	if err != nil || sendTime < 0 || sendTime >= 24*60 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	_, err = time.LoadLocation(r.FormValue("timezone"))
+	if err != nil || len(r.FormValue("timezone")) > 64 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	if (daily || weekly) && !c.User.EmailVerified {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "email_not_verified"})
+		return
+	}
+
+	settings, err := getDigestSettings(c.User)
+	if err != nil {
+		errorlog.LogError("setting digest settings", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	if settings.UnsubscribeToken == "" {
+		settings.UnsubscribeToken, err = auth.GenerateRandomString(32)
+		if err != nil {
+			errorlog.LogError("setting digest settings", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+	}
+
+	settings.Daily = daily
+	settings.Weekly = weekly
+	settings.WeeklyDay = time.Weekday(weeklyDay)
+	settings.SendTime = sendTime
+	settings.Timezone = r.FormValue("timezone")
+
+	err = data.SaveDigestSettings(settings)
+	if err != nil {
+		errorlog.LogError("setting digest settings", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+// routeDigestUnsubscribeConfirm is where the link in a digest goes. Email scanners open links to check them, so this doesn't change anything, and sends the user to a page where they can confirm instead.
+func routeDigestUnsubscribeConfirm(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	_, err := data.GetDigestSettingsByUnsubscribeToken(p.ByName("token"))
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusNotFound, errorResponse{"error", "not_found"})
+		return
+	} else if err != nil {
+		errorlog.LogError("unsubscribing from digest", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	http.Redirect(w, r, config.GetCurrent().Server.AppURLBase+"digestUnsubscribe?token="+url.QueryEscape(p.ByName("token")), http.StatusFound)
+}
+
+// routeDigestUnsubscribe is used by the confirmation page, and by email clients that support one-click unsubscribing.
+func routeDigestUnsubscribe(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	settings, err := data.GetDigestSettingsByUnsubscribeToken(p.ByName("token"))
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusNotFound, errorResponse{"error", "not_found"})
+		return
+	} else if err != nil {
+		errorlog.LogError("unsubscribing from digest", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	settings.Daily = false
+	settings.Weekly = false
+
+	err = data.SaveDigestSettings(settings)
+	if err != nil {
+		errorlog.LogError("unsubscribing from digest", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
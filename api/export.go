@@ -0,0 +1,268 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// A transferItem is a single row in an export or import file.
+type transferItem interface {
+	csvRecord() []string
+}
+
+type transferHomework struct {
+	Name     string `json:"name"`
+	Due      string `json:"due"`
+	Desc     string `json:"desc"`
+	Complete bool   `json:"complete"`
+	Class    string `json:"class"`
+}
+type transferClass struct {
+	Name    string `json:"name"`
+	Teacher string `json:"teacher"`
+	Color   string `json:"color"`
+}
+type transferEvent struct {
+	Name            string `json:"name"`
+	Start           int    `json:"start"`
+	End             int    `json:"end"`
+	Location        string `json:"location"`
+	Desc            string `json:"desc"`
+	Recur           bool   `json:"recur"`
+	RecurFrequency  int    `json:"recurFrequency"`
+	RecurInterval   int    `json:"recurInterval"`
+	RecurUntil      string `json:"recurUntil"`
+	RecurByDay      string `json:"recurByDay"`
+	RecurByMonthDay int    `json:"recurByMonthDay"`
+	RecurByMonth    int    `json:"recurByMonth"`
+}
+type transferPrefix struct {
+	Name       string   `json:"name"`
+	Words      []string `json:"words"`
+	Color      string   `json:"color"`
+	Background string   `json:"background"`
+	TimedEvent bool     `json:"timedEvent"`
+	Regex      bool     `json:"regex"`
+	Priority   int      `json:"priority"`
+}
+
+// the columns used in csv files, in the same order as csvRecord
+var transferColumns = map[string][]string{
+	"homework": {"name", "due", "desc", "complete", "class"},
+	"classes":  {"name", "teacher", "color"},
+	"events":   {"name", "start", "end", "location", "desc", "recur", "recurFrequency", "recurInterval", "recurUntil", "recurByDay", "recurByMonthDay", "recurByMonth"},
+	"prefixes": {"name", "words", "color", "background", "timedEvent", "regex", "priority"},
+}
+
+func (h transferHomework) csvRecord() []string {
+	return []string{h.Name, h.Due, h.Desc, strconv.FormatBool(h.Complete), h.Class}
+}
+
+func (c transferClass) csvRecord() []string {
+	return []string{c.Name, c.Teacher, c.Color}
+}
+
+func (e transferEvent) csvRecord() []string {
+	return []string{
+		e.Name, strconv.Itoa(e.Start), strconv.Itoa(e.End), e.Location, e.Desc,
+		strconv.FormatBool(e.Recur), strconv.Itoa(e.RecurFrequency), strconv.Itoa(e.RecurInterval), e.RecurUntil,
+		e.RecurByDay, strconv.Itoa(e.RecurByMonthDay), strconv.Itoa(e.RecurByMonth),
+	}
+}
+
+func (p transferPrefix) csvRecord() []string {
+	// plain words are separated by spaces, but phrases and patterns can contain spaces themselves, so those are written as a json array
+	words := strings.Join(p.Words, " ")
+	if p.Regex || len(strings.Fields(words)) != len(p.Words) {
+		wordsJSON, _ := json.Marshal(p.Words)
+		words = string(wordsJSON)
+	}
+
+	return []string{p.Name, words, p.Color, p.Background, strconv.FormatBool(p.TimedEvent), strconv.FormatBool(p.Regex), strconv.Itoa(p.Priority)}
+}
+
+/*
+ * helpers
+ */
+
+func getHomeworkForExport(userID int) ([]transferItem, error) {
+	rows, err := DB.Query(
+		"SELECT homework.name, homework.`due`, homework.`desc`, homework.`complete`, classes.name FROM homework INNER JOIN classes ON homework.classId = classes.id WHERE homework.userId = ? AND homework.trashId IS NULL AND classes.trashId IS NULL ORDER BY homework.`due` ASC, homework.id ASC",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []transferItem{}
+	for rows.Next() {
+		item := transferHomework{}
+		complete := 0
+		err = rows.Scan(&item.Name, &item.Due, &item.Desc, &complete, &item.Class)
+		if err != nil {
+			return nil, err
+		}
+		item.Complete = (complete == 1)
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func getClassesForExport(userID int) ([]transferItem, error) {
+	rows, err := DB.Query("SELECT name, teacher, color FROM classes WHERE userId = ? AND trashId IS NULL ORDER BY sortIndex ASC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []transferItem{}
+	for rows.Next() {
+		item := transferClass{}
+		err = rows.Scan(&item.Name, &item.Teacher, &item.Color)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func getEventsForExport(userID int) ([]transferItem, error) {
+	rows, err := DB.Query(
+		"SELECT calendar_events.name, calendar_events.`start`, calendar_events.`end`, calendar_events.location, calendar_events.`desc`, IFNULL(calendar_event_rules.frequency, -1), IFNULL(calendar_event_rules.interval, 0), IFNULL(calendar_event_rules.until, ''), "+
+			"IFNULL(calendar_event_rules.byDay, ''), IFNULL(calendar_event_rules.byMonthDay, 0), IFNULL(calendar_event_rules.byMonth, 0) FROM calendar_events "+
+			"LEFT JOIN calendar_event_rules ON calendar_events.id = calendar_event_rules.eventId "+
+			"WHERE calendar_events.userId = ? AND calendar_events.trashId IS NULL ORDER BY calendar_events.`start` ASC, calendar_events.id ASC",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []transferItem{}
+	for rows.Next() {
+		item := transferEvent{}
+		err = rows.Scan(&item.Name, &item.Start, &item.End, &item.Location, &item.Desc, &item.RecurFrequency, &item.RecurInterval, &item.RecurUntil, &item.RecurByDay, &item.RecurByMonthDay, &item.RecurByMonth)
+		if err != nil {
+			return nil, err
+		}
+
+		if item.RecurFrequency == -1 {
+			item.RecurFrequency = 0
+		} else {
+			item.Recur = true
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func getPrefixesForExport(userID int) ([]transferItem, error) {
+	// class scopes aren't exported, since class IDs only make sense for this account
+	rows, err := DB.Query("SELECT name, words, color, background, isTimedEvent, isRegex, priority FROM prefixes WHERE userId = ? ORDER BY id ASC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []transferItem{}
+	for rows.Next() {
+		item := transferPrefix{}
+		wordsListString := ""
+		timedEventInt := -1
+		regexInt := -1
+		err = rows.Scan(&item.Name, &wordsListString, &item.Color, &item.Background, &timedEventInt, &regexInt, &item.Priority)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal([]byte(wordsListString), &item.Words)
+		if err != nil {
+			return nil, err
+		}
+
+		item.TimedEvent = (timedEventInt == 1)
+		item.Regex = (regexInt == 1)
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+/*
+ * routes
+ */
+
+func routeExport(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	exportType := p.ByName("type")
+	format := r.FormValue("format")
+	if format == "" {
+		format = "json"
+	}
+
+	if format != "json" && format != "csv" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	var items []transferItem
+	var err error
+	if exportType == "homework" {
+		items, err = getHomeworkForExport(c.User.ID)
+	} else if exportType == "classes" {
+		items, err = getClassesForExport(c.User.ID)
+	} else if exportType == "events" {
+		items, err = getEventsForExport(c.User.ID)
+	} else if exportType == "prefixes" {
+		items, err = getPrefixesForExport(c.User.ID)
+	} else {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+	if err != nil {
+		errorlog.LogError("exporting "+exportType, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+exportType+"."+format+"\"")
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(items)
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+
+	csvWriter := csv.NewWriter(w)
+	csvWriter.Write(transferColumns[exportType])
+	for _, item := range items {
+		csvWriter.Write(item.csvRecord())
+	}
+	csvWriter.Flush()
+	err = csvWriter.Error()
+	if err != nil {
+		// it's too late to tell the client, since the status has already been sent
+		errorlog.LogError("exporting "+exportType, err)
+	}
+}
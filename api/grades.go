@@ -0,0 +1,469 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+	"github.com/MyHomeworkSpace/api-server/grades"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// responses
+type gradesResponse struct {
+	Status     string               `json:"status"`
+	Categories []data.GradeCategory `json:"categories"`
+	Items      []data.GradeItem     `json:"items"`
+	Summary    grades.Summary       `json:"summary"`
+}
+type gradeSummariesResponse struct {
+	Status    string                 `json:"status"`
+	Summaries map[int]grades.Summary `json:"summaries"`
+}
+type gradeProjectionResponse struct {
+	Status     string            `json:"status"`
+	Projection grades.Projection `json:"projection"`
+}
+
+/*
+ * helpers
+ */
+
+func parseGradeNumber(value string) (float64, bool) {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) || number < 0 {
+		return 0, false
+	}
+
+	return number, true
+}
+
+func getGradeCategoryFromForm(w http.ResponseWriter, r *http.Request, c RouteContext, formKey string, action string) (data.GradeCategory, bool) {
+	if r.FormValue(formKey) == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return data.GradeCategory{}, false
+	}
+
+	id, err := strconv.Atoi(r.FormValue(formKey))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return data.GradeCategory{}, false
+	}
+
+	category, err := data.GetGradeCategoryByID(id)
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return data.GradeCategory{}, false
+	} else if err != nil {
+		errorlog.LogError(action, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return data.GradeCategory{}, false
+	}
+
+	owned, err := isClassOwnedByUser(category.ClassID, c.User.ID)
+	if err != nil {
+		errorlog.LogError(action, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return data.GradeCategory{}, false
+	}
+	if !owned {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return data.GradeCategory{}, false
+	}
+
+	return category, true
+}
+
+func getGradeItemFromForm(w http.ResponseWriter, r *http.Request, c RouteContext, formKey string, action string) (data.GradeItem, bool) {
+	if r.FormValue(formKey) == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return data.GradeItem{}, false
+	}
+
+	id, err := strconv.Atoi(r.FormValue(formKey))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return data.GradeItem{}, false
+	}
+
+	item, err := data.GetGradeItemByID(id)
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return data.GradeItem{}, false
+	} else if err != nil {
+		errorlog.LogError(action, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return data.GradeItem{}, false
+	}
+
+	owned, err := isClassOwnedByUser(item.ClassID, c.User.ID)
+	if err != nil {
+		errorlog.LogError(action, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return data.GradeItem{}, false
+	}
+	if !owned {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return data.GradeItem{}, false
+	}
+
+	return item, true
+}
+
+// parseGradeItemForm reads the fields shared by adding and editing a GradeItem. The score and homework ID are nil if they weren't given.
+func parseGradeItemForm(w http.ResponseWriter, r *http.Request, c RouteContext, action string) (string, *float64, float64, *int, bool) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" || r.FormValue("maxScore") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return "", nil, 0, nil, false
+	}
+
+	maxScore, ok := parseGradeNumber(r.FormValue("maxScore"))
+	if !ok || maxScore == 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return "", nil, 0, nil, false
+	}
+
+	// no score means it hasn't been graded yet
+	var score *float64
+	if r.FormValue("score") != "" {
+		scoreNumber, ok := parseGradeNumber(r.FormValue("score"))
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return "", nil, 0, nil, false
+		}
+		score = &scoreNumber
+	}
+
+	var homeworkID *int
+	if r.FormValue("homeworkId") != "" {
+		homeworkIDInt, err := strconv.Atoi(r.FormValue("homeworkId"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return "", nil, 0, nil, false
+		}
+
+		// check if you are allowed to use the given homework
+		homeworkRows, err := DB.Query("SELECT id FROM homework WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, homeworkIDInt)
+		if err != nil {
+			errorlog.LogError(action, err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return "", nil, 0, nil, false
+		}
+		defer homeworkRows.Close()
+		if !homeworkRows.Next() {
+			writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+			return "", nil, 0, nil, false
+		}
+
+		homeworkID = &homeworkIDInt
+	}
+
+	return name, score, maxScore, homeworkID, true
+}
+
+/*
+ * routes
+ */
+
+func routeGradesGet(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	classID, err := strconv.Atoi(p.ByName("classId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	owned, err := isClassOwnedByUser(classID, c.User.ID)
+	if err != nil {
+		errorlog.LogError("getting grades", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	if !owned {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
+
+	categories, err := data.GetGradeCategoriesForClass(classID)
+	if err != nil {
+		errorlog.LogError("getting grades", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	items, err := data.GetGradeItemsForClass(classID)
+	if err != nil {
+		errorlog.LogError("getting grades", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, gradesResponse{"ok", categories, items, grades.Calculate(categories, items)})
+}
+
+func routeGradesGetSummaries(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	categories, err := data.GetGradeCategoriesForUser(c.User.ID)
+	if err != nil {
+		errorlog.LogError("getting grade summaries", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	items, err := data.GetGradeItemsForUser(c.User.ID)
+	if err != nil {
+		errorlog.LogError("getting grade summaries", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	categoriesByClass := map[int][]data.GradeCategory{}
+	for _, category := range categories {
+		categoriesByClass[category.ClassID] = append(categoriesByClass[category.ClassID], category)
+	}
+
+	itemsByClass := map[int][]data.GradeItem{}
+	for _, item := range items {
+		itemsByClass[item.ClassID] = append(itemsByClass[item.ClassID], item)
+	}
+
+	summaries := map[int]grades.Summary{}
+	for classID, classCategories := range categoriesByClass {
+		summaries[classID] = grades.Calculate(classCategories, itemsByClass[classID])
+	}
+
+	writeJSON(w, http.StatusOK, gradeSummariesResponse{"ok", summaries})
+}
+
+func routeGradesProject(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("target") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	target, ok := parseGradeNumber(r.FormValue("target"))
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	item, ok := getGradeItemFromForm(w, r, c, "itemId", "projecting grade")
+	if !ok {
+		return
+	}
+
+	categories, err := data.GetGradeCategoriesForClass(item.ClassID)
+	if err != nil {
+		errorlog.LogError("projecting grade", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	items, err := data.GetGradeItemsForClass(item.ClassID)
+	if err != nil {
+		errorlog.LogError("projecting grade", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	projection, err := grades.Project(categories, items, item.ID, target)
+	if err == grades.ErrNoEffect {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "item_has_no_effect"})
+		return
+	} else if err != nil {
+		errorlog.LogError("projecting grade", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, gradeProjectionResponse{"ok", projection})
+}
+
+func routeGradesCategoriesAdd(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	if r.FormValue("classId") == "" || name == "" || r.FormValue("weight") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	classID, err := strconv.Atoi(r.FormValue("classId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	weight, ok := parseGradeNumber(r.FormValue("weight"))
+	if !ok || len(name) > 64 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	owned, err := isClassOwnedByUser(classID, c.User.ID)
+	if err != nil {
+		errorlog.LogError("adding grade category", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	if !owned {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
+
+	_, err = DB.Exec(
+		"INSERT INTO grade_categories(classId, name, weight, userId) VALUES(?, ?, ?, ?)",
+		classID, name, weight, c.User.ID,
+	)
+	if err != nil {
+		errorlog.LogError("adding grade category", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeGradesCategoriesEdit(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" || r.FormValue("weight") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	weight, ok := parseGradeNumber(r.FormValue("weight"))
+	if !ok || len(name) > 64 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	category, ok := getGradeCategoryFromForm(w, r, c, "id", "editing grade category")
+	if !ok {
+		return
+	}
+
+	_, err := DB.Exec("UPDATE grade_categories SET name = ?, weight = ? WHERE id = ?", name, weight, category.ID)
+	if err != nil {
+		errorlog.LogError("editing grade category", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeGradesCategoriesDelete(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	category, ok := getGradeCategoryFromForm(w, r, c, "id", "deleting grade category")
+	if !ok {
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("deleting grade category", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// the category's items go with it
+	_, err = tx.Exec("DELETE FROM grade_items WHERE categoryId = ?", category.ID)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("deleting grade category", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	_, err = tx.Exec("DELETE FROM grade_categories WHERE id = ?", category.ID)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("deleting grade category", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("deleting grade category", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeGradesItemsAdd(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	name, score, maxScore, homeworkID, ok := parseGradeItemForm(w, r, c, "adding grade item")
+	if !ok {
+		return
+	}
+
+	category, ok := getGradeCategoryFromForm(w, r, c, "categoryId", "adding grade item")
+	if !ok {
+		return
+	}
+
+	_, err := DB.Exec(
+		"INSERT INTO grade_items(categoryId, classId, homeworkId, name, score, maxScore, userId) VALUES(?, ?, ?, ?, ?, ?, ?)",
+		category.ID, category.ClassID, homeworkID, name, score, maxScore, c.User.ID,
+	)
+	if err != nil {
+		errorlog.LogError("adding grade item", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeGradesItemsEdit(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	name, score, maxScore, homeworkID, ok := parseGradeItemForm(w, r, c, "editing grade item")
+	if !ok {
+		return
+	}
+
+	item, ok := getGradeItemFromForm(w, r, c, "id", "editing grade item")
+	if !ok {
+		return
+	}
+
+	category, ok := getGradeCategoryFromForm(w, r, c, "categoryId", "editing grade item")
+	if !ok {
+		return
+	}
+
+	// items can only move between categories in the same class
+	if category.ClassID != item.ClassID {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	_, err := DB.Exec(
+		"UPDATE grade_items SET categoryId = ?, homeworkId = ?, name = ?, score = ?, maxScore = ? WHERE id = ?",
+		category.ID, homeworkID, name, score, maxScore, item.ID,
+	)
+	if err != nil {
+		errorlog.LogError("editing grade item", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeGradesItemsDelete(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	item, ok := getGradeItemFromForm(w, r, c, "id", "deleting grade item")
+	if !ok {
+		return
+	}
+
+	_, err := DB.Exec("DELETE FROM grade_items WHERE id = ?", item.ID)
+	if err != nil {
+		errorlog.LogError("deleting grade item", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/MyHomeworkSpace/api-server/data"
@@ -35,7 +34,8 @@ type singleHomeworkResponse struct {
 }
 
 func routeHomeworkGet(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
-	rows, err := DB.Query("SELECT id, name, `due`, `desc`, `complete`, classId, userId FROM homework WHERE userId = ? ORDER BY `due` ASC", c.User.ID)
+	// homework in archived classes is left out, since the classes themselves aren't in the class list
+	rows, err := DB.Query("SELECT homework.id, homework.name, homework.`due`, homework.`desc`, homework.`complete`, homework.classId, homework.userId FROM homework INNER JOIN classes ON homework.classId = classes.id WHERE homework.userId = ? AND homework.trashId IS NULL AND classes.archived = 0 ORDER BY homework.`due` ASC", c.User.ID)
 	if err != nil {
 		errorlog.LogError("getting homework information", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -66,7 +66,7 @@ func routeHomeworkGetForClass(w http.ResponseWriter, r *http.Request, p httprout
 		return
 	}
 
-	classRows, err := DB.Query("SELECT id FROM classes WHERE id = ? AND userId = ?", classID, c.User.ID)
+	classRows, err := DB.Query("SELECT id FROM classes WHERE id = ? AND userId = ? AND trashId IS NULL", classID, c.User.ID)
 	if err != nil {
 		errorlog.LogError("getting homework for class", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -80,7 +80,7 @@ func routeHomeworkGetForClass(w http.ResponseWriter, r *http.Request, p httprout
 	}
 
 	// actually get the homework
-	rows, err := DB.Query("SELECT id, name, `due`, `desc`, `complete`, classId, userId FROM homework WHERE classId = ? AND userId = ? ORDER BY `due` ASC, id ASC", classID, c.User.ID)
+	rows, err := DB.Query("SELECT id, name, `due`, `desc`, `complete`, classId, userId FROM homework WHERE classId = ? AND userId = ? AND trashId IS NULL ORDER BY `due` ASC, id ASC", classID, c.User.ID)
 	if err != nil {
 		errorlog.LogError("getting homework for class", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -112,7 +112,7 @@ func routeHomeworkGetHWView(w http.ResponseWriter, r *http.Request, p httprouter
 		}
 	}
 
-	rows, err := DB.Query("SELECT id, name, `due`, `desc`, `complete`, classId, userId FROM homework WHERE userId = ? AND (`due` > (NOW() - INTERVAL 2 DAY) OR `complete` != '1') ORDER BY `due` ASC", c.User.ID)
+	rows, err := DB.Query("SELECT homework.id, homework.name, homework.`due`, homework.`desc`, homework.`complete`, homework.classId, homework.userId FROM homework INNER JOIN classes ON homework.classId = classes.id WHERE homework.userId = ? AND homework.trashId IS NULL AND classes.archived = 0 AND (homework.`due` > (NOW() - INTERVAL 2 DAY) OR homework.`complete` != '1') ORDER BY homework.`due` ASC", c.User.ID)
 	if err != nil {
 		errorlog.LogError("getting homework information", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -143,115 +143,60 @@ func routeHomeworkGetHWViewSorted(w http.ResponseWriter, r *http.Request, p http
 	}
 
 	// look for hidden class pref
-	hiddenPref, err := data.GetPrefForUser("homeworkHiddenClasses", c.User.ID)
-	hiddenClasses := []int{}
-	if err != nil && err != data.ErrNotFound {
-		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
-		return
-	} else if err == nil {
-		err = json.Unmarshal([]byte(hiddenPref.Value), &hiddenClasses)
-		if err != nil {
-			// just ignore the error
-			hiddenClasses = []int{}
-		}
-	}
-
-	rows, err := DB.Query("SELECT id, name, `due`, `desc`, `complete`, classId, userId FROM homework WHERE userId = ? AND (`due` > (NOW() - INTERVAL 3 DAY) OR `complete` != '1') ORDER BY `due` ASC", c.User.ID)
+	hiddenClasses, err := data.GetHiddenClassesForUser(c.User.ID)
 	if err != nil {
-		errorlog.LogError("getting homework view", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
-	defer rows.Close()
-
-	overdue := []data.Homework{}
-	today := []data.Homework{}
-	tomorrowName := "Tomorrow"
-	tomorrow := []data.Homework{}
-	soon := []data.Homework{}
-	longterm := []data.Homework{}
 
-	tomorrowTimeToThreshold := 24 * time.Hour
-
-	location, err := time.LoadLocation("America/New_York")
+	rows, err := DB.Query("SELECT homework.id, homework.name, homework.`due`, homework.`desc`, homework.`complete`, homework.classId, homework.userId FROM homework INNER JOIN classes ON homework.classId = classes.id WHERE homework.userId = ? AND homework.trashId IS NULL AND classes.archived = 0 AND (homework.`due` > (NOW() - INTERVAL 3 DAY) OR homework.`complete` != '1') ORDER BY homework.`due` ASC", c.User.ID)
 	if err != nil {
 		errorlog.LogError("getting homework view", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
-	now := time.Now().In(location)
-
-	if now.Weekday() == time.Friday || now.Weekday() == time.Saturday {
-		tomorrowName = "Monday"
-		if now.Weekday() == time.Friday {
-			tomorrowTimeToThreshold = 3 * 24 * time.Hour
-		} else {
-			tomorrowTimeToThreshold = 2 * 24 * time.Hour
-		}
-	}
-
-	if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
-		showToday = false
-	}
+	defer rows.Close()
 
+	homework := []data.Homework{}
 	for rows.Next() {
 		resp := data.Homework{}
 		rows.Scan(&resp.ID, &resp.Name, &resp.Due, &resp.Desc, &resp.Complete, &resp.ClassID, &resp.UserID)
-		dueDate, err := time.ParseInLocation("2006-01-02", resp.Due, location)
-		if err != nil {
-			errorlog.LogError("getting homework view", err)
-			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
-			return
-		}
 
 		if util.IntSliceContains(hiddenClasses, resp.ClassID) {
 			continue
 		}
 
-		timeUntilDue := dueDate.Sub(now)
-		if timeUntilDue < 0 {
-			if timeUntilDue > 0-(24*time.Hour) {
-				// it's in the today column
-				today = append(today, resp)
-			} else if resp.Complete == 0 {
-				// it's overdue
-				overdue = append(overdue, resp)
-			}
-		} else if timeUntilDue <= tomorrowTimeToThreshold {
-			// it's in the tomorrow column
-			tomorrow = append(tomorrow, resp)
-		} else if timeUntilDue <= 5*24*time.Hour {
-			// it's in the soon column
-			soon = append(soon, resp)
-		} else {
-			// it's in the longterm column
-			longterm = append(longterm, resp)
-		}
+		homework = append(homework, resp)
 	}
 
-	if !showToday {
-		for _, item := range today {
-			if item.Complete == 0 {
-				overdue = append(overdue, item)
-			}
-		}
-		today = []data.Homework{}
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		errorlog.LogError("getting homework view", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	view, err := data.SortHomeworkView(homework, time.Now().In(location), showToday)
+	if err != nil {
+		errorlog.LogError("getting homework view", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
 	}
 
 	writeJSON(w, http.StatusOK, hwViewResponse{
 		"ok",
-		tomorrowName,
-		showToday,
-		overdue,
-		today,
-		tomorrow,
-		soon,
-		longterm,
+		view.TomorrowName,
+		view.ShowToday,
+		view.Overdue,
+		view.Today,
+		view.Tomorrow,
+		view.Soon,
+		view.Longterm,
 	})
 }
 
 func routeHomeworkGetID(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
-	rows, err := DB.Query("SELECT id, name, `due`, `desc`, `complete`, classId, userId FROM homework WHERE userId = ? AND id = ?", c.User.ID, p.ByName("id"))
+	rows, err := DB.Query("SELECT id, name, `due`, `desc`, `complete`, classId, userId FROM homework WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, p.ByName("id"))
 	if err != nil {
 		errorlog.LogError("getting homework information", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -278,7 +223,7 @@ func routeHomeworkGetWeek(w http.ResponseWriter, r *http.Request, p httprouter.P
 	}
 	endDate := startDate.Add(time.Hour * 24 * 7)
 
-	rows, err := DB.Query("SELECT id, name, `due`, `desc`, `complete`, classId, userId FROM homework WHERE userId = ? AND (due >= ? and due < ?)", c.User.ID, startDate, endDate)
+	rows, err := DB.Query("SELECT homework.id, homework.name, homework.`due`, homework.`desc`, homework.`complete`, homework.classId, homework.userId FROM homework INNER JOIN classes ON homework.classId = classes.id WHERE homework.userId = ? AND homework.trashId IS NULL AND classes.archived = 0 AND (homework.due >= ? and homework.due < ?)", c.User.ID, startDate, endDate)
 	if err != nil {
 		errorlog.LogError("getting homework information", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -297,7 +242,8 @@ func routeHomeworkGetWeek(w http.ResponseWriter, r *http.Request, p httprouter.P
 
 func routeHomeworkGetPickerSuggestions(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
 	rows, err := DB.Query(
-		"SELECT id, name, `due`, `desc`, `complete`, classId, userId FROM homework WHERE userId = ? AND due >= ? AND id NOT IN (SELECT homework.id FROM homework INNER JOIN calendar_hwevents ON calendar_hwevents.homeworkId = homework.id) ORDER BY `due` ASC",
+		"SELECT homework.id, homework.name, homework.`due`, homework.`desc`, homework.`complete`, homework.classId, homework.userId FROM homework INNER JOIN classes ON homework.classId = classes.id "+
+			"WHERE homework.userId = ? AND homework.trashId IS NULL AND classes.archived = 0 AND homework.due >= ? AND homework.id NOT IN (SELECT homework.id FROM homework INNER JOIN calendar_hwevents ON calendar_hwevents.homeworkId = homework.id) ORDER BY homework.`due` ASC",
 		c.User.ID,
 		time.Now().Format("2006-01-02"),
 	)
@@ -317,70 +263,88 @@ func routeHomeworkGetPickerSuggestions(w http.ResponseWriter, r *http.Request, p
 	writeJSON(w, http.StatusOK, homeworkResponse{"ok", homework})
 }
 
-func routeHomeworkSearch(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
-	if r.FormValue("q") == "" {
+func routeHomeworkAdd(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("name") == "" || r.FormValue("due") == "" || r.FormValue("complete") == "" || r.FormValue("classId") == "" {
 		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
 		return
 	}
+	if r.FormValue("complete") != "0" && r.FormValue("complete") != "1" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
 
-	query := r.FormValue("q")
-
-	// sanitize the query, see https://githubengineering.com/like-injection/ for details
-	query = strings.Replace(query, "\\", "\\\\", -1)
-	query = strings.Replace(query, "%", "\\%", -1)
-	query = strings.Replace(query, "_", "\\_", -1)
-	query = "%" + query + "%"
-
-	rows, err := DB.Query("SELECT id, name, `due`, `desc`, `complete`, classId, userId FROM homework WHERE userId = ? AND (`name` LIKE ? OR `desc` LIKE ?) ORDER BY `due` DESC", c.User.ID, query, query)
+	// check if you are allowed to add to the given classId
+	rows, err := DB.Query("SELECT id FROM classes WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, r.FormValue("classId"))
 	if err != nil {
-		errorlog.LogError("getting homework search results", err)
+		errorlog.LogError("adding homework", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 	defer rows.Close()
+	if !rows.Next() {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
 
-	homework := []data.Homework{}
-	for rows.Next() {
-		resp := data.Homework{}
-		rows.Scan(&resp.ID, &resp.Name, &resp.Due, &resp.Desc, &resp.Complete, &resp.ClassID, &resp.UserID)
-		homework = append(homework, resp)
+	var completedAt *int64
+	if r.FormValue("complete") == "1" {
+		now := time.Now().Unix()
+		completedAt = &now
 	}
-	writeJSON(w, http.StatusOK, homeworkResponse{"ok", homework})
-}
 
-func routeHomeworkAdd(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
-	if r.FormValue("name") == "" || r.FormValue("due") == "" || r.FormValue("complete") == "" || r.FormValue("classId") == "" {
-		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("adding homework", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
-	if r.FormValue("complete") != "0" && r.FormValue("complete") != "1" {
-		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+
+	result, err := tx.Exec(
+		"INSERT INTO homework(name, `due`, `desc`, `complete`, completedAt, classId, userId) VALUES(?, ?, ?, ?, ?, ?, ?)",
+		r.FormValue("name"), r.FormValue("due"), r.FormValue("desc"), r.FormValue("complete"), completedAt, r.FormValue("classId"), c.User.ID,
+	)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("adding homework", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
-	// check if you are allowed to add to the given classId
-	rows, err := DB.Query("SELECT id FROM classes WHERE userId = ? AND id = ?", c.User.ID, r.FormValue("classId"))
+	homeworkID, err := result.LastInsertId()
 	if err != nil {
+		tx.Rollback()
 		errorlog.LogError("adding homework", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
-	defer rows.Close()
-	if !rows.Next() {
-		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+
+	classID, _ := strconv.Atoi(r.FormValue("classId"))
+
+	// give a copy to anyone this class is shared with
+	copies, err := data.ShareNewHomework(tx, data.Homework{
+		ID:      int(homeworkID),
+		Name:    r.FormValue("name"),
+		Due:     r.FormValue("due"),
+		Desc:    r.FormValue("desc"),
+		ClassID: classID,
+	})
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("adding homework", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
-	_, err = DB.Exec(
-		"INSERT INTO homework(name, `due`, `desc`, `complete`, classId, userId) VALUES(?, ?, ?, ?, ?, ?)",
-		r.FormValue("name"), r.FormValue("due"), r.FormValue("desc"), r.FormValue("complete"), r.FormValue("classId"), c.User.ID,
-	)
+	err = tx.Commit()
 	if err != nil {
 		errorlog.LogError("adding homework", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
+	queueHomeworkWebhookEvent(c.User.ID, data.WebhookEventHomeworkCreated, int(homeworkID))
+	queueHomeworkWebhookEvents(data.WebhookEventHomeworkCreated, copies)
+
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
 
@@ -395,7 +359,7 @@ func routeHomeworkEdit(w http.ResponseWriter, r *http.Request, p httprouter.Para
 	}
 
 	// check if you are allowed to edit the given id
-	idRows, err := DB.Query("SELECT id FROM homework WHERE userId = ? AND id = ?", c.User.ID, r.FormValue("id"))
+	idRows, err := DB.Query("SELECT `complete`, classId FROM homework WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, r.FormValue("id"))
 	if err != nil {
 		errorlog.LogError("editing homework", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -407,8 +371,12 @@ func routeHomeworkEdit(w http.ResponseWriter, r *http.Request, p httprouter.Para
 		return
 	}
 
+	wasComplete := -1
+	oldClassID := -1
+	idRows.Scan(&wasComplete, &oldClassID)
+
 	// check if you are allowed to add to the given classId
-	classRows, err := DB.Query("SELECT id FROM classes WHERE userId = ? AND id = ?", c.User.ID, r.FormValue("classId"))
+	classRows, err := DB.Query("SELECT id FROM classes WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, r.FormValue("classId"))
 	if err != nil {
 		errorlog.LogError("editing homework", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -420,16 +388,74 @@ func routeHomeworkEdit(w http.ResponseWriter, r *http.Request, p httprouter.Para
 		return
 	}
 
-	_, err = DB.Exec(
-		"UPDATE homework SET name = ?, `due` = ?, `desc` = ?, `complete` = ?, classId = ? WHERE id = ?",
-		r.FormValue("name"), r.FormValue("due"), r.FormValue("desc"), r.FormValue("complete"), r.FormValue("classId"), r.FormValue("id"),
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("editing homework", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// if the due date was changed by hand, the homework isn't due at the event it was linked to anymore
+	_, err = tx.Exec(
+		"DELETE homework_events FROM homework_events INNER JOIN homework ON homework_events.homeworkId = homework.id WHERE homework.id = ? AND homework.`due` != ?",
+		r.FormValue("id"), r.FormValue("due"),
 	)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("editing homework", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// completedAt has to be set before complete, so that it sees the old value
+	_, err = tx.Exec(
+		"UPDATE homework SET name = ?, `due` = ?, `desc` = ?, completedAt = IF(? = 1, IF(`complete` = 1, completedAt, ?), NULL), `complete` = ?, classId = ? WHERE id = ?",
+		r.FormValue("name"), r.FormValue("due"), r.FormValue("desc"), r.FormValue("complete"), time.Now().Unix(), r.FormValue("complete"), r.FormValue("classId"), r.FormValue("id"),
+	)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("editing homework", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// keep any shared copies up to date
+	id, _ := strconv.Atoi(r.FormValue("id"))
+	updatedCopies, err := data.SyncSharedHomework(tx, id)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("editing homework", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// if it moved into a class that's been shared, everyone it's shared with should get it too
+	newCopies := []data.Homework{}
+	if r.FormValue("classId") != strconv.Itoa(oldClassID) {
+		newCopies, err = data.ShareMovedHomework(tx, id)
+		if err != nil {
+			tx.Rollback()
+			errorlog.LogError("editing homework", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		errorlog.LogError("editing homework", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
+	event := data.WebhookEventHomeworkUpdated
+	if wasComplete == 0 && r.FormValue("complete") == "1" {
+		event = data.WebhookEventHomeworkCompleted
+	}
+	queueHomeworkWebhookEvent(c.User.ID, event, id)
+	queueHomeworkWebhookEvents(data.WebhookEventHomeworkUpdated, updatedCopies)
+	queueHomeworkWebhookEvents(data.WebhookEventHomeworkCreated, newCopies)
+
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
 
@@ -439,8 +465,14 @@ func routeHomeworkDelete(w http.ResponseWriter, r *http.Request, p httprouter.Pa
 		return
 	}
 
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
 	// check if you are allowed to edit the given id
-	idRows, err := DB.Query("SELECT id FROM homework WHERE userId = ? AND id = ?", c.User.ID, r.FormValue("id"))
+	idRows, err := DB.Query("SELECT name FROM homework WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, id)
 	if err != nil {
 		errorlog.LogError("deleting homework", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -452,19 +484,20 @@ func routeHomeworkDelete(w http.ResponseWriter, r *http.Request, p httprouter.Pa
 		return
 	}
 
-	deleteTx, err := DB.Begin()
+	name := ""
+	idRows.Scan(&name)
 
-	// delete the homework records
-	_, err = deleteTx.Exec("DELETE FROM homework WHERE id = ?", r.FormValue("id"))
+	deleteTx, err := DB.Begin()
 	if err != nil {
 		errorlog.LogError("deleting homework", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
-	// delete any associated calendar events
-	_, err = deleteTx.Exec("DELETE FROM calendar_hwevents WHERE homeworkId = ?", r.FormValue("id"))
+	// move the homework and any associated calendar events to the trash
+	_, err = data.TrashHomework(deleteTx, id, name, c.User.ID)
 	if err != nil {
+		deleteTx.Rollback()
 		errorlog.LogError("deleting homework", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
@@ -477,6 +510,8 @@ func routeHomeworkDelete(w http.ResponseWriter, r *http.Request, p httprouter.Pa
 		return
 	}
 
+	queueWebhookEvent(c.User.ID, data.WebhookEventHomeworkDeleted, webhookItem{id, name})
+
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
 
@@ -503,12 +538,33 @@ func routeHomeworkMarkOverdueDone(w http.ResponseWriter, r *http.Request, p http
 		hiddenClassesSet = hiddenClassesSet + strconv.Itoa(hiddenClassID)
 	}
 
-	_, err = DB.Exec("UPDATE homework SET complete = 1 WHERE due < NOW() - INTERVAL 1 DAY AND userId = ? AND FIND_IN_SET(classId, ?) = 0", c.User.ID, hiddenClassesSet)
+	// find what's about to be completed, so that webhooks can be told about it
+	overdueRows, err := DB.Query("SELECT id FROM homework WHERE complete = 0 AND due < NOW() - INTERVAL 1 DAY AND userId = ? AND trashId IS NULL AND FIND_IN_SET(classId, ?) = 0", c.User.ID, hiddenClassesSet)
 	if err != nil {
 		errorlog.LogError("marking overdue homework as done", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
+	defer overdueRows.Close()
+
+	overdueIDs := []int{}
+	for overdueRows.Next() {
+		overdueID := -1
+		overdueRows.Scan(&overdueID)
+		overdueIDs = append(overdueIDs, overdueID)
+	}
+	overdueRows.Close()
+
+	_, err = DB.Exec("UPDATE homework SET completedAt = IF(complete = 1, completedAt, ?), complete = 1 WHERE due < NOW() - INTERVAL 1 DAY AND userId = ? AND trashId IS NULL AND FIND_IN_SET(classId, ?) = 0", time.Now().Unix(), c.User.ID, hiddenClassesSet)
+	if err != nil {
+		errorlog.LogError("marking overdue homework as done", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	for _, overdueID := range overdueIDs {
+		queueHomeworkWebhookEvent(c.User.ID, data.WebhookEventHomeworkCompleted, overdueID)
+	}
 
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// the most homework items that can be changed in one request
+const bulkHomeworkMaxItems = 500
+
+// responses
+type bulkHomeworkResult struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+type bulkHomeworkResponse struct {
+	Status  string               `json:"status"`
+	Results []bulkHomeworkResult `json:"results"`
+}
+
+func routeHomeworkBulk(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("ids") == "" || r.FormValue("action") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	action := r.FormValue("action")
+
+	ids := []int{}
+	err := json.Unmarshal([]byte(r.FormValue("ids")), &ids)
+	if err != nil || len(ids) == 0 || len(ids) > bulkHomeworkMaxItems {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	// check the parameters for the given action
+	newDue := ""
+	shiftDays := 0
+	classID := -1
+	if action == "moveDue" {
+		if r.FormValue("due") == "" && r.FormValue("shiftDays") == "" {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+			return
+		}
+
+		if r.FormValue("due") != "" {
+			_, err = time.Parse("2006-01-02", r.FormValue("due"))
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+				return
+			}
+			newDue = r.FormValue("due")
+		} else {
+			shiftDays, err = strconv.Atoi(r.FormValue("shiftDays"))
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+				return
+			}
+		}
+	} else if action == "changeClass" {
+		if r.FormValue("classId") == "" {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+			return
+		}
+
+		classID, err = strconv.Atoi(r.FormValue("classId"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+
+		// check if you are allowed to add to the given classId
+		classRows, err := DB.Query("SELECT id FROM classes WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, classID)
+		if err != nil {
+			errorlog.LogError("applying bulk homework action", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+		defer classRows.Close()
+		if !classRows.Next() {
+			writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+			return
+		}
+	} else if action != "complete" && action != "uncomplete" && action != "delete" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	// find which of the given ids actually belong to the user
+	idStrings := []string{}
+	for _, id := range ids {
+		idStrings = append(idStrings, strconv.Itoa(id))
+	}
+
+	ownedRows, err := DB.Query("SELECT id, name, `due`, `complete`, classId FROM homework WHERE userId = ? AND trashId IS NULL AND FIND_IN_SET(id, ?) > 0", c.User.ID, strings.Join(idStrings, ","))
+	if err != nil {
+		errorlog.LogError("applying bulk homework action", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	defer ownedRows.Close()
+
+	ownedHomework := map[int]data.Homework{}
+	for ownedRows.Next() {
+		homework := data.Homework{}
+		err = ownedRows.Scan(&homework.ID, &homework.Name, &homework.Due, &homework.Complete, &homework.ClassID)
+		if err != nil {
+			errorlog.LogError("applying bulk homework action", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+		ownedHomework[homework.ID] = homework
+	}
+
+	// apply the action to everything in one go
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("applying bulk homework action", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	results := []bulkHomeworkResult{}
+	applied := map[int]bool{}
+	copies := []data.Homework{}
+	for _, id := range ids {
+		homework, owned := ownedHomework[id]
+		if !owned {
+			results = append(results, bulkHomeworkResult{id, "error", "forbidden"})
+			continue
+		}
+
+		if applied[id] {
+			// it showed up twice, don't do anything the second time
+			results = append(results, bulkHomeworkResult{id, "ok", ""})
+			continue
+		}
+
+		changedCopies, err := applyBulkHomeworkAction(tx, action, homework, c.User.ID, newDue, shiftDays, classID)
+		if err != nil {
+			tx.Rollback()
+			errorlog.LogError("applying bulk homework action", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+
+		applied[id] = true
+		copies = append(copies, changedCopies...)
+		results = append(results, bulkHomeworkResult{id, "ok", ""})
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("applying bulk homework action", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	for _, id := range ids {
+		if applied[id] {
+			queueBulkHomeworkWebhookEvent(action, ownedHomework[id], c.User.ID)
+
+			// so that it's only sent once if the id showed up twice
+			applied[id] = false
+		}
+	}
+
+	// copies of shared homework are new if it was moved into a shared class, and follow the original otherwise
+	if action == "changeClass" {
+		queueHomeworkWebhookEvents(data.WebhookEventHomeworkCreated, copies)
+	} else {
+		queueHomeworkWebhookEvents(data.WebhookEventHomeworkUpdated, copies)
+	}
+
+	writeJSON(w, http.StatusOK, bulkHomeworkResponse{"ok", results})
+}
+
+// applyBulkHomeworkAction applies the given action to the given homework, and returns any copies of it that were shared and changed along with it.
+func applyBulkHomeworkAction(tx *sql.Tx, action string, homework data.Homework, userID int, newDue string, shiftDays int, classID int) ([]data.Homework, error) {
+	var err error
+
+	if action == "complete" {
+		_, err = tx.Exec("UPDATE homework SET completedAt = IF(`complete` = 1, completedAt, ?), `complete` = 1 WHERE id = ?", time.Now().Unix(), homework.ID)
+	} else if action == "uncomplete" {
+		_, err = tx.Exec("UPDATE homework SET `complete` = 0, completedAt = NULL WHERE id = ?", homework.ID)
+	} else if action == "moveDue" {
+		due := newDue
+		if due == "" {
+			currentDue, err := time.Parse("2006-01-02", homework.Due)
+			if err != nil {
+				return nil, err
+			}
+			due = currentDue.AddDate(0, 0, shiftDays).Format("2006-01-02")
+		}
+		_, err = tx.Exec("UPDATE homework SET `due` = ? WHERE id = ?", due, homework.ID)
+		if err != nil {
+			return nil, err
+		}
+		// it's not due at the event it was linked to anymore
+		err = data.DeleteHomeworkEventLink(tx, homework.ID)
+		if err != nil {
+			return nil, err
+		}
+		return data.SyncSharedHomework(tx, homework.ID)
+	} else if action == "changeClass" {
+		if homework.ClassID == classID {
+			return nil, nil
+		}
+		_, err = tx.Exec("UPDATE homework SET classId = ? WHERE id = ?", classID, homework.ID)
+		if err != nil {
+			return nil, err
+		}
+		return data.ShareMovedHomework(tx, homework.ID)
+	} else if action == "delete" {
+		_, err = data.TrashHomework(tx, homework.ID, homework.Name, userID)
+	}
+
+	return nil, err
+}
+
+// queueBulkHomeworkWebhookEvent tells the user's webhooks about a homework item that a bulk action was applied to.
+func queueBulkHomeworkWebhookEvent(action string, homework data.Homework, userID int) {
+	if action == "delete" {
+		queueWebhookEvent(userID, data.WebhookEventHomeworkDeleted, webhookItem{homework.ID, homework.Name})
+		return
+	}
+
+	event := data.WebhookEventHomeworkUpdated
+	if action == "complete" {
+		if homework.Complete == 1 {
+			// nothing changed
+			return
+		}
+		event = data.WebhookEventHomeworkCompleted
+	}
+
+	queueHomeworkWebhookEvent(userID, event, homework.ID)
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/calendar"
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// responses
+type homeworkEventLinkResponse struct {
+	Status string                  `json:"status"`
+	Link   *data.HomeworkEventLink `json:"link"`
+}
+
+func routeHomeworkGetEventLink(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	id, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	link, err := data.GetHomeworkEventLinkForHomework(id)
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusOK, homeworkEventLinkResponse{"ok", nil})
+		return
+	} else if err != nil {
+		errorlog.LogError("getting homework event link", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	if link.UserID != c.User.ID {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, homeworkEventLinkResponse{"ok", &link})
+}
+
+func routeHomeworkLinkEvent(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("id") == "" || r.FormValue("eventId") == "" || r.FormValue("date") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	timeZone, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		errorlog.LogError("timezone info", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", r.FormValue("date"), timeZone)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	// check if you are allowed to edit the given id
+	// copies of shared homework get their due date from the original, so they can't be linked
+	idRows, err := DB.Query("SELECT id FROM homework WHERE userId = ? AND trashId IS NULL AND sourceId IS NULL AND id = ?", c.User.ID, id)
+	if err != nil {
+		errorlog.LogError("linking homework to event", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	defer idRows.Close()
+	if !idRows.Next() {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
+
+	// find the event on the day it's happening
+	view, err := calendar.GetView(DB, c.User, timeZone, date, date.AddDate(0, 0, 1))
+	if err != nil {
+		errorlog.LogError("linking homework to event", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	event, found := view.FindEvent(r.FormValue("eventId"))
+	if !found {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	_, isHomework := event.Tags[data.EventTagHomework]
+	if isHomework {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	cancelled, _ := event.Tags[data.EventTagCancelled].(bool)
+	if cancelled {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "event_cancelled"})
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("linking homework to event", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = data.SetHomeworkEventLink(tx, data.HomeworkEventLink{
+		HomeworkID: id,
+		EventID:    event.UniqueID,
+		EventDate:  calendar.GetEventOriginalDate(event, timeZone),
+		UserID:     c.User.ID,
+	})
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("linking homework to event", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// the homework is now due at the event
+	_, err = tx.Exec("UPDATE homework SET `due` = ? WHERE id = ?", date.Format("2006-01-02"), id)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("linking homework to event", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	copies, err := data.SyncSharedHomework(tx, id)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("linking homework to event", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("linking homework to event", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	queueHomeworkWebhookEvent(c.User.ID, data.WebhookEventHomeworkUpdated, id)
+	queueHomeworkWebhookEvents(data.WebhookEventHomeworkUpdated, copies)
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeHomeworkUnlinkEvent(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("id") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	// check if you are allowed to edit the given id
+	idRows, err := DB.Query("SELECT id FROM homework WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, id)
+	if err != nil {
+		errorlog.LogError("unlinking homework from event", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	defer idRows.Close()
+	if !idRows.Next() {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("unlinking homework from event", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// the due date stays where it is
+	err = data.DeleteHomeworkEventLink(tx, id)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("unlinking homework from event", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("unlinking homework from event", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+	"github.com/MyHomeworkSpace/api-server/search"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// HomeworkIndex is the search.Index used to search through homework.
+var HomeworkIndex search.Index
+
+// responses
+type homeworkSearchResponse struct {
+	Status     string          `json:"status"`
+	Homework   []data.Homework `json:"homework"`
+	Results    []search.Result `json:"results"`
+	NextCursor string          `json:"nextCursor"`
+}
+
+func routeHomeworkSearch(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("q") == "" && r.FormValue("classIds") == "" && r.FormValue("start") == "" && r.FormValue("end") == "" && r.FormValue("complete") == "" && r.FormValue("prefix") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	query := search.Query{
+		UserID: c.User.ID,
+		Text:   r.FormValue("q"),
+	}
+
+	if r.FormValue("classIds") != "" {
+		err := json.Unmarshal([]byte(r.FormValue("classIds")), &query.ClassIDs)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+	}
+
+	if r.FormValue("start") != "" {
+		_, err := time.Parse("2006-01-02", r.FormValue("start"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+		query.DueStart = r.FormValue("start")
+	}
+
+	if r.FormValue("end") != "" {
+		_, err := time.Parse("2006-01-02", r.FormValue("end"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+		query.DueEnd = r.FormValue("end")
+	}
+
+	if r.FormValue("complete") != "" {
+		if r.FormValue("complete") != "0" && r.FormValue("complete") != "1" {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+		complete := (r.FormValue("complete") == "1")
+		query.Complete = &complete
+	}
+
+	if r.FormValue("prefix") != "" {
+		// match any of the words that belong to the same prefix
+		prefixes, err := data.GetPrefixesForUser(c.User)
+		if err != nil {
+			errorlog.LogError("getting homework search results", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+
+		// search backends only look at the first word, so regular expressions and phrases are left out
+		query.PrefixWords = []string{}
+		prefix, found := data.MatchPrefix(prefixes, r.FormValue("prefix"), -1)
+		if found && !prefix.Regex {
+			for _, word := range prefix.Words {
+				if !strings.Contains(word, " ") {
+					query.PrefixWords = append(query.PrefixWords, word)
+				}
+			}
+		}
+		if len(query.PrefixWords) == 0 {
+			query.PrefixWords = []string{r.FormValue("prefix")}
+		}
+	}
+
+	if r.FormValue("cursor") != "" {
+		cursor, err := search.DecodeCursor(r.FormValue("cursor"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+		query.Cursor = &cursor
+	}
+
+	if r.FormValue("limit") != "" {
+		limit, err := strconv.Atoi(r.FormValue("limit"))
+		if err != nil || limit <= 0 || limit > search.MaxLimit {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+		query.Limit = limit
+	}
+
+	page, err := search.Search(HomeworkIndex, query)
+	if err != nil {
+		errorlog.LogError("getting homework search results", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// older clients only look at the homework list
+	homework := []data.Homework{}
+	for _, result := range page.Results {
+		homework = append(homework, result.Homework)
+	}
+
+	writeJSON(w, http.StatusOK, homeworkSearchResponse{"ok", homework, page.Results, page.NextCursor})
+}
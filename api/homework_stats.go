@@ -0,0 +1,270 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// how far back stats go if no start date is given
+const homeworkStatsDefaultWeeks = 12
+
+// the longest range that stats can be asked for, since every week in it gets its own entry
+const homeworkStatsMaxYears = 2
+
+// responses
+type homeworkStatsGroup struct {
+	Total                int     `json:"total"`
+	Completed            int     `json:"completed"`
+	CompletionRate       float64 `json:"completionRate"`
+	OnTime               int     `json:"onTime"`
+	Late                 int     `json:"late"`
+	CompletedUnknownTime int     `json:"completedUnknownTime"`
+}
+type homeworkStatsClass struct {
+	ClassID int `json:"classId"`
+	homeworkStatsGroup
+}
+type homeworkStatsPrefix struct {
+	Prefix string `json:"prefix"`
+	homeworkStatsGroup
+}
+type homeworkStatsWeek struct {
+	Week      string `json:"week"`
+	Total     int    `json:"total"`
+	Completed int    `json:"completed"`
+}
+type homeworkStatsResponse struct {
+	Status   string                `json:"status"`
+	Start    string                `json:"start"`
+	End      string                `json:"end"`
+	Overall  homeworkStatsGroup    `json:"overall"`
+	Classes  []homeworkStatsClass  `json:"classes"`
+	Prefixes []homeworkStatsPrefix `json:"prefixes"`
+	Weeks    []homeworkStatsWeek   `json:"weeks"`
+	Forecast []homeworkStatsWeek   `json:"forecast"`
+}
+
+/*
+ * helpers
+ */
+
+func getWeekStart(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7 // monday is the start of the week
+	return time.Date(day.Year(), day.Month(), day.Day()-offset, 0, 0, 0, 0, day.Location())
+}
+
+func (g *homeworkStatsGroup) add(complete bool, completedAt sql.NullInt64, dueDate time.Time) {
+	g.Total++
+
+	if complete {
+		g.Completed++
+
+		if !completedAt.Valid {
+			// this was completed before we started keeping track
+			g.CompletedUnknownTime++
+		} else if completedAt.Int64 < dueDate.AddDate(0, 0, 1).Unix() {
+			g.OnTime++
+		} else {
+			g.Late++
+		}
+	}
+
+	g.CompletionRate = float64(g.Completed) / float64(g.Total)
+}
+
+/*
+ * routes
+ */
+
+func routeHomeworkGetStats(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		errorlog.LogError("getting homework stats", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	now := time.Now().In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+
+	startDate := getWeekStart(today).AddDate(0, 0, -7*homeworkStatsDefaultWeeks)
+	endDate := today
+	if r.FormValue("start") != "" {
+		startDate, err = time.ParseInLocation("2006-01-02", r.FormValue("start"), location)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+	}
+	if r.FormValue("end") != "" {
+		endDate, err = time.ParseInLocation("2006-01-02", r.FormValue("end"), location)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+	}
+	if endDate.Before(startDate) || endDate.After(startDate.AddDate(homeworkStatsMaxYears, 0, 0)) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	forecastWeeks := 4
+	if r.FormValue("forecastWeeks") != "" {
+		forecastWeeks, err = strconv.Atoi(r.FormValue("forecastWeeks"))
+		if err != nil || forecastWeeks < 0 || forecastWeeks > 12 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+	}
+
+	prefixes, err := data.GetPrefixesForUser(c.User)
+	if err != nil {
+		errorlog.LogError("getting homework stats", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	prefixMatcher := data.NewPrefixMatcher(prefixes)
+
+	rows, err := DB.Query(
+		"SELECT name, `due`, `complete`, completedAt, classId FROM homework WHERE userId = ? AND trashId IS NULL AND `due` >= ? AND `due` <= ? ORDER BY `due` ASC",
+		c.User.ID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"),
+	)
+	if err != nil {
+		errorlog.LogError("getting homework stats", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	defer rows.Close()
+
+	overall := homeworkStatsGroup{}
+	classStats := map[int]*homeworkStatsClass{}
+	classOrder := []int{}
+	prefixStats := map[string]*homeworkStatsPrefix{}
+	prefixOrder := []string{}
+
+	// make sure every week in the range shows up, even if it's empty
+	weekStats := []homeworkStatsWeek{}
+	weekIndices := map[string]int{}
+	for week := getWeekStart(startDate); !week.After(endDate); week = week.AddDate(0, 0, 7) {
+		weekIndices[week.Format("2006-01-02")] = len(weekStats)
+		weekStats = append(weekStats, homeworkStatsWeek{Week: week.Format("2006-01-02")})
+	}
+
+	for rows.Next() {
+		name, due, complete, classID := "", "", 0, -1
+		completedAt := sql.NullInt64{}
+		err = rows.Scan(&name, &due, &complete, &completedAt, &classID)
+		if err != nil {
+			errorlog.LogError("getting homework stats", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+
+		dueDate, err := time.ParseInLocation("2006-01-02", due, location)
+		if err != nil {
+			errorlog.LogError("getting homework stats", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+
+		isComplete := (complete == 1)
+
+		overall.add(isComplete, completedAt, dueDate)
+
+		if _, ok := classStats[classID]; !ok {
+			classStats[classID] = &homeworkStatsClass{ClassID: classID}
+			classOrder = append(classOrder, classID)
+		}
+		classStats[classID].add(isComplete, completedAt, dueDate)
+
+		prefixName := ""
+		prefix, _, found := prefixMatcher.Match(name, classID)
+		if found {
+			prefixName = prefix.DisplayName()
+		}
+		if _, ok := prefixStats[prefixName]; !ok {
+			prefixStats[prefixName] = &homeworkStatsPrefix{Prefix: prefixName}
+			prefixOrder = append(prefixOrder, prefixName)
+		}
+		prefixStats[prefixName].add(isComplete, completedAt, dueDate)
+
+		weekIndex := weekIndices[getWeekStart(dueDate).Format("2006-01-02")]
+		weekStats[weekIndex].Total++
+		if isComplete {
+			weekStats[weekIndex].Completed++
+		}
+	}
+
+	// now look ahead at what's coming up
+	forecast := []homeworkStatsWeek{}
+	forecastIndices := map[string]int{}
+	thisWeek := getWeekStart(today)
+	for i := 0; i < forecastWeeks; i++ {
+		week := thisWeek.AddDate(0, 0, 7*i)
+		forecastIndices[week.Format("2006-01-02")] = len(forecast)
+		forecast = append(forecast, homeworkStatsWeek{Week: week.Format("2006-01-02")})
+	}
+
+	if forecastWeeks > 0 {
+		forecastRows, err := DB.Query(
+			"SELECT `due`, `complete` FROM homework WHERE userId = ? AND trashId IS NULL AND `due` >= ? AND `due` < ?",
+			c.User.ID, today.Format("2006-01-02"), thisWeek.AddDate(0, 0, 7*forecastWeeks).Format("2006-01-02"),
+		)
+		if err != nil {
+			errorlog.LogError("getting homework stats", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+		defer forecastRows.Close()
+
+		for forecastRows.Next() {
+			due, complete := "", 0
+			err = forecastRows.Scan(&due, &complete)
+			if err != nil {
+				errorlog.LogError("getting homework stats", err)
+				writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+				return
+			}
+
+			dueDate, err := time.ParseInLocation("2006-01-02", due, location)
+			if err != nil {
+				errorlog.LogError("getting homework stats", err)
+				writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+				return
+			}
+
+			weekIndex := forecastIndices[getWeekStart(dueDate).Format("2006-01-02")]
+			forecast[weekIndex].Total++
+			if complete == 1 {
+				forecast[weekIndex].Completed++
+			}
+		}
+	}
+
+	classes := []homeworkStatsClass{}
+	for _, classID := range classOrder {
+		classes = append(classes, *classStats[classID])
+	}
+
+	prefixList := []homeworkStatsPrefix{}
+	for _, prefixName := range prefixOrder {
+		prefixList = append(prefixList, *prefixStats[prefixName])
+	}
+
+	writeJSON(w, http.StatusOK, homeworkStatsResponse{
+		Status:   "ok",
+		Start:    startDate.Format("2006-01-02"),
+		End:      endDate.Format("2006-01-02"),
+		Overall:  overall,
+		Classes:  classes,
+		Prefixes: prefixList,
+		Weeks:    weekStats,
+		Forecast: forecast,
+	})
+}
@@ -0,0 +1,643 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// the longest estimate that can be set, in minutes
+const homeworkMaxEstimate = 7 * 24 * 60
+
+// responses
+type studySessionResponse struct {
+	Status  string            `json:"status"`
+	Session data.StudySession `json:"session"`
+}
+type studySessionsResponse struct {
+	Status   string              `json:"status"`
+	Sessions []data.StudySession `json:"sessions"`
+}
+type homeworkTimeResponse struct {
+	Status   string              `json:"status"`
+	Sessions []data.StudySession `json:"sessions"`
+	Estimate int                 `json:"estimate"` // minutes, or -1 if not set
+	Total    int                 `json:"total"`    // seconds
+}
+type homeworkTimeGroup struct {
+	Homework                  int     `json:"homework"`
+	WithEstimate              int     `json:"withEstimate"`
+	EstimatedSeconds          int     `json:"estimatedSeconds"`
+	ActualSeconds             int     `json:"actualSeconds"`
+	ActualSecondsWithEstimate int     `json:"actualSecondsWithEstimate"`
+	Ratio                     float64 `json:"ratio"` // actual time over estimated time, only counting homework with an estimate
+}
+type homeworkTimeClass struct {
+	ClassID int `json:"classId"`
+	homeworkTimeGroup
+}
+type homeworkTimePrefix struct {
+	Prefix string `json:"prefix"`
+	homeworkTimeGroup
+}
+type homeworkTimeReportResponse struct {
+	Status   string               `json:"status"`
+	Start    string               `json:"start"`
+	End      string               `json:"end"`
+	Overall  homeworkTimeGroup    `json:"overall"`
+	Classes  []homeworkTimeClass  `json:"classes"`
+	Prefixes []homeworkTimePrefix `json:"prefixes"`
+}
+
+/*
+ * helpers
+ */
+
+func (g *homeworkTimeGroup) add(estimate int, actual int) {
+	g.Homework++
+	g.ActualSeconds += actual
+
+	if estimate != -1 {
+		g.WithEstimate++
+		g.EstimatedSeconds += estimate * 60
+		g.ActualSecondsWithEstimate += actual
+	}
+
+	if g.EstimatedSeconds > 0 {
+		g.Ratio = float64(g.ActualSecondsWithEstimate) / float64(g.EstimatedSeconds)
+	}
+}
+
+func getStudySessionFromForm(w http.ResponseWriter, r *http.Request, c RouteContext, action string) (data.StudySession, bool) {
+	if r.FormValue("id") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return data.StudySession{}, false
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return data.StudySession{}, false
+	}
+
+	session, err := data.GetStudySessionByID(id, c.User.ID)
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return data.StudySession{}, false
+	} else if err != nil {
+		errorlog.LogError(action, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return data.StudySession{}, false
+	}
+
+	return session, true
+}
+
+// pauseOtherStudySessions pauses any of the user's timers that are running, other than the given session, so that only one runs at a time.
+func pauseOtherStudySessions(tx *sql.Tx, userID int, sessionID int, now time.Time) error {
+	sessions, err := data.GetActiveStudySessionsForUser(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.ID == sessionID || !session.Running() {
+			continue
+		}
+
+		err = data.PauseStudySession(tx, session, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+ * routes
+ */
+
+func routeHomeworkTimeGet(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	homeworkID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	rows, err := DB.Query("SELECT id FROM homework WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, homeworkID)
+	if err != nil {
+		errorlog.LogError("getting homework time", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
+
+	sessions, err := data.GetStudySessionsForHomework(homeworkID)
+	if err != nil {
+		errorlog.LogError("getting homework time", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	estimate, err := data.GetHomeworkEstimate(homeworkID)
+	if err != nil {
+		errorlog.LogError("getting homework time", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	now := time.Now()
+	total := 0
+	for _, session := range sessions {
+		total += session.TotalDuration(now)
+	}
+
+	writeJSON(w, http.StatusOK, homeworkTimeResponse{"ok", sessions, estimate, total})
+}
+
+func routeHomeworkTimeGetActive(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	sessions, err := data.GetActiveStudySessionsForUser(c.User.ID)
+	if err != nil {
+		errorlog.LogError("getting active study sessions", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, studySessionsResponse{"ok", sessions})
+}
+
+func routeHomeworkTimeStart(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("homeworkId") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	homeworkID, err := strconv.Atoi(r.FormValue("homeworkId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	rows, err := DB.Query("SELECT id FROM homework WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, homeworkID)
+	if err != nil {
+		errorlog.LogError("starting study session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
+
+	now := time.Now()
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("starting study session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = pauseOtherStudySessions(tx, c.User.ID, -1, now)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("starting study session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO study_sessions(homeworkId, `start`, duration, runningSince, userId) VALUES(?, ?, 0, ?, ?)",
+		homeworkID, now.Unix(), now.Unix(), c.User.ID,
+	)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("starting study session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	sessionID, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("starting study session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("starting study session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, studySessionResponse{"ok", data.StudySession{
+		ID:           int(sessionID),
+		HomeworkID:   homeworkID,
+		Start:        int(now.Unix()),
+		End:          -1,
+		Duration:     0,
+		RunningSince: int(now.Unix()),
+		HWEventID:    -1,
+		UserID:       c.User.ID,
+	}})
+}
+
+func routeHomeworkTimePause(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	session, ok := getStudySessionFromForm(w, r, c, "pausing study session")
+	if !ok {
+		return
+	}
+
+	if session.Stopped() || !session.Running() {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("pausing study session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = data.PauseStudySession(tx, session, time.Now())
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("pausing study session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("pausing study session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeHomeworkTimeResume(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	session, ok := getStudySessionFromForm(w, r, c, "resuming study session")
+	if !ok {
+		return
+	}
+
+	if session.Stopped() || session.Running() {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	now := time.Now()
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("resuming study session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = pauseOtherStudySessions(tx, c.User.ID, session.ID, now)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("resuming study session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	_, err = tx.Exec("UPDATE study_sessions SET runningSince = ? WHERE id = ?", now.Unix(), session.ID)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("resuming study session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("resuming study session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeHomeworkTimeStop(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	session, ok := getStudySessionFromForm(w, r, c, "stopping study session")
+	if !ok {
+		return
+	}
+
+	if session.Stopped() {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("stopping study session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = data.StopStudySession(tx, session, time.Now())
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("stopping study session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("stopping study session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeHomeworkTimeDelete(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	session, ok := getStudySessionFromForm(w, r, c, "deleting study session")
+	if !ok {
+		return
+	}
+
+	_, err := DB.Exec("DELETE FROM study_sessions WHERE id = ?", session.ID)
+	if err != nil {
+		errorlog.LogError("deleting study session", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeHomeworkTimeLogEvent(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("eventId") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	eventID, err := strconv.Atoi(r.FormValue("eventId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	rows, err := DB.Query(
+		"SELECT calendar_hwevents.homeworkId, calendar_hwevents.`start`, calendar_hwevents.`end` FROM calendar_hwevents "+
+			"INNER JOIN homework ON calendar_hwevents.homeworkId = homework.id "+
+			"WHERE calendar_hwevents.id = ? AND calendar_hwevents.userId = ? AND calendar_hwevents.trashId IS NULL AND homework.trashId IS NULL",
+		eventID, c.User.ID,
+	)
+	if err != nil {
+		errorlog.LogError("logging calendar homework event", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
+
+	homeworkID, start, end := -1, -1, -1
+	err = rows.Scan(&homeworkID, &start, &end)
+	if err != nil {
+		errorlog.LogError("logging calendar homework event", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// only blocks that are over count as time spent
+	if int64(end) > time.Now().Unix() {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "event_not_over"})
+		return
+	}
+
+	// each event can only be logged once, which the unique key on hwEventId takes care of, even if two requests come in at the same time
+	_, err = DB.Exec(
+		"INSERT INTO study_sessions(homeworkId, `start`, `end`, duration, hwEventId, userId) VALUES(?, ?, ?, ?, ?, ?)",
+		homeworkID, start, end, end-start, eventID, c.User.ID,
+	)
+	if data.IsDuplicateKeyError(err) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "already_logged"})
+		return
+	} else if err != nil {
+		errorlog.LogError("logging calendar homework event", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeHomeworkTimeSetEstimate(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("homeworkId") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	homeworkID, err := strconv.Atoi(r.FormValue("homeworkId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	// leaving out the estimate clears it
+	minutes := -1
+	if r.FormValue("minutes") != "" {
+		minutes, err = strconv.Atoi(r.FormValue("minutes"))
+		if err != nil || minutes <= 0 || minutes > homeworkMaxEstimate {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+	}
+
+	rows, err := DB.Query("SELECT id FROM homework WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, homeworkID)
+	if err != nil {
+		errorlog.LogError("setting homework estimate", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
+
+	if minutes == -1 {
+		_, err = DB.Exec("DELETE FROM homework_estimates WHERE homeworkId = ?", homeworkID)
+	} else {
+		_, err = DB.Exec(
+			"INSERT INTO homework_estimates(homeworkId, minutes, userId) VALUES(?, ?, ?) ON DUPLICATE KEY UPDATE minutes = VALUES(minutes)",
+			homeworkID, minutes, c.User.ID,
+		)
+	}
+	if err != nil {
+		errorlog.LogError("setting homework estimate", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeHomeworkTimeGetReport(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		errorlog.LogError("getting homework time report", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	now := time.Now().In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+
+	startDate := getWeekStart(today).AddDate(0, 0, -7*homeworkStatsDefaultWeeks)
+	endDate := today
+	if r.FormValue("start") != "" {
+		startDate, err = time.ParseInLocation("2006-01-02", r.FormValue("start"), location)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+	}
+	if r.FormValue("end") != "" {
+		endDate, err = time.ParseInLocation("2006-01-02", r.FormValue("end"), location)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+	}
+	if endDate.Before(startDate) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	prefixes, err := data.GetPrefixesForUser(c.User)
+	if err != nil {
+		errorlog.LogError("getting homework time report", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	prefixMatcher := data.NewPrefixMatcher(prefixes)
+
+	// add up the time spent on each homework item first
+	sessionRows, err := DB.Query(
+		"SELECT study_sessions.homeworkId, study_sessions.duration, IFNULL(study_sessions.runningSince, -1) FROM study_sessions "+
+			"INNER JOIN homework ON study_sessions.homeworkId = homework.id "+
+			"WHERE study_sessions.userId = ? AND homework.trashId IS NULL AND homework.`due` >= ? AND homework.`due` <= ?",
+		c.User.ID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"),
+	)
+	if err != nil {
+		errorlog.LogError("getting homework time report", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	defer sessionRows.Close()
+
+	actualTimes := map[int]int{}
+	for sessionRows.Next() {
+		session := data.StudySession{}
+		err = sessionRows.Scan(&session.HomeworkID, &session.Duration, &session.RunningSince)
+		if err != nil {
+			errorlog.LogError("getting homework time report", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+
+		actualTimes[session.HomeworkID] += session.TotalDuration(now)
+	}
+
+	rows, err := DB.Query(
+		"SELECT homework.id, homework.name, homework.classId, IFNULL(homework_estimates.minutes, -1) FROM homework "+
+			"INNER JOIN classes ON homework.classId = classes.id "+
+			"LEFT JOIN homework_estimates ON homework_estimates.homeworkId = homework.id "+
+			"WHERE homework.userId = ? AND homework.trashId IS NULL AND classes.archived = 0 AND homework.`due` >= ? AND homework.`due` <= ? ORDER BY homework.`due` ASC, homework.id ASC",
+		c.User.ID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"),
+	)
+	if err != nil {
+		errorlog.LogError("getting homework time report", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	defer rows.Close()
+
+	overall := homeworkTimeGroup{}
+	classTimes := map[int]*homeworkTimeClass{}
+	classOrder := []int{}
+	prefixTimes := map[string]*homeworkTimePrefix{}
+	prefixOrder := []string{}
+
+	for rows.Next() {
+		homeworkID, name, classID, estimate := -1, "", -1, -1
+		err = rows.Scan(&homeworkID, &name, &classID, &estimate)
+		if err != nil {
+			errorlog.LogError("getting homework time report", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+
+		// homework that nobody estimated or timed doesn't tell us anything
+		actual, tracked := actualTimes[homeworkID]
+		if !tracked && estimate == -1 {
+			continue
+		}
+
+		overall.add(estimate, actual)
+
+		if _, ok := classTimes[classID]; !ok {
+			classTimes[classID] = &homeworkTimeClass{ClassID: classID}
+			classOrder = append(classOrder, classID)
+		}
+		classTimes[classID].add(estimate, actual)
+
+		prefixName := ""
+		prefix, _, found := prefixMatcher.Match(name, classID)
+		if found {
+			prefixName = prefix.DisplayName()
+		}
+		if _, ok := prefixTimes[prefixName]; !ok {
+			prefixTimes[prefixName] = &homeworkTimePrefix{Prefix: prefixName}
+			prefixOrder = append(prefixOrder, prefixName)
+		}
+		prefixTimes[prefixName].add(estimate, actual)
+	}
+
+	classes := []homeworkTimeClass{}
+	for _, classID := range classOrder {
+		classes = append(classes, *classTimes[classID])
+	}
+
+	prefixResults := []homeworkTimePrefix{}
+	for _, prefixName := range prefixOrder {
+		prefixResults = append(prefixResults, *prefixTimes[prefixName])
+	}
+
+	writeJSON(w, http.StatusOK, homeworkTimeReportResponse{
+		"ok",
+		startDate.Format("2006-01-02"),
+		endDate.Format("2006-01-02"),
+		overall,
+		classes,
+		prefixResults,
+	})
+}
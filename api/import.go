@@ -0,0 +1,633 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// the most rows that can be imported in one request
+const importMaxRows = 5000
+
+var errImportInvalidFile = errors.New("api: import file could not be parsed")
+var errImportTooManyRows = errors.New("api: import file has too many rows")
+
+// an importedItem is something that an import added, which the webhooks of the user it belongs to are told about once it's saved
+type importedItem struct {
+	event  data.WebhookEvent
+	id     int
+	name   string
+	userID int // copies of shared homework belong to whoever it was shared with
+}
+
+// responses
+type importRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+type importResponse struct {
+	Status   string           `json:"status"`
+	DryRun   bool             `json:"dryRun"`
+	Imported int              `json:"imported"`
+	Errors   []importRowError `json:"errors"`
+}
+
+/*
+ * helpers
+ */
+
+// readImportCSV parses a csv file with a header row, and returns each of the other rows as a map from column name to value.
+func readImportCSV(input string) ([]map[string]string, error) {
+	reader := csv.NewReader(strings.NewReader(input))
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, errImportInvalidFile
+	}
+
+	header := records[0]
+	rows := []map[string]string{}
+	for _, record := range records[1:] {
+		row := map[string]string{}
+		for i, column := range header {
+			if i < len(record) {
+				row[strings.TrimSpace(column)] = strings.TrimSpace(record[i])
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// readImportJSON parses a json array into the given slice.
+func readImportJSON(input string, items interface{}) error {
+	err := json.Unmarshal([]byte(input), items)
+	if err != nil {
+		return errImportInvalidFile
+	}
+	return nil
+}
+
+// importFirstRow returns the row number of the first item in a file of the given format, which is what row errors count from.
+func importFirstRow(format string) int {
+	if format == "csv" {
+		// the header is the first row
+		return 2
+	}
+	return 1
+}
+
+func parseImportBool(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+func isValidImportColor(color string) bool {
+	_, err := hex.DecodeString(color)
+	return len(color) == 6 && err == nil
+}
+
+func importHomework(tx *sql.Tx, user *data.User, format string, input string) (int, []importedItem, []importRowError, error) {
+	items := []transferHomework{}
+	rowErrors := []importRowError{}
+	firstRow := importFirstRow(format)
+
+	if format == "csv" {
+		rows, err := readImportCSV(input)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+
+		for i, row := range rows {
+			complete, err := parseImportBool(row["complete"])
+			if err != nil {
+				rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_complete"})
+			}
+
+			items = append(items, transferHomework{row["name"], row["due"], row["desc"], complete, row["class"]})
+		}
+	} else {
+		err := readImportJSON(input, &items)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+	}
+
+	if len(items) > importMaxRows {
+		return 0, nil, nil, errImportTooManyRows
+	}
+
+	// classes are given by name, so figure out which ones those are
+	classes, err := data.GetClassesForUser(user)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	classIDs := map[string]int{}
+	for _, class := range classes {
+		key := strings.ToLower(strings.TrimSpace(class.Name))
+		if _, exists := classIDs[key]; !exists {
+			classIDs[key] = class.ID
+		}
+	}
+
+	imported := 0
+	importedItems := []importedItem{}
+	importedAt := time.Now().Unix()
+	for i, item := range items {
+		rowValid := true
+		if strings.TrimSpace(item.Name) == "" {
+			rowErrors = append(rowErrors, importRowError{i + firstRow, "missing_name"})
+			rowValid = false
+		}
+		if _, err := time.Parse("2006-01-02", item.Due); err != nil {
+			rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_due"})
+			rowValid = false
+		}
+		classID, classFound := classIDs[strings.ToLower(strings.TrimSpace(item.Class))]
+		if !classFound {
+			rowErrors = append(rowErrors, importRowError{i + firstRow, "unknown_class"})
+			rowValid = false
+		}
+
+		// once something is wrong, nothing will be saved, but keep checking so that every problem gets reported
+		if !rowValid || len(rowErrors) > 0 {
+			continue
+		}
+
+		complete := 0
+		var completedAt *int64
+		if item.Complete {
+			complete = 1
+
+			// there's no way to know when it was actually done, so count it as done now
+			completedAt = &importedAt
+		}
+
+		result, err := tx.Exec(
+			"INSERT INTO homework(name, `due`, `desc`, `complete`, completedAt, classId, userId) VALUES(?, ?, ?, ?, ?, ?, ?)",
+			strings.TrimSpace(item.Name), item.Due, item.Desc, complete, completedAt, classID, user.ID,
+		)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+
+		homeworkID, err := result.LastInsertId()
+		if err != nil {
+			return 0, nil, nil, err
+		}
+
+		copies, err := data.ShareNewHomework(tx, data.Homework{
+			ID:      int(homeworkID),
+			Name:    strings.TrimSpace(item.Name),
+			Due:     item.Due,
+			Desc:    item.Desc,
+			ClassID: classID,
+		})
+		if err != nil {
+			return 0, nil, nil, err
+		}
+
+		importedItems = append(importedItems, importedItem{data.WebhookEventHomeworkCreated, int(homeworkID), strings.TrimSpace(item.Name), user.ID})
+		for _, homeworkCopy := range copies {
+			importedItems = append(importedItems, importedItem{data.WebhookEventHomeworkCreated, homeworkCopy.ID, homeworkCopy.Name, homeworkCopy.UserID})
+		}
+
+		imported++
+	}
+
+	return imported, importedItems, rowErrors, nil
+}
+
+func importClasses(tx *sql.Tx, user *data.User, format string, input string) (int, []importedItem, []importRowError, error) {
+	items := []transferClass{}
+	rowErrors := []importRowError{}
+	firstRow := importFirstRow(format)
+
+	if format == "csv" {
+		rows, err := readImportCSV(input)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+
+		for _, row := range rows {
+			items = append(items, transferClass{row["name"], row["teacher"], row["color"]})
+		}
+	} else {
+		err := readImportJSON(input, &items)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+	}
+
+	if len(items) > importMaxRows {
+		return 0, nil, nil, errImportTooManyRows
+	}
+
+	imported := 0
+	importedItems := []importedItem{}
+	for i, item := range items {
+		rowValid := true
+		if strings.TrimSpace(item.Name) == "" {
+			rowErrors = append(rowErrors, importRowError{i + firstRow, "missing_name"})
+			rowValid = false
+		}
+		if !isValidImportColor(item.Color) {
+			rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_color"})
+			rowValid = false
+		}
+
+		if !rowValid || len(rowErrors) > 0 {
+			continue
+		}
+
+		result, err := tx.Exec(
+			"INSERT INTO classes(name, teacher, color, sortIndex, termId, userId) VALUES(?, ?, ?, (SELECT * FROM (SELECT COUNT(*) FROM classes WHERE userId = ? AND trashId IS NULL) AS sortIndex), (SELECT id FROM terms WHERE userId = ? ORDER BY startedAt DESC, id DESC LIMIT 1), ?)",
+			strings.TrimSpace(item.Name), item.Teacher, item.Color, user.ID, user.ID, user.ID,
+		)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+
+		classID, err := result.LastInsertId()
+		if err != nil {
+			return 0, nil, nil, err
+		}
+
+		importedItems = append(importedItems, importedItem{data.WebhookEventClassCreated, int(classID), strings.TrimSpace(item.Name), user.ID})
+
+		imported++
+	}
+
+	return imported, importedItems, rowErrors, nil
+}
+
+func importEvents(tx *sql.Tx, user *data.User, format string, input string) (int, []importedItem, []importRowError, error) {
+	items := []transferEvent{}
+	rowErrors := []importRowError{}
+	firstRow := importFirstRow(format)
+
+	if format == "csv" {
+		rows, err := readImportCSV(input)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+
+		for i, row := range rows {
+			item := transferEvent{
+				Name:       row["name"],
+				Location:   row["location"],
+				Desc:       row["desc"],
+				RecurUntil: row["recurUntil"],
+			}
+
+			item.Start, err = strconv.Atoi(row["start"])
+			if err != nil {
+				rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_start"})
+			}
+			item.End, err = strconv.Atoi(row["end"])
+			if err != nil {
+				rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_end"})
+			}
+
+			item.Recur, err = parseImportBool(row["recur"])
+			if err != nil {
+				rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_recur"})
+			}
+			if item.Recur {
+				frequency, err := strconv.Atoi(row["recurFrequency"])
+				interval, err2 := strconv.Atoi(row["recurInterval"])
+				if err != nil || err2 != nil {
+					rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_recur"})
+				}
+				item.RecurFrequency = frequency
+				item.RecurInterval = interval
+				item.RecurByDay = row["recurByDay"]
+
+				if row["recurByMonthDay"] != "" {
+					item.RecurByMonthDay, err = strconv.Atoi(row["recurByMonthDay"])
+					if err != nil {
+						rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_recur"})
+					}
+				}
+				if row["recurByMonth"] != "" {
+					item.RecurByMonth, err = strconv.Atoi(row["recurByMonth"])
+					if err != nil {
+						rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_recur"})
+					}
+				}
+			}
+
+			items = append(items, item)
+		}
+	} else {
+		err := readImportJSON(input, &items)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+	}
+
+	if len(items) > importMaxRows {
+		return 0, nil, nil, errImportTooManyRows
+	}
+
+	imported := 0
+	importedItems := []importedItem{}
+	for i, item := range items {
+		rowValid := true
+		if strings.TrimSpace(item.Name) == "" {
+			rowErrors = append(rowErrors, importRowError{i + firstRow, "missing_name"})
+			rowValid = false
+		}
+		if item.Start > item.End {
+			rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_times"})
+			rowValid = false
+		}
+
+		recurUntil := item.RecurUntil
+		if item.Recur {
+			if item.RecurFrequency < int(data.RecurFrequencyDaily) || item.RecurFrequency > int(data.RecurFrequencyYearly) || item.RecurInterval < 1 ||
+				item.RecurByMonthDay < 0 || item.RecurByMonthDay > 31 || item.RecurByMonth < 0 || item.RecurByMonth > 12 {
+				rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_recur"})
+				rowValid = false
+			}
+
+			if recurUntil == "" {
+				// fill in a placeholder value because mysql wants one
+				recurUntil = "2099-12-12"
+			} else if _, err := time.Parse("2006-01-02", recurUntil); err != nil {
+				rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_recur"})
+				rowValid = false
+			}
+		}
+
+		if !rowValid || len(rowErrors) > 0 {
+			continue
+		}
+
+		result, err := tx.Exec(
+			"INSERT INTO calendar_events(name, `start`, `end`, location, `desc`, userId) VALUES(?, ?, ?, ?, ?, ?)",
+			strings.TrimSpace(item.Name), item.Start, item.End, item.Location, item.Desc, user.ID,
+		)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+
+		eventID, err := result.LastInsertId()
+		if err != nil {
+			return 0, nil, nil, err
+		}
+
+		if item.Recur {
+			_, err = tx.Exec(
+				"INSERT INTO calendar_event_rules(eventId, `frequency`, `interval`, byDay, byMonthDay, byMonth, `until`) VALUES(?, ?, ?, ?, ?, ?, ?)",
+				eventID, item.RecurFrequency, item.RecurInterval, item.RecurByDay, item.RecurByMonthDay, item.RecurByMonth, recurUntil,
+			)
+			if err != nil {
+				return 0, nil, nil, err
+			}
+		}
+
+		importedItems = append(importedItems, importedItem{data.WebhookEventCalendarEventCreated, int(eventID), strings.TrimSpace(item.Name), user.ID})
+
+		imported++
+	}
+
+	return imported, importedItems, rowErrors, nil
+}
+
+func importPrefixes(tx *sql.Tx, user *data.User, format string, input string) (int, []importedItem, []importRowError, error) {
+	items := []transferPrefix{}
+	rowErrors := []importRowError{}
+	firstRow := importFirstRow(format)
+
+	if format == "csv" {
+		rows, err := readImportCSV(input)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+
+		for i, row := range rows {
+			timedEvent, err := parseImportBool(row["timedEvent"])
+			if err != nil {
+				rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_timed_event"})
+			}
+
+			regex, err := parseImportBool(row["regex"])
+			if err != nil {
+				rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_regex"})
+			}
+
+			priority := 0
+			if row["priority"] != "" {
+				priority, err = strconv.Atoi(row["priority"])
+				if err != nil {
+					rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_priority"})
+				}
+			}
+
+			// see transferPrefix.csvRecord for how words are written
+			words := strings.Fields(row["words"])
+			if strings.HasPrefix(row["words"], "[") {
+				err = json.Unmarshal([]byte(row["words"]), &words)
+				if err != nil {
+					rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_words"})
+				}
+			}
+
+			items = append(items, transferPrefix{strings.TrimSpace(row["name"]), words, row["color"], row["background"], timedEvent, regex, priority})
+		}
+	} else {
+		err := readImportJSON(input, &items)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+	}
+
+	if len(items) > importMaxRows {
+		return 0, nil, nil, errImportTooManyRows
+	}
+
+	imported := 0
+	for i, item := range items {
+		rowValid := true
+
+		cleanedWordsList := []string{}
+		for _, word := range item.Words {
+			if strings.TrimSpace(word) == "" {
+				continue
+			}
+
+			if item.Regex {
+				_, err := data.CompilePrefixPattern(word)
+				if err != nil || len(word) > data.MaxPrefixPatternLength {
+					rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_regex"})
+					rowValid = false
+					break
+				}
+				cleanedWordsList = append(cleanedWordsList, word)
+			} else {
+				cleanedWordsList = append(cleanedWordsList, strings.Join(strings.Fields(word), " "))
+			}
+		}
+		if rowValid && len(cleanedWordsList) == 0 {
+			rowErrors = append(rowErrors, importRowError{i + firstRow, "missing_words"})
+			rowValid = false
+		}
+
+		item.Name = strings.TrimSpace(item.Name)
+		if len(item.Name) > data.MaxPrefixNameLength || (item.Regex && item.Name == "") {
+			rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_name"})
+			rowValid = false
+		}
+
+		if item.Priority < -1000 || item.Priority > 1000 {
+			rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_priority"})
+			rowValid = false
+		}
+
+		if !isValidImportColor(item.Color) || !isValidImportColor(item.Background) {
+			rowErrors = append(rowErrors, importRowError{i + firstRow, "invalid_color"})
+			rowValid = false
+		}
+
+		if !rowValid || len(rowErrors) > 0 {
+			continue
+		}
+
+		wordsFormatted, err := json.Marshal(cleanedWordsList)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+
+		timedEventInt := 0
+		if item.TimedEvent {
+			timedEventInt = 1
+		}
+
+		regexInt := 0
+		if item.Regex {
+			regexInt = 1
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO prefixes(name, words, color, background, isTimedEvent, isRegex, priority, userId) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			item.Name, string(wordsFormatted), item.Color, item.Background, timedEventInt, regexInt, item.Priority, user.ID,
+		)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+
+		imported++
+	}
+
+	return imported, nil, rowErrors, nil
+}
+
+/*
+ * routes
+ */
+
+func routeImport(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("data") == "" || r.FormValue("format") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	importType := p.ByName("type")
+	format := r.FormValue("format")
+	if format != "json" && format != "csv" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	dryRun, err := parseImportBool(r.FormValue("dryRun"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	var importFunc func(tx *sql.Tx, user *data.User, format string, input string) (int, []importedItem, []importRowError, error)
+	if importType == "homework" {
+		importFunc = importHomework
+	} else if importType == "classes" {
+		importFunc = importClasses
+	} else if importType == "events" {
+		importFunc = importEvents
+	} else if importType == "prefixes" {
+		importFunc = importPrefixes
+	} else {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	// everything goes in one transaction, so that nothing gets saved unless everything is valid
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("importing "+importType, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	imported, importedItems, rowErrors, err := importFunc(tx, c.User, format, r.FormValue("data"))
+	if err == errImportInvalidFile {
+		tx.Rollback()
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_file"})
+		return
+	} else if err == errImportTooManyRows {
+		tx.Rollback()
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "too_many_rows"})
+		return
+	} else if err != nil {
+		tx.Rollback()
+		errorlog.LogError("importing "+importType, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	if len(rowErrors) > 0 {
+		tx.Rollback()
+		writeJSON(w, http.StatusBadRequest, importResponse{"error", dryRun, 0, rowErrors})
+		return
+	}
+
+	if dryRun {
+		// everything would have worked, but don't actually save it
+		tx.Rollback()
+		writeJSON(w, http.StatusOK, importResponse{"ok", true, imported, rowErrors})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("importing "+importType, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	for _, item := range importedItems {
+		if item.event == data.WebhookEventHomeworkCreated {
+			queueHomeworkWebhookEvent(item.userID, item.event, item.id)
+		} else if item.event == data.WebhookEventClassCreated {
+			queueClassWebhookEvent(item.userID, item.event, item.id)
+		} else {
+			queueWebhookEvent(item.userID, item.event, webhookItem{item.id, item.name})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, importResponse{"ok", false, imported, rowErrors})
+}
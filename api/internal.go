@@ -18,7 +18,7 @@ func routeInternalStartTask(w http.ResponseWriter, r *http.Request, p httprouter
 		return
 	}
 
-	if task != "calendar:sync" && task != "mit:fetch:catalog" && task != "mit:fetch:coursews" && task != "mit:fetch:offerings" {
+	if task != "calendar:sync" && task != "trash:purge" && task != "digest:send" && task != "webhooks:deliver" && task != "mit:fetch:catalog" && task != "mit:fetch:coursews" && task != "mit:fetch:offerings" {
 		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
 		return
 	}
@@ -30,6 +30,27 @@ func routeInternalStartTask(w http.ResponseWriter, r *http.Request, p httprouter
 			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 			return
 		}
+	} else if task == "trash:purge" {
+		err := tasks.StartTrashPurge(DB)
+		if err != nil {
+			errorlog.LogError("starting task", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+	} else if task == "digest:send" {
+		err := tasks.StartDigestSend(DB)
+		if err != nil {
+			errorlog.LogError("starting task", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+	} else if task == "webhooks:deliver" {
+		err := tasks.StartWebhookDeliver(DB)
+		if err != nil {
+			errorlog.LogError("starting task", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
 	} else {
 		source := strings.Replace(task, "mit:fetch:", "", -1)
 
@@ -12,6 +12,7 @@ import (
 	"github.com/MyHomeworkSpace/api-server/config"
 	"github.com/MyHomeworkSpace/api-server/data"
 	"github.com/MyHomeworkSpace/api-server/errorlog"
+	"github.com/MyHomeworkSpace/api-server/util"
 	"github.com/duo-labs/webauthn/webauthn"
 
 	"github.com/julienschmidt/httprouter"
@@ -48,7 +49,9 @@ type RouteContext struct {
 	User     *data.User
 }
 
-func route(f routeFunc, level authLevel) httprouter.Handle {
+// route wraps the given routeFunc, checking that the request is allowed to use it.
+// The scope is what an application's token needs to be able to use the route, or one of scopeSessionOnly and scopeAny.
+func route(f routeFunc, level authLevel, scope data.Scope) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		// set up panic handler
 		defer func() {
@@ -79,7 +82,9 @@ func route(f routeFunc, level authLevel) httprouter.Handle {
 		}
 
 		// some routes bypass session stuff
-		bypassSession := strings.HasPrefix(r.URL.Path, "/application/requestAuth") || strings.HasPrefix(r.URL.Path, "/auth/completeEmailStart")
+		// the oauth endpoints other than approving are called by applications, which authenticate themselves instead
+		bypassSession := strings.HasPrefix(r.URL.Path, "/application/requestAuth") || strings.HasPrefix(r.URL.Path, "/auth/completeEmailStart") || strings.HasPrefix(r.URL.Path, "/digest/unsubscribe") ||
+			strings.HasPrefix(r.URL.Path, "/oauth/authorize") || strings.HasPrefix(r.URL.Path, "/oauth/token") || strings.HasPrefix(r.URL.Path, "/oauth/revoke") || strings.HasPrefix(r.URL.Path, "/oauth/introspect")
 		if !bypassSession {
 			_, err := r.Cookie("session")
 			if err != nil {
@@ -108,7 +113,12 @@ func route(f routeFunc, level authLevel) httprouter.Handle {
 					authToken := headerParts[1]
 
 					// look up token
-					rows, err := DB.Query("SELECT applications.cors FROM application_authorizations INNER JOIN applications ON application_authorizations.applicationId = applications.id WHERE application_authorizations.token = ?", authToken)
+					// it could be an old-style token, or an access token from oauth
+					rows, err := DB.Query(
+						"SELECT applications.cors FROM application_authorizations INNER JOIN applications ON application_authorizations.applicationId = applications.id WHERE application_authorizations.token = ? "+
+							"UNION ALL SELECT applications.cors FROM oauth_tokens INNER JOIN application_authorizations ON oauth_tokens.authorizationId = application_authorizations.id INNER JOIN applications ON application_authorizations.applicationId = applications.id WHERE oauth_tokens.tokenHash = ? AND oauth_tokens.type = 'access'",
+						authToken, util.HashToken(authToken),
+					)
 					if err == nil {
 						// IMPORTANT: if there's an error with the token, we just continue with the request
 						// this is for backwards compatibility with old versions, where the token would always bypass csrf and only be checked when authentication was needed
@@ -204,17 +214,36 @@ func route(f routeFunc, level authLevel) httprouter.Handle {
 		}
 
 		// are they logged in?
-		sessionUserID := GetSessionUserID(r)
+		session := GetSessionInfo(r)
 
-		if sessionUserID != -1 {
+		if session.UserID != -1 {
 			context.LoggedIn = true
-			user, err := data.GetUserByID(sessionUserID)
+			user, err := data.GetUserByID(session.UserID)
 			if err != nil {
 				errorlog.LogError("getting user information for request", err)
 				writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 				return
 			}
 			context.User = &user
+
+			if !HasAuthToken(r) {
+				cookie, _ := r.Cookie("session")
+				auth.TouchSession(cookie.Value, session.UserID, getRequestRemoteAddr(r), r.UserAgent())
+			}
+
+			// applications can only do what the user let them
+			if session.FromToken && !tokenHasScope(session.Scopes, scope) {
+				writeInsufficientScope(w, scope)
+				return
+			}
+
+			// keep track of how much applications are used, for their developers
+			if session.FromToken && session.ApplicationID != -1 {
+				err = data.RecordApplicationRequest(session.ApplicationID, time.Now())
+				if err != nil {
+					errorlog.LogError("recording application request", err)
+				}
+			}
 		}
 
 		if level != authLevelNone {
@@ -255,110 +284,215 @@ func writeJSON(w http.ResponseWriter, status int, thing interface{}) {
 
 // Init will initialize all available API endpoints
 func Init(router *httprouter.Router) {
-	router.GET("/status", route(routeStatus, authLevelNone))
-
-	router.GET("/admin/getAllFeedback", route(routeAdminGetAllFeedback, authLevelAdmin))
-	router.GET("/admin/getFeedbackScreenshot/:id", route(routeAdminGetFeedbackScreenshot, authLevelAdmin))
-	router.GET("/admin/getUserCount", route(routeAdminGetUserCount, authLevelAdmin))
-	router.POST("/admin/sendEmail", route(routeAdminSendEmail, authLevelAdmin))
-	router.POST("/admin/triggerError", route(routeAdminTriggerError, authLevelAdmin))
-
-	router.POST("/application/completeAuth", route(routeApplicationCompleteAuth, authLevelLoggedIn))
-	router.GET("/application/get/:id", route(routeApplicationGet, authLevelLoggedIn))
-	router.GET("/application/getAuthorizations", route(routeApplicationGetAuthorizations, authLevelLoggedIn))
-	router.GET("/application/requestAuth/:id", route(routeApplicationRequestAuth, authLevelNone))
-	router.POST("/application/revokeAuth", route(routeApplicationRevokeAuth, authLevelLoggedIn))
-	router.POST("/application/revokeSelf", route(routeApplicationRevokeSelf, authLevelLoggedIn))
-
-	router.POST("/application/manage/create", route(routeApplicationManageCreate, authLevelLoggedIn))
-	router.GET("/application/manage/getAll", route(routeApplicationManageGetAll, authLevelLoggedIn))
-	router.POST("/application/manage/update", route(routeApplicationManageUpdate, authLevelLoggedIn))
-	router.POST("/application/manage/delete", route(routeApplicationManageDelete, authLevelLoggedIn))
-
-	router.POST("/auth/changeEmail", route(routeAuthChangeEmail, authLevelLoggedIn))
-	router.POST("/auth/changeName", route(routeAuthChangeName, authLevelLoggedIn))
-	router.POST("/auth/changePassword", route(routeAuthChangePassword, authLevelLoggedIn))
-	router.POST("/auth/clearMigrateFlag", route(routeAuthClearMigrateFlag, authLevelLoggedIn))
-	router.GET("/auth/completeEmailStart/:token", route(routeAuthCompleteEmailStart, authLevelNone))
-	router.POST("/auth/completeEmail", route(routeAuthCompleteEmail, authLevelNone))
-	router.GET("/auth/context", route(routeAuthContext, authLevelLoggedIn))
-	router.POST("/auth/createAccount", route(routeAuthCreateAccount, authLevelNone))
-	router.GET("/auth/csrf", route(routeAuthCsrf, authLevelNone))
-	router.POST("/auth/login", route(routeAuthLogin, authLevelNone))
-	router.GET("/auth/me", route(routeAuthMe, authLevelLoggedIn))
-	router.GET("/auth/logout", route(routeAuthLogout, authLevelLoggedIn))
-	router.POST("/auth/requestAccountDelete", route(routeAuthRequestAccountDelete, authLevelLoggedIn))
-	router.POST("/auth/resetPassword", route(routeAuthResetPassword, authLevelNone))
-	router.POST("/auth/resendVerificationEmail", route(routeAuthResendVerificationEmail, authLevelNone))
-	router.GET("/auth/session", route(routeAuthSession, authLevelNone))
-
-	router.POST("/auth/2fa/beginEnroll", route(routeAuth2faBeginEnroll, authLevelLoggedIn))
-	router.POST("/auth/2fa/completeEnroll", route(routeAuth2faCompleteEnroll, authLevelLoggedIn))
-	router.GET("/auth/2fa/status", route(routeAuth2faStatus, authLevelLoggedIn))
-	router.POST("/auth/2fa/unenroll", route(routeAuth2faUnenroll, authLevelLoggedIn))
-
-	router.GET("/calendar/getStatus", route(routeCalendarGetStatus, authLevelLoggedIn))
-	router.GET("/calendar/getView", route(routeCalendarGetView, authLevelLoggedIn))
-
-	router.GET("/calendar/events/getWeek/:monday", route(routeCalendarEventsGetWeek, authLevelLoggedIn))
-
-	router.POST("/calendar/events/add", route(routeCalendarEventsAdd, authLevelLoggedIn))
-	router.POST("/calendar/events/edit", route(routeCalendarEventsEdit, authLevelLoggedIn))
-	router.POST("/calendar/events/delete", route(routeCalendarEventsDelete, authLevelLoggedIn))
-
-	router.POST("/calendar/hwEvents/add", route(routeCalendarHWEventsAdd, authLevelLoggedIn))
-	router.POST("/calendar/hwEvents/edit", route(routeCalendarHWEventsEdit, authLevelLoggedIn))
-	router.POST("/calendar/hwEvents/delete", route(routeCalendarHWEventsDelete, authLevelLoggedIn))
-
-	router.GET("/calendar/eventChanges/get", route(routeCalendarEventChangesGet, authLevelLoggedIn))
-	router.POST("/calendar/eventChanges/set", route(routeCalendarEventChangesSet, authLevelLoggedIn))
-
-	router.GET("/classes/get", route(routeClassesGet, authLevelLoggedIn))
-	router.GET("/classes/get/:id", route(routeClassesGetID, authLevelLoggedIn))
-	router.GET("/classes/hwInfo/:id", route(routeClassesHWInfo, authLevelLoggedIn))
-	router.POST("/classes/add", route(routeClassesAdd, authLevelLoggedIn))
-	router.POST("/classes/edit", route(routeClassesEdit, authLevelLoggedIn))
-	router.POST("/classes/delete", route(routeClassesDelete, authLevelLoggedIn))
-	router.POST("/classes/swap", route(routeClassesSwap, authLevelLoggedIn))
-
-	router.POST("/feedback/add", route(routeFeedbackAdd, authLevelLoggedIn))
-
-	router.GET("/homework/get", route(routeHomeworkGet, authLevelLoggedIn))
-	router.GET("/homework/getForClass/:classId", route(routeHomeworkGetForClass, authLevelLoggedIn))
-	router.GET("/homework/getHWView", route(routeHomeworkGetHWView, authLevelLoggedIn))
-	router.GET("/homework/getHWViewSorted", route(routeHomeworkGetHWViewSorted, authLevelLoggedIn))
-	router.GET("/homework/get/:id", route(routeHomeworkGetID, authLevelLoggedIn))
-	router.GET("/homework/getWeek/:date", route(routeHomeworkGetWeek, authLevelLoggedIn))
-	router.GET("/homework/getPickerSuggestions", route(routeHomeworkGetPickerSuggestions, authLevelLoggedIn))
-	router.GET("/homework/search", route(routeHomeworkSearch, authLevelLoggedIn))
-	router.POST("/homework/add", route(routeHomeworkAdd, authLevelLoggedIn))
-	router.POST("/homework/edit", route(routeHomeworkEdit, authLevelLoggedIn))
-	router.POST("/homework/delete", route(routeHomeworkDelete, authLevelLoggedIn))
-	router.POST("/homework/markOverdueDone", route(routeHomeworkMarkOverdueDone, authLevelLoggedIn))
-
-	router.POST("/internal/startTask", route(routeInternalStartTask, authLevelInternal))
-
-	router.POST("/notifications/add", route(routeNotificationsAdd, authLevelAdmin))
-	router.POST("/notifications/delete", route(routeNotificationsDelete, authLevelAdmin))
-	router.GET("/notifications/get", route(routeNotificationsGet, authLevelLoggedIn))
-
-	router.GET("/planner/getWeekInfo/:date", route(routePlannerGetWeekInfo, authLevelLoggedIn))
-
-	router.GET("/prefixes/getDefaultList", route(routePrefixesGetDefaultList, authLevelNone))
-	router.GET("/prefixes/getList", route(routePrefixesGetList, authLevelLoggedIn))
-	router.POST("/prefixes/delete", route(routePrefixesDelete, authLevelLoggedIn))
-	router.POST("/prefixes/add", route(routePrefixesAdd, authLevelLoggedIn))
-
-	router.GET("/prefs/get/:key", route(routePrefsGet, authLevelLoggedIn))
-	router.GET("/prefs/getAll", route(routePrefsGetAll, authLevelLoggedIn))
-	router.POST("/prefs/set", route(routePrefsSet, authLevelLoggedIn))
-
-	router.POST("/schools/enroll", route(routeSchoolsEnroll, authLevelLoggedIn))
-	router.GET("/schools/lookup", route(routeSchoolsLookup, authLevelLoggedIn))
-	router.POST("/schools/setEnabled", route(routeSchoolsSetEnabled, authLevelLoggedIn))
-	router.POST("/schools/unenroll", route(routeSchoolsUnenroll, authLevelLoggedIn))
-
-	router.POST("/schools/settings/callMethod", route(routeSchoolsSettingsCallMethod, authLevelLoggedIn))
-	router.GET("/schools/settings/get", route(routeSchoolsSettingsGet, authLevelLoggedIn))
-	router.POST("/schools/settings/set", route(routeSchoolsSettingsSet, authLevelLoggedIn))
+	router.GET("/status", route(routeStatus, authLevelNone, scopeAny))
+
+	router.GET("/admin/getAllFeedback", route(routeAdminGetAllFeedback, authLevelAdmin, scopeSessionOnly))
+	router.GET("/admin/getAuditLog", route(routeAdminGetAuditLog, authLevelAdmin, scopeSessionOnly))
+	router.GET("/admin/getFeedbackScreenshot/:id", route(routeAdminGetFeedbackScreenshot, authLevelAdmin, scopeSessionOnly))
+	router.GET("/admin/getUserCount", route(routeAdminGetUserCount, authLevelAdmin, scopeSessionOnly))
+	router.POST("/admin/sendEmail", route(routeAdminSendEmail, authLevelAdmin, scopeSessionOnly))
+	router.POST("/admin/triggerError", route(routeAdminTriggerError, authLevelAdmin, scopeSessionOnly))
+
+	router.POST("/application/completeAuth", route(routeApplicationCompleteAuth, authLevelLoggedIn, scopeSessionOnly))
+	router.GET("/application/get/:id", route(routeApplicationGet, authLevelLoggedIn, scopeSessionOnly))
+	router.GET("/application/getLogo/:id", route(routeApplicationGetLogo, authLevelNone, scopeAny))
+	router.GET("/application/getAuthorizations", route(routeApplicationGetAuthorizations, authLevelLoggedIn, scopeSessionOnly))
+	router.GET("/application/requestAuth/:id", route(routeApplicationRequestAuth, authLevelNone, scopeAny))
+	router.POST("/application/revokeAuth", route(routeApplicationRevokeAuth, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/application/revokeSelf", route(routeApplicationRevokeSelf, authLevelLoggedIn, scopeAny))
+	router.GET("/application/scopes", route(routeApplicationGetScopes, authLevelNone, scopeAny))
+
+	router.POST("/application/manage/create", route(routeApplicationManageCreate, authLevelLoggedIn, scopeSessionOnly))
+	router.GET("/application/manage/getAll", route(routeApplicationManageGetAll, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/application/manage/update", route(routeApplicationManageUpdate, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/application/manage/delete", route(routeApplicationManageDelete, authLevelLoggedIn, scopeSessionOnly))
+	router.GET("/application/manage/getStats", route(routeApplicationManageGetStats, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/application/manage/rotateSecret", route(routeApplicationManageRotateSecret, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/application/manage/setLogo", route(routeApplicationManageSetLogo, authLevelLoggedIn, scopeSessionOnly))
+
+	router.POST("/auth/changeEmail", route(routeAuthChangeEmail, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/auth/changeName", route(routeAuthChangeName, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/auth/changePassword", route(rateLimited(routeAuthChangePassword, confirmRateLimit), authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/auth/clearMigrateFlag", route(routeAuthClearMigrateFlag, authLevelLoggedIn, scopeSessionOnly))
+	router.GET("/auth/completeEmailStart/:token", route(routeAuthCompleteEmailStart, authLevelNone, scopeAny))
+	router.POST("/auth/completeEmail", route(routeAuthCompleteEmail, authLevelNone, scopeAny))
+	router.GET("/auth/context", route(routeAuthContext, authLevelLoggedIn, data.ScopePrefs))
+	router.GET("/auth/getAuditLog", route(routeAuthGetAuditLog, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/auth/createAccount", route(routeAuthCreateAccount, authLevelNone, scopeAny))
+	router.GET("/auth/csrf", route(routeAuthCsrf, authLevelNone, scopeAny))
+	router.POST("/auth/login", route(rateLimited(routeAuthLogin, loginRateLimit), authLevelNone, scopeAny))
+	router.GET("/auth/me", route(routeAuthMe, authLevelLoggedIn, scopeAny))
+	router.GET("/auth/logout", route(routeAuthLogout, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/auth/requestAccountDelete", route(rateLimited(routeAuthRequestAccountDelete, confirmRateLimit), authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/auth/resetPassword", route(rateLimited(routeAuthResetPassword, resetRateLimit), authLevelNone, scopeAny))
+	router.POST("/auth/resendVerificationEmail", route(routeAuthResendVerificationEmail, authLevelNone, scopeAny))
+	router.GET("/auth/session", route(routeAuthSession, authLevelNone, scopeAny))
+
+	router.POST("/auth/2fa/beginEnroll", route(routeAuth2faBeginEnroll, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/auth/2fa/completeEnroll", route(rateLimited(routeAuth2faCompleteEnroll, confirmRateLimit), authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/auth/2fa/regenerateRecoveryCodes", route(rateLimited(routeAuth2faRegenerateRecoveryCodes, confirmRateLimit), authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/auth/2fa/requestReset", route(rateLimited(routeAuth2faRequestReset, resetRateLimit), authLevelNone, scopeAny))
+	router.GET("/auth/2fa/status", route(routeAuth2faStatus, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/auth/2fa/unenroll", route(rateLimited(routeAuth2faUnenroll, confirmRateLimit), authLevelLoggedIn, scopeSessionOnly))
+
+	router.GET("/auth/sessions/getAll", route(routeAuthSessionsGetAll, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/auth/sessions/revoke", route(routeAuthSessionsRevoke, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/auth/sessions/revokeOthers", route(routeAuthSessionsRevokeOthers, authLevelLoggedIn, scopeSessionOnly))
+
+	router.POST("/auth/tokens/create", route(routeAuthTokensCreate, authLevelLoggedIn, scopeSessionOnly))
+	router.GET("/auth/tokens/getAll", route(routeAuthTokensGetAll, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/auth/tokens/revoke", route(routeAuthTokensRevoke, authLevelLoggedIn, scopeSessionOnly))
+
+	router.POST("/auth/webauthn/beginLogin", route(routeAuthWebauthnBeginLogin, authLevelNone, scopeAny))
+	router.POST("/auth/webauthn/beginRegister", route(routeAuthWebauthnBeginRegister, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/auth/webauthn/completeRegister", route(routeAuthWebauthnCompleteRegister, authLevelLoggedIn, scopeSessionOnly))
+	router.GET("/auth/webauthn/getKeys", route(routeAuthWebauthnGetKeys, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/auth/webauthn/removeKey", route(rateLimited(routeAuthWebauthnRemoveKey, confirmRateLimit), authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/auth/webauthn/renameKey", route(routeAuthWebauthnRenameKey, authLevelLoggedIn, scopeSessionOnly))
+
+	router.GET("/calendar/getStatus", route(routeCalendarGetStatus, authLevelLoggedIn, data.ScopeCalendarRead))
+	router.GET("/calendar/getView", route(routeCalendarGetView, authLevelLoggedIn, data.ScopeCalendarRead))
+
+	router.GET("/calendar/events/getWeek/:monday", route(routeCalendarEventsGetWeek, authLevelLoggedIn, data.ScopeCalendarRead))
+
+	router.POST("/calendar/events/add", route(routeCalendarEventsAdd, authLevelLoggedIn, data.ScopeCalendarWrite))
+	router.POST("/calendar/events/edit", route(routeCalendarEventsEdit, authLevelLoggedIn, data.ScopeCalendarWrite))
+	router.POST("/calendar/events/delete", route(routeCalendarEventsDelete, authLevelLoggedIn, data.ScopeCalendarWrite))
+
+	router.POST("/calendar/hwEvents/add", route(routeCalendarHWEventsAdd, authLevelLoggedIn, data.ScopeCalendarWrite))
+	router.POST("/calendar/hwEvents/edit", route(routeCalendarHWEventsEdit, authLevelLoggedIn, data.ScopeCalendarWrite))
+	router.POST("/calendar/hwEvents/delete", route(routeCalendarHWEventsDelete, authLevelLoggedIn, data.ScopeCalendarWrite))
+
+	router.GET("/calendar/eventChanges/get", route(routeCalendarEventChangesGet, authLevelLoggedIn, data.ScopeCalendarRead))
+	router.POST("/calendar/eventChanges/set", route(routeCalendarEventChangesSet, authLevelLoggedIn, data.ScopeCalendarWrite))
+
+	router.GET("/classes/get", route(routeClassesGet, authLevelLoggedIn, data.ScopeClassesRead))
+	router.GET("/classes/get/:id", route(routeClassesGetID, authLevelLoggedIn, data.ScopeClassesRead))
+	router.GET("/classes/getDetails/:id", route(routeClassesGetDetails, authLevelLoggedIn, data.ScopeClassesRead))
+	router.GET("/classes/hwInfo/:id", route(routeClassesHWInfo, authLevelLoggedIn, data.ScopeClassesRead))
+	router.POST("/classes/add", route(routeClassesAdd, authLevelLoggedIn, data.ScopeClassesWrite))
+	router.POST("/classes/edit", route(routeClassesEdit, authLevelLoggedIn, data.ScopeClassesWrite))
+	router.POST("/classes/delete", route(routeClassesDelete, authLevelLoggedIn, data.ScopeClassesWrite))
+	router.POST("/classes/setArchived", route(routeClassesSetArchived, authLevelLoggedIn, data.ScopeClassesWrite))
+	router.POST("/classes/setLinks", route(routeClassesSetLinks, authLevelLoggedIn, data.ScopeClassesWrite))
+	router.POST("/classes/swap", route(routeClassesSwap, authLevelLoggedIn, data.ScopeClassesWrite))
+	router.POST("/classes/contacts/add", route(routeClassesContactsAdd, authLevelLoggedIn, data.ScopeClassesWrite))
+	router.POST("/classes/contacts/edit", route(routeClassesContactsEdit, authLevelLoggedIn, data.ScopeClassesWrite))
+	router.POST("/classes/contacts/delete", route(routeClassesContactsDelete, authLevelLoggedIn, data.ScopeClassesWrite))
+	router.POST("/classes/officeHours/add", route(routeClassesOfficeHoursAdd, authLevelLoggedIn, data.ScopeClassesWrite))
+	router.POST("/classes/officeHours/edit", route(routeClassesOfficeHoursEdit, authLevelLoggedIn, data.ScopeClassesWrite))
+	router.POST("/classes/officeHours/delete", route(routeClassesOfficeHoursDelete, authLevelLoggedIn, data.ScopeClassesWrite))
+
+	router.GET("/digest/getSettings", route(routeDigestGetSettings, authLevelLoggedIn, data.ScopePrefs))
+	router.GET("/digest/unsubscribe/:token", route(routeDigestUnsubscribeConfirm, authLevelNone, scopeAny))
+	router.POST("/digest/setSettings", route(routeDigestSetSettings, authLevelLoggedIn, data.ScopePrefs))
+	router.POST("/digest/unsubscribe/:token", route(routeDigestUnsubscribe, authLevelNone, scopeAny))
+
+	router.GET("/export/:type", route(routeExport, authLevelLoggedIn, scopeSessionOnly))
+
+	router.POST("/feedback/add", route(routeFeedbackAdd, authLevelLoggedIn, scopeSessionOnly))
+
+	router.GET("/grades/get/:classId", route(routeGradesGet, authLevelLoggedIn, data.ScopeGradesRead))
+	router.GET("/grades/getSummaries", route(routeGradesGetSummaries, authLevelLoggedIn, data.ScopeGradesRead))
+	router.GET("/grades/project", route(routeGradesProject, authLevelLoggedIn, data.ScopeGradesRead))
+	router.POST("/grades/categories/add", route(routeGradesCategoriesAdd, authLevelLoggedIn, data.ScopeGradesWrite))
+	router.POST("/grades/categories/edit", route(routeGradesCategoriesEdit, authLevelLoggedIn, data.ScopeGradesWrite))
+	router.POST("/grades/categories/delete", route(routeGradesCategoriesDelete, authLevelLoggedIn, data.ScopeGradesWrite))
+	router.POST("/grades/items/add", route(routeGradesItemsAdd, authLevelLoggedIn, data.ScopeGradesWrite))
+	router.POST("/grades/items/edit", route(routeGradesItemsEdit, authLevelLoggedIn, data.ScopeGradesWrite))
+	router.POST("/grades/items/delete", route(routeGradesItemsDelete, authLevelLoggedIn, data.ScopeGradesWrite))
+
+	router.GET("/homework/get", route(routeHomeworkGet, authLevelLoggedIn, data.ScopeHomeworkRead))
+	router.GET("/homework/getForClass/:classId", route(routeHomeworkGetForClass, authLevelLoggedIn, data.ScopeHomeworkRead))
+	router.GET("/homework/getHWView", route(routeHomeworkGetHWView, authLevelLoggedIn, data.ScopeHomeworkRead))
+	router.GET("/homework/getHWViewSorted", route(routeHomeworkGetHWViewSorted, authLevelLoggedIn, data.ScopeHomeworkRead))
+	router.GET("/homework/get/:id", route(routeHomeworkGetID, authLevelLoggedIn, data.ScopeHomeworkRead))
+	router.GET("/homework/getEventLink/:id", route(routeHomeworkGetEventLink, authLevelLoggedIn, data.ScopeHomeworkRead))
+	router.GET("/homework/getWeek/:date", route(routeHomeworkGetWeek, authLevelLoggedIn, data.ScopeHomeworkRead))
+	router.GET("/homework/getStats", route(routeHomeworkGetStats, authLevelLoggedIn, data.ScopeHomeworkRead))
+	router.GET("/homework/getPickerSuggestions", route(routeHomeworkGetPickerSuggestions, authLevelLoggedIn, data.ScopeHomeworkRead))
+	router.GET("/homework/search", route(routeHomeworkSearch, authLevelLoggedIn, data.ScopeHomeworkRead))
+	router.GET("/homework/time/get/:id", route(routeHomeworkTimeGet, authLevelLoggedIn, data.ScopeHomeworkRead))
+	router.GET("/homework/time/getActive", route(routeHomeworkTimeGetActive, authLevelLoggedIn, data.ScopeHomeworkRead))
+	router.GET("/homework/time/getReport", route(routeHomeworkTimeGetReport, authLevelLoggedIn, data.ScopeHomeworkRead))
+	router.POST("/homework/add", route(routeHomeworkAdd, authLevelLoggedIn, data.ScopeHomeworkWrite))
+	router.POST("/homework/edit", route(routeHomeworkEdit, authLevelLoggedIn, data.ScopeHomeworkWrite))
+	router.POST("/homework/delete", route(routeHomeworkDelete, authLevelLoggedIn, data.ScopeHomeworkWrite))
+	router.POST("/homework/bulk", route(routeHomeworkBulk, authLevelLoggedIn, data.ScopeHomeworkWrite))
+	router.POST("/homework/markOverdueDone", route(routeHomeworkMarkOverdueDone, authLevelLoggedIn, data.ScopeHomeworkWrite))
+	router.POST("/homework/linkEvent", route(routeHomeworkLinkEvent, authLevelLoggedIn, data.ScopeHomeworkWrite))
+	router.POST("/homework/unlinkEvent", route(routeHomeworkUnlinkEvent, authLevelLoggedIn, data.ScopeHomeworkWrite))
+	router.POST("/homework/time/start", route(routeHomeworkTimeStart, authLevelLoggedIn, data.ScopeHomeworkWrite))
+	router.POST("/homework/time/pause", route(routeHomeworkTimePause, authLevelLoggedIn, data.ScopeHomeworkWrite))
+	router.POST("/homework/time/resume", route(routeHomeworkTimeResume, authLevelLoggedIn, data.ScopeHomeworkWrite))
+	router.POST("/homework/time/stop", route(routeHomeworkTimeStop, authLevelLoggedIn, data.ScopeHomeworkWrite))
+	router.POST("/homework/time/delete", route(routeHomeworkTimeDelete, authLevelLoggedIn, data.ScopeHomeworkWrite))
+	router.POST("/homework/time/logEvent", route(routeHomeworkTimeLogEvent, authLevelLoggedIn, data.ScopeHomeworkWrite))
+	router.POST("/homework/time/setEstimate", route(routeHomeworkTimeSetEstimate, authLevelLoggedIn, data.ScopeHomeworkWrite))
+
+	router.POST("/import/:type", route(routeImport, authLevelLoggedIn, scopeSessionOnly))
+
+	router.POST("/internal/startTask", route(routeInternalStartTask, authLevelInternal, scopeSessionOnly))
+
+	router.POST("/notifications/add", route(routeNotificationsAdd, authLevelAdmin, scopeSessionOnly))
+	router.POST("/notifications/delete", route(routeNotificationsDelete, authLevelAdmin, scopeSessionOnly))
+	router.GET("/notifications/get", route(routeNotificationsGet, authLevelLoggedIn, scopeAny))
+
+	router.GET("/oauth/authorize", route(routeOAuthAuthorize, authLevelNone, scopeAny))
+	router.POST("/oauth/approve", route(routeOAuthApprove, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/oauth/introspect", route(rateLimited(routeOAuthIntrospect, oauthClientRateLimit), authLevelNone, scopeAny))
+	router.POST("/oauth/revoke", route(rateLimited(routeOAuthRevoke, oauthClientRateLimit), authLevelNone, scopeAny))
+	router.POST("/oauth/token", route(rateLimited(routeOAuthToken, oauthClientRateLimit), authLevelNone, scopeAny))
+
+	router.GET("/planner/getWeekInfo/:date", route(routePlannerGetWeekInfo, authLevelLoggedIn, data.ScopeHomeworkRead))
+
+	router.GET("/prefixes/getDefaultList", route(routePrefixesGetDefaultList, authLevelNone, scopeAny))
+	router.GET("/prefixes/getList", route(routePrefixesGetList, authLevelLoggedIn, data.ScopePrefs))
+	router.GET("/prefixes/match", route(routePrefixesMatch, authLevelLoggedIn, data.ScopePrefs))
+	router.POST("/prefixes/delete", route(routePrefixesDelete, authLevelLoggedIn, data.ScopePrefs))
+	router.POST("/prefixes/add", route(routePrefixesAdd, authLevelLoggedIn, data.ScopePrefs))
+	router.POST("/prefixes/edit", route(routePrefixesEdit, authLevelLoggedIn, data.ScopePrefs))
+	router.GET("/prefixes/packs/browse", route(routePrefixesPacksBrowse, authLevelLoggedIn, data.ScopePrefs))
+	router.GET("/prefixes/packs/get/:id", route(routePrefixesPacksGet, authLevelLoggedIn, data.ScopePrefs))
+	router.GET("/prefixes/packs/getMine", route(routePrefixesPacksGetMine, authLevelLoggedIn, data.ScopePrefs))
+	router.POST("/prefixes/packs/publish", route(routePrefixesPacksPublish, authLevelLoggedIn, data.ScopePrefs))
+	router.POST("/prefixes/packs/edit", route(routePrefixesPacksEdit, authLevelLoggedIn, data.ScopePrefs))
+	router.POST("/prefixes/packs/delete", route(routePrefixesPacksDelete, authLevelLoggedIn, data.ScopePrefs))
+	router.POST("/prefixes/packs/subscribe", route(routePrefixesPacksSubscribe, authLevelLoggedIn, data.ScopePrefs))
+	router.POST("/prefixes/packs/unsubscribe", route(routePrefixesPacksUnsubscribe, authLevelLoggedIn, data.ScopePrefs))
+
+	router.GET("/prefs/get/:key", route(routePrefsGet, authLevelLoggedIn, data.ScopePrefs))
+	router.GET("/prefs/getAll", route(routePrefsGetAll, authLevelLoggedIn, data.ScopePrefs))
+	router.POST("/prefs/set", route(routePrefsSet, authLevelLoggedIn, data.ScopePrefs))
+
+	router.POST("/schools/enroll", route(routeSchoolsEnroll, authLevelLoggedIn, scopeSessionOnly))
+	router.GET("/schools/lookup", route(routeSchoolsLookup, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/schools/setEnabled", route(routeSchoolsSetEnabled, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/schools/setSyncClasses", route(routeSchoolsSetSyncClasses, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/schools/unenroll", route(routeSchoolsUnenroll, authLevelLoggedIn, scopeSessionOnly))
+
+	router.POST("/schools/settings/callMethod", route(routeSchoolsSettingsCallMethod, authLevelLoggedIn, scopeSessionOnly))
+	router.GET("/schools/settings/get", route(routeSchoolsSettingsGet, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/schools/settings/set", route(routeSchoolsSettingsSet, authLevelLoggedIn, scopeSessionOnly))
+
+	router.GET("/shares/get", route(routeSharesGet, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/shares/add", route(routeSharesAdd, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/shares/accept", route(routeSharesAccept, authLevelLoggedIn, scopeSessionOnly))
+	router.POST("/shares/delete", route(routeSharesDelete, authLevelLoggedIn, scopeSessionOnly))
+
+	router.GET("/terms/get", route(routeTermsGet, authLevelLoggedIn, data.ScopeClassesRead))
+	router.POST("/terms/start", route(routeTermsStart, authLevelLoggedIn, data.ScopeClassesWrite))
+
+	router.GET("/trash/get", route(routeTrashGet, authLevelLoggedIn, data.ScopeHomeworkRead))
+	router.POST("/trash/restore", route(routeTrashRestore, authLevelLoggedIn, data.ScopeHomeworkWrite))
+	router.POST("/trash/delete", route(routeTrashDelete, authLevelLoggedIn, data.ScopeHomeworkWrite))
+
+	// applications can manage their own webhooks, but only subscribe to events that their scopes cover
+	router.GET("/webhooks/getAll", route(routeWebhooksGetAll, authLevelLoggedIn, scopeAny))
+	router.GET("/webhooks/getEvents", route(routeWebhooksGetEvents, authLevelLoggedIn, scopeAny))
+	router.GET("/webhooks/getDeliveries", route(routeWebhooksGetDeliveries, authLevelLoggedIn, scopeAny))
+	router.POST("/webhooks/create", route(routeWebhooksCreate, authLevelLoggedIn, scopeAny))
+	router.POST("/webhooks/update", route(routeWebhooksUpdate, authLevelLoggedIn, scopeAny))
+	router.POST("/webhooks/delete", route(routeWebhooksDelete, authLevelLoggedIn, scopeAny))
+	router.POST("/webhooks/redeliver", route(routeWebhooksRedeliver, authLevelLoggedIn, scopeAny))
 }
@@ -0,0 +1,534 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/config"
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// The oauth endpoints follow RFC 6749 (OAuth 2.0), RFC 7636 (PKCE), RFC 7009 (revocation), and RFC 7662 (introspection).
+// Because of that, the ones that applications call respond in the format those describe, and not with our usual status and error fields.
+
+type oauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+type oauthIntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Expires   int    `json:"exp,omitempty"`
+	IssuedAt  int    `json:"iat,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+}
+
+type oauthRedirectResponse struct {
+	Status      string `json:"status"`
+	RedirectURI string `json:"redirectUri"`
+}
+
+/*
+ * helpers
+ */
+
+func writeOAuthJSON(w http.ResponseWriter, status int, thing interface{}) {
+	// responses can have tokens in them, so they shouldn't be cached anywhere
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	writeJSON(w, status, thing)
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, errorCode string, description string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Basic realm=\"MyHomeworkSpace\"")
+	}
+	writeOAuthJSON(w, status, oauthErrorResponse{errorCode, description})
+}
+
+func writeOAuthServerError(w http.ResponseWriter, desc string, err error) {
+	errorlog.LogError(desc, err)
+	writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+}
+
+// getOAuthClient finds the application that's calling one of the oauth endpoints, and checks its client secret if it has one.
+// Applications can send their credentials with HTTP basic auth or as form values, as described in RFC 6749, section 2.3.1.
+// If the application can't be authenticated, this returns false.
+func getOAuthClient(r *http.Request) (data.Application, bool, error) {
+	clientID, clientSecret, hasBasicAuth := r.BasicAuth()
+	if hasBasicAuth {
+		// the credentials are form encoded before going in the header
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.FormValue("client_id")
+		clientSecret = r.FormValue("client_secret")
+	}
+
+	if clientID == "" {
+		return data.Application{}, false, nil
+	}
+
+	application, err := data.GetApplicationByClientID(clientID)
+	if err == data.ErrNotFound {
+		return data.Application{}, false, nil
+	} else if err != nil {
+		return data.Application{}, false, err
+	}
+
+	if application.IsConfidential() && !application.CheckClientSecret(clientSecret) {
+		return data.Application{}, false, nil
+	}
+
+	return application, true, nil
+}
+
+// getOAuthAuthorizeApplication checks the application and redirect URI of a request to the authorization endpoint.
+// If something's wrong with them, there's nowhere safe to redirect back to, so it returns an error code to show the user instead.
+func getOAuthAuthorizeApplication(r *http.Request) (data.Application, string, string, error) {
+	if r.FormValue("client_id") == "" {
+		return data.Application{}, "", "missing_params", nil
+	}
+
+	application, err := data.GetApplicationByClientID(r.FormValue("client_id"))
+	if err == data.ErrNotFound {
+		return data.Application{}, "", "not_found", nil
+	} else if err != nil {
+		return data.Application{}, "", "", err
+	}
+
+	// the redirect uri has to exactly match a registered one, so that codes can't be sent anywhere else
+	redirectURI := r.FormValue("redirect_uri")
+	if redirectURI == "" {
+		// it can only be left out if there's no question about where it should go
+		if len(application.RedirectURIs) != 1 {
+			return data.Application{}, "", "invalid_params", nil
+		}
+		redirectURI = application.RedirectURIs[0]
+	} else if !application.HasRedirectURI(redirectURI) {
+		return data.Application{}, "", "invalid_params", nil
+	}
+
+	if !isValidRedirectURI(redirectURI) {
+		return data.Application{}, "", "invalid_params", nil
+	}
+
+	return application, redirectURI, "", nil
+}
+
+// getOAuthRedirect adds the given parameters to the query string of a redirect URI, keeping any that it already had.
+func getOAuthRedirect(redirectURI string, params map[string]string) string {
+	parsedURI, _ := url.Parse(redirectURI)
+
+	query := parsedURI.Query()
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	parsedURI.RawQuery = query.Encode()
+
+	return parsedURI.String()
+}
+
+// getPKCEChallenge returns the S256 code challenge that matches the given code verifier.
+func getPKCEChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func issueOAuthTokens(w http.ResponseWriter, authorizationID int, scopes []data.Scope, replacing int) {
+	tx, err := DB.Begin()
+	if err != nil {
+		writeOAuthServerError(w, "issuing oauth tokens", err)
+		return
+	}
+
+	accessToken, refreshToken, err := data.IssueOAuthTokens(tx, authorizationID, replacing, time.Now())
+	if err == data.ErrNotFound {
+		tx.Rollback()
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "The refresh token has already been used.")
+		return
+	} else if err != nil {
+		tx.Rollback()
+		writeOAuthServerError(w, "issuing oauth tokens", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		writeOAuthServerError(w, "issuing oauth tokens", err)
+		return
+	}
+
+	writeOAuthJSON(w, http.StatusOK, oauthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(data.OAuthAccessTokenLifetime.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        data.FormatScopes(scopes),
+	})
+}
+
+func handleOAuthAuthorizationCodeGrant(w http.ResponseWriter, r *http.Request, application data.Application) {
+	if r.FormValue("code") == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "The code parameter is missing.")
+		return
+	}
+
+	code, err := data.UseOAuthCode(r.FormValue("code"))
+	if err == data.ErrNotFound {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "The authorization code is invalid or expired.")
+		return
+	} else if err != nil {
+		writeOAuthServerError(w, "using oauth code", err)
+		return
+	}
+
+	if code.ApplicationID != application.ID {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "The authorization code is invalid or expired.")
+		return
+	}
+
+	if code.RedirectURI != "" && code.RedirectURI != r.FormValue("redirect_uri") {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "The redirect_uri does not match the authorization request.")
+		return
+	}
+
+	if code.CodeChallenge != "" {
+		verifier := r.FormValue("code_verifier")
+		if verifier == "" || subtle.ConstantTimeCompare([]byte(getPKCEChallenge(verifier)), []byte(code.CodeChallenge)) != 1 {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "The code_verifier does not match the code_challenge.")
+			return
+		}
+	}
+
+	// make sure the user didn't revoke the application in the meantime
+	rows, err := DB.Query("SELECT scopes FROM application_authorizations WHERE id = ?", code.AuthorizationID)
+	if err != nil {
+		writeOAuthServerError(w, "checking oauth authorization", err)
+		return
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "The authorization has been revoked.")
+		return
+	}
+
+	scopes := ""
+	err = rows.Scan(&scopes)
+	if err != nil {
+		writeOAuthServerError(w, "checking oauth authorization", err)
+		return
+	}
+
+	// tokens can do everything the user has allowed, which can be more than this code asked for
+	authorizedScopes, _ := data.ParseScopes(scopes)
+	issueOAuthTokens(w, code.AuthorizationID, authorizedScopes, -1)
+}
+
+func handleOAuthRefreshTokenGrant(w http.ResponseWriter, r *http.Request, application data.Application) {
+	if r.FormValue("refresh_token") == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "The refresh_token parameter is missing.")
+		return
+	}
+
+	token, err := data.GetOAuthToken(r.FormValue("refresh_token"))
+	if err == data.ErrNotFound {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "The refresh token is invalid or expired.")
+		return
+	} else if err != nil {
+		writeOAuthServerError(w, "getting oauth refresh token", err)
+		return
+	}
+
+	if token.Type != data.OAuthTokenRefresh || token.ApplicationID != application.ID || int64(token.ExpiresAt) <= time.Now().Unix() {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "The refresh token is invalid or expired.")
+		return
+	}
+
+	issueOAuthTokens(w, token.AuthorizationID, token.Scopes, token.ID)
+}
+
+/*
+ * routes
+ */
+
+func routeOAuthAuthorize(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	_, _, errorCode, err := getOAuthAuthorizeApplication(r)
+	if err != nil {
+		errorlog.LogError("checking oauth authorization request", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	if errorCode != "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", errorCode})
+		return
+	}
+
+	// the app asks the user, and then sends everything back to routeOAuthApprove
+	http.Redirect(w, r, config.GetCurrent().Server.AppURLBase+"oauthAuthorize:"+base64.URLEncoding.EncodeToString([]byte(r.URL.RawQuery)), http.StatusFound)
+}
+
+func routeOAuthApprove(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	application, redirectURI, errorCode, err := getOAuthAuthorizeApplication(r)
+	if err != nil {
+		errorlog.LogError("checking oauth authorization request", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	if errorCode != "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", errorCode})
+		return
+	}
+
+	// from here on, problems are reported to the application through the redirect
+	state := r.FormValue("state")
+	redirectWithError := func(oauthError string, description string) {
+		writeJSON(w, http.StatusOK, oauthRedirectResponse{"ok", getOAuthRedirect(redirectURI, map[string]string{
+			"error":             oauthError,
+			"error_description": description,
+			"state":             state,
+		})})
+	}
+
+	if r.FormValue("response_type") != "code" {
+		redirectWithError("unsupported_response_type", "Only the code response type is supported.")
+		return
+	}
+
+	codeChallenge := r.FormValue("code_challenge")
+	if codeChallenge != "" && r.FormValue("code_challenge_method") != "S256" {
+		redirectWithError("invalid_request", "Only the S256 code_challenge_method is supported.")
+		return
+	}
+
+	if codeChallenge == "" && !application.IsConfidential() {
+		redirectWithError("invalid_request", "Applications without a client secret must use PKCE.")
+		return
+	}
+
+	// if the application doesn't say what it wants, it gets everything it's registered for
+	scopes := application.Scopes
+	if r.FormValue("scope") != "" {
+		scopes, err = data.ParseScopes(r.FormValue("scope"))
+		if err != nil || !data.ScopesContainAll(application.Scopes, scopes) {
+			redirectWithError("invalid_scope", "The application is not registered for the requested scope.")
+			return
+		}
+	}
+
+	if r.FormValue("allow") != "true" {
+		redirectWithError("access_denied", "The user denied the request.")
+		return
+	}
+
+	authorizationID, changed, err := saveApplicationAuthorization(application.ID, c.User.ID, scopes)
+	if err != nil {
+		errorlog.LogError("authorizing application", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	code, err := data.SaveOAuthCode(data.OAuthCode{
+		AuthorizationID: authorizationID,
+		ApplicationID:   application.ID,
+		UserID:          c.User.ID,
+		RedirectURI:     r.FormValue("redirect_uri"),
+		CodeChallenge:   codeChallenge,
+	})
+	if err != nil {
+		errorlog.LogError("saving oauth code", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	if changed {
+		logAuditEvent(r, c.User.ID, data.AuditEventApplicationAuthorize, map[string]interface{}{
+			"applicationId": application.ID,
+			"name":          application.Name,
+			"scopes":        data.FormatScopes(scopes),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, oauthRedirectResponse{"ok", getOAuthRedirect(redirectURI, map[string]string{
+		"code":  code,
+		"state": state,
+	})})
+}
+
+func routeOAuthToken(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	application, authenticated, err := getOAuthClient(r)
+	if err != nil {
+		writeOAuthServerError(w, "authenticating oauth client", err)
+		return
+	}
+
+	if !authenticated {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed.")
+		return
+	}
+
+	switch r.FormValue("grant_type") {
+	case "authorization_code":
+		handleOAuthAuthorizationCodeGrant(w, r, application)
+	case "refresh_token":
+		handleOAuthRefreshTokenGrant(w, r, application)
+	case "":
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "The grant_type parameter is missing.")
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+	}
+}
+
+func routeOAuthRevoke(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	application, authenticated, err := getOAuthClient(r)
+	if err != nil {
+		writeOAuthServerError(w, "authenticating oauth client", err)
+		return
+	}
+
+	if !authenticated {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed.")
+		return
+	}
+
+	if r.FormValue("token") == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "The token parameter is missing.")
+		return
+	}
+
+	// tokens that don't exist or belong to someone else are ignored, so that this can't be used to tell if a token is valid
+	token, err := data.GetOAuthToken(r.FormValue("token"))
+	if err == nil {
+		if token.ApplicationID == application.ID {
+			err = data.RevokeOAuthToken(token.ID)
+			if err != nil {
+				writeOAuthServerError(w, "revoking oauth token", err)
+				return
+			}
+		}
+	} else if err == data.ErrNotFound {
+		// it could be an old-style token, which is the same thing as the whole authorization
+		rows, err := DB.Query("SELECT id FROM application_authorizations WHERE token = ? AND applicationId = ?", r.FormValue("token"), application.ID)
+		if err != nil {
+			writeOAuthServerError(w, "revoking oauth token", err)
+			return
+		}
+		defer rows.Close()
+
+		if rows.Next() {
+			authorizationID := -1
+			rows.Scan(&authorizationID)
+
+			err = deleteApplicationAuthorization(authorizationID)
+			if err != nil {
+				writeOAuthServerError(w, "revoking oauth token", err)
+				return
+			}
+		}
+	} else {
+		writeOAuthServerError(w, "getting oauth token", err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+func routeOAuthIntrospect(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	application, authenticated, err := getOAuthClient(r)
+	if err != nil {
+		writeOAuthServerError(w, "authenticating oauth client", err)
+		return
+	}
+
+	if !authenticated {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed.")
+		return
+	}
+
+	if r.FormValue("token") == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "The token parameter is missing.")
+		return
+	}
+
+	// applications only get to find out about their own tokens
+	token, err := data.GetOAuthToken(r.FormValue("token"))
+	if err == data.ErrNotFound {
+		rows, err := DB.Query("SELECT userId, scopes FROM application_authorizations WHERE token = ? AND applicationId = ?", r.FormValue("token"), application.ID)
+		if err != nil {
+			writeOAuthServerError(w, "introspecting oauth token", err)
+			return
+		}
+		defer rows.Close()
+
+		if !rows.Next() {
+			writeOAuthJSON(w, http.StatusOK, oauthIntrospectionResponse{Active: false})
+			return
+		}
+
+		userID := -1
+		scopes := ""
+		err = rows.Scan(&userID, &scopes)
+		if err != nil {
+			writeOAuthServerError(w, "introspecting oauth token", err)
+			return
+		}
+
+		// old-style tokens never expire
+		writeOAuthJSON(w, http.StatusOK, oauthIntrospectionResponse{
+			Active:    true,
+			Scope:     scopes,
+			ClientID:  application.ClientID,
+			TokenType: "Bearer",
+			Subject:   strconv.Itoa(userID),
+		})
+		return
+	} else if err != nil {
+		writeOAuthServerError(w, "introspecting oauth token", err)
+		return
+	}
+
+	if token.ApplicationID != application.ID || int64(token.ExpiresAt) <= time.Now().Unix() {
+		writeOAuthJSON(w, http.StatusOK, oauthIntrospectionResponse{Active: false})
+		return
+	}
+
+	tokenType := ""
+	if token.Type == data.OAuthTokenAccess {
+		tokenType = "Bearer"
+	}
+
+	writeOAuthJSON(w, http.StatusOK, oauthIntrospectionResponse{
+		Active:    true,
+		Scope:     data.FormatScopes(token.Scopes),
+		ClientID:  application.ClientID,
+		TokenType: tokenType,
+		Expires:   token.ExpiresAt,
+		IssuedAt:  token.CreatedAt,
+		Subject:   strconv.Itoa(token.UserID),
+	})
+}
@@ -0,0 +1,389 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// responses
+type prefixPacksResponse struct {
+	Status string            `json:"status"`
+	Packs  []data.PrefixPack `json:"packs"`
+}
+
+type prefixPackResponse struct {
+	Status     string          `json:"status"`
+	Pack       data.PrefixPack `json:"pack"`
+	Prefixes   []data.Prefix   `json:"prefixes"`
+	Subscribed bool            `json:"subscribed"`
+}
+
+type myPrefixPacksResponse struct {
+	Status     string            `json:"status"`
+	Published  []data.PrefixPack `json:"published"`
+	Subscribed []data.PrefixPack `json:"subscribed"`
+}
+
+type prefixPackIDResponse struct {
+	Status string `json:"status"`
+	ID     int    `json:"id"`
+}
+
+const maxPrefixPackSize = 100
+
+/*
+ * helpers
+ */
+
+func getPrefixPackFromForm(w http.ResponseWriter, r *http.Request, action string) (data.PrefixPack, bool) {
+	if r.FormValue("id") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return data.PrefixPack{}, false
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return data.PrefixPack{}, false
+	}
+
+	pack, err := data.GetPrefixPackByID(id)
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusNotFound, errorResponse{"error", "not_found"})
+		return data.PrefixPack{}, false
+	} else if err != nil {
+		errorlog.LogError(action, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return data.PrefixPack{}, false
+	}
+
+	return pack, true
+}
+
+// parsePrefixPackForm reads the name, description, and list of prefixes for a pack, checking that all of the prefixes belong to the current user and aren't limited to certain classes.
+func parsePrefixPackForm(w http.ResponseWriter, r *http.Request, c RouteContext, action string) (string, string, []int, bool) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	description := strings.TrimSpace(r.FormValue("description"))
+	if name == "" || r.FormValue("prefixIds") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return "", "", nil, false
+	}
+
+	prefixIDs := []int{}
+	err := json.Unmarshal([]byte(r.FormValue("prefixIds")), &prefixIDs)
+	if err != nil || len(prefixIDs) == 0 || len(prefixIDs) > maxPrefixPackSize || len(name) > 64 || len(description) > 512 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return "", "", nil, false
+	}
+
+	seenPrefixIDs := map[int]bool{}
+	for _, prefixID := range prefixIDs {
+		if seenPrefixIDs[prefixID] {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return "", "", nil, false
+		}
+		seenPrefixIDs[prefixID] = true
+
+		rows, err := DB.Query("SELECT classIds FROM prefixes WHERE userId = ? AND id = ?", c.User.ID, prefixID)
+		if err != nil {
+			errorlog.LogError(action, err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return "", "", nil, false
+		}
+		if !rows.Next() {
+			rows.Close()
+			writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+			return "", "", nil, false
+		}
+
+		classIDsString := ""
+		err = rows.Scan(&classIDsString)
+		rows.Close()
+		if err != nil {
+			errorlog.LogError(action, err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return "", "", nil, false
+		}
+
+		// prefixes limited to the author's classes wouldn't mean anything to subscribers
+		classIDs := []int{}
+		err = json.Unmarshal([]byte(classIDsString), &classIDs)
+		if err != nil || len(classIDs) > 0 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return "", "", nil, false
+		}
+	}
+
+	return name, description, prefixIDs, true
+}
+
+/*
+ * routes
+ */
+
+func routePrefixesPacksBrowse(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	query := strings.TrimSpace(r.FormValue("q"))
+	if len(query) > 64 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	packs, err := data.SearchPrefixPacks(query, 50)
+	if err != nil {
+		errorlog.LogError("browsing prefix packs", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, prefixPacksResponse{"ok", packs})
+}
+
+func routePrefixesPacksGet(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	id, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	pack, err := data.GetPrefixPackByID(id)
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusNotFound, errorResponse{"error", "not_found"})
+		return
+	} else if err != nil {
+		errorlog.LogError("getting prefix pack", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	prefixes, err := data.GetPrefixesForPack(pack.ID)
+	if err != nil {
+		errorlog.LogError("getting prefix pack", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	rows, err := DB.Query("SELECT id FROM prefix_pack_subscriptions WHERE packId = ? AND userId = ?", pack.ID, c.User.ID)
+	if err != nil {
+		errorlog.LogError("getting prefix pack", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	defer rows.Close()
+
+	writeJSON(w, http.StatusOK, prefixPackResponse{"ok", pack, prefixes, rows.Next()})
+}
+
+func routePrefixesPacksGetMine(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	published, err := data.GetPrefixPacksByAuthor(c.User.ID)
+	if err != nil {
+		errorlog.LogError("getting user's prefix packs", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	subscribed, err := data.GetPrefixPacksForSubscriber(c.User.ID)
+	if err != nil {
+		errorlog.LogError("getting user's prefix packs", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, myPrefixPacksResponse{"ok", published, subscribed})
+}
+
+func routePrefixesPacksPublish(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	name, description, prefixIDs, ok := parsePrefixPackForm(w, r, c, "publishing prefix pack")
+	if !ok {
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("publishing prefix pack", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	now := time.Now().Unix()
+	result, err := tx.Exec(
+		"INSERT INTO prefix_packs(name, description, authorId, createdAt, updatedAt) VALUES(?, ?, ?, ?, ?)",
+		name, description, c.User.ID, now, now,
+	)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("publishing prefix pack", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	packID, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("publishing prefix pack", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = data.SetPrefixPackItems(tx, int(packID), prefixIDs)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("publishing prefix pack", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("publishing prefix pack", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, prefixPackIDResponse{"ok", int(packID)})
+}
+
+func routePrefixesPacksEdit(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	pack, ok := getPrefixPackFromForm(w, r, "editing prefix pack")
+	if !ok {
+		return
+	}
+
+	if pack.AuthorID != c.User.ID {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
+
+	name, description, prefixIDs, ok := parsePrefixPackForm(w, r, c, "editing prefix pack")
+	if !ok {
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("editing prefix pack", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	_, err = tx.Exec("UPDATE prefix_packs SET name = ?, description = ?, updatedAt = ? WHERE id = ?", name, description, time.Now().Unix(), pack.ID)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("editing prefix pack", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = data.SetPrefixPackItems(tx, pack.ID, prefixIDs)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("editing prefix pack", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("editing prefix pack", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routePrefixesPacksDelete(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	pack, ok := getPrefixPackFromForm(w, r, "deleting prefix pack")
+	if !ok {
+		return
+	}
+
+	if pack.AuthorID != c.User.ID {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("deleting prefix pack", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// this also unsubscribes everyone
+	for _, table := range []string{"prefix_pack_subscriptions", "prefix_pack_items"} {
+		_, err = tx.Exec("DELETE FROM "+table+" WHERE packId = ?", pack.ID)
+		if err != nil {
+			tx.Rollback()
+			errorlog.LogError("deleting prefix pack", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM prefix_packs WHERE id = ?", pack.ID)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("deleting prefix pack", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("deleting prefix pack", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routePrefixesPacksSubscribe(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	pack, ok := getPrefixPackFromForm(w, r, "subscribing to prefix pack")
+	if !ok {
+		return
+	}
+
+	// the author already has these prefixes
+	if pack.AuthorID == c.User.ID {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	// subscribing again keeps the original position, so that the order of packs doesn't change
+	_, err := DB.Exec(
+		"INSERT INTO prefix_pack_subscriptions(packId, userId, subscribedAt) VALUES(?, ?, ?) ON DUPLICATE KEY UPDATE packId = packId",
+		pack.ID, c.User.ID, time.Now().Unix(),
+	)
+	if err != nil {
+		errorlog.LogError("subscribing to prefix pack", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routePrefixesPacksUnsubscribe(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	pack, ok := getPrefixPackFromForm(w, r, "unsubscribing from prefix pack")
+	if !ok {
+		return
+	}
+
+	_, err := DB.Exec("DELETE FROM prefix_pack_subscriptions WHERE packId = ? AND userId = ?", pack.ID, c.User.ID)
+	if err != nil {
+		errorlog.LogError("unsubscribing from prefix pack", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
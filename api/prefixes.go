@@ -19,6 +19,13 @@ type prefixesResponse struct {
 	FallbackColor      string        `json:"fallbackColor"`
 }
 
+type prefixMatchResponse struct {
+	Status string       `json:"status"`
+	Found  bool         `json:"found"`
+	Prefix *data.Prefix `json:"prefix"`
+	Match  string       `json:"match"`
+}
+
 type schoolPrefixInfo struct {
 	School   data.SchoolResult `json:"school"`
 	Prefixes []data.Prefix     `json:"prefixes"`
@@ -32,6 +39,146 @@ type defaultPrefixesResponse struct {
 	FallbackColor      string             `json:"fallbackColor"`
 }
 
+// prefixForm holds the fields of a prefix that are shared between the add and edit routes, ready to be saved.
+type prefixForm struct {
+	Name       string
+	Words      string
+	Color      string
+	Background string
+	TimedEvent int
+	Regex      int
+	ClassIDs   string
+	Priority   int
+}
+
+// parsePrefixForm reads a prefix from the request, writing an error response and returning false if something's wrong with it.
+func parsePrefixForm(w http.ResponseWriter, r *http.Request, c RouteContext, action string) (prefixForm, bool) {
+	if r.FormValue("color") == "" || r.FormValue("background") == "" || r.FormValue("words") == "" || r.FormValue("timedEvent") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return prefixForm{}, false
+	}
+
+	form := prefixForm{
+		Name:       strings.TrimSpace(r.FormValue("name")),
+		Color:      r.FormValue("color"),
+		Background: r.FormValue("background"),
+	}
+
+	if len(form.Name) > data.MaxPrefixNameLength {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return prefixForm{}, false
+	}
+
+	timedEvent, err := strconv.ParseBool(r.FormValue("timedEvent"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return prefixForm{}, false
+	}
+	if timedEvent {
+		form.TimedEvent = 1
+	}
+
+	regex := false
+	if r.FormValue("regex") != "" {
+		regex, err = strconv.ParseBool(r.FormValue("regex"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return prefixForm{}, false
+		}
+	}
+	if regex {
+		// a pattern isn't something that can be shown to people, so it needs a name
+		if form.Name == "" {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+			return prefixForm{}, false
+		}
+
+		form.Regex = 1
+	}
+
+	if r.FormValue("priority") != "" {
+		form.Priority, err = strconv.Atoi(r.FormValue("priority"))
+		if err != nil || form.Priority < -1000 || form.Priority > 1000 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return prefixForm{}, false
+		}
+	}
+
+	wordsInputString := r.FormValue("words")
+	wordsList := []string{}
+	cleanedWordsList := []string{}
+
+	err = json.Unmarshal([]byte(wordsInputString), &wordsList)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return prefixForm{}, false
+	}
+
+	for _, word := range wordsList {
+		if regex {
+			if strings.TrimSpace(word) == "" {
+				continue
+			}
+
+			// patterns are kept as they are, since spaces can be meaningful
+			_, err = data.CompilePrefixPattern(word)
+			if err != nil || len(word) > data.MaxPrefixPatternLength {
+				writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_regex"})
+				return prefixForm{}, false
+			}
+
+			cleanedWordsList = append(cleanedWordsList, word)
+		} else if phrase := strings.Join(strings.Fields(word), " "); phrase != "" {
+			cleanedWordsList = append(cleanedWordsList, phrase)
+		}
+	}
+
+	if len(cleanedWordsList) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return prefixForm{}, false
+	}
+
+	wordsFormatted, err := json.Marshal(cleanedWordsList)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return prefixForm{}, false
+	}
+	form.Words = string(wordsFormatted)
+
+	classIDs := []int{}
+	if r.FormValue("classIds") != "" {
+		err = json.Unmarshal([]byte(r.FormValue("classIds")), &classIDs)
+		if err != nil || len(classIDs) > 50 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return prefixForm{}, false
+		}
+	}
+
+	for _, classID := range classIDs {
+		rows, err := DB.Query("SELECT id FROM classes WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, classID)
+		if err != nil {
+			errorlog.LogError(action, err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return prefixForm{}, false
+		}
+		found := rows.Next()
+		rows.Close()
+		if !found {
+			writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+			return prefixForm{}, false
+		}
+	}
+
+	classIDsFormatted, err := json.Marshal(classIDs)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return prefixForm{}, false
+	}
+	form.ClassIDs = string(classIDsFormatted)
+
+	return form, true
+}
+
 func routePrefixesGetDefaultList(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
 	info := []schoolPrefixInfo{}
 	schools := MainRegistry.GetAllSchools()
@@ -77,7 +224,45 @@ func routePrefixesDelete(w http.ResponseWriter, r *http.Request, p httprouter.Pa
 		return
 	}
 
-	_, err = DB.Exec("DELETE FROM prefixes WHERE id = ?", r.FormValue("id"))
+	prefixID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("deleting prefixes", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// take it out of any packs it was published in
+	err = data.TouchPrefixPacksForPrefix(tx, prefixID)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("deleting prefixes", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	_, err = tx.Exec("DELETE FROM prefix_pack_items WHERE prefixId = ?", prefixID)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("deleting prefixes", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	_, err = tx.Exec("DELETE FROM prefixes WHERE id = ?", prefixID)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("deleting prefixes", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		errorlog.LogError("deleting prefixes", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
@@ -88,55 +273,118 @@ func routePrefixesDelete(w http.ResponseWriter, r *http.Request, p httprouter.Pa
 }
 
 func routePrefixesAdd(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
-	if r.FormValue("color") == "" || r.FormValue("background") == "" || r.FormValue("words") == "" || r.FormValue("timedEvent") == "" {
-		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+	form, ok := parsePrefixForm(w, r, c, "adding prefix")
+	if !ok {
 		return
 	}
 
-	timedEvent, err := strconv.ParseBool(r.FormValue("timedEvent"))
+	_, err := DB.Exec(
+		"INSERT INTO prefixes(name, words, color, background, isTimedEvent, isRegex, classIds, priority, userId) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		form.Name, form.Words, form.Color, form.Background, form.TimedEvent, form.Regex, form.ClassIDs, form.Priority, c.User.ID,
+	)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		errorlog.LogError("adding prefix", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
-	timedEventInt := 0
-	if timedEvent {
-		timedEventInt = 1
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routePrefixesEdit(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("id") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
 	}
 
-	wordsInputString := r.FormValue("words")
-	wordsList := []string{}
-	cleanedWordsList := []string{}
+	rows, err := DB.Query("SELECT id FROM prefixes WHERE userId = ? AND id = ?", c.User.ID, r.FormValue("id"))
+	if err != nil {
+		errorlog.LogError("editing prefix", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
 
-	err = json.Unmarshal([]byte(wordsInputString), &wordsList)
+	form, ok := parsePrefixForm(w, r, c, "editing prefix")
+	if !ok {
+		return
+	}
+
+	prefixID, err := strconv.Atoi(r.FormValue("id"))
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
 		return
 	}
 
-	for _, word := range wordsList {
-		if strings.TrimSpace(word) != "" {
-			cleanedWordsList = append(cleanedWordsList, strings.TrimSpace(word))
-		}
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("editing prefix", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
 	}
 
-	if len(cleanedWordsList) == 0 {
-		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+	_, err = tx.Exec(
+		"UPDATE prefixes SET name = ?, words = ?, color = ?, background = ?, isTimedEvent = ?, isRegex = ?, classIds = ?, priority = ? WHERE id = ?",
+		form.Name, form.Words, form.Color, form.Background, form.TimedEvent, form.Regex, form.ClassIDs, form.Priority, prefixID,
+	)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("editing prefix", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
-	wordsFormatted, err := json.Marshal(cleanedWordsList)
+	// subscribers of any pack with this prefix see the change
+	err = data.TouchPrefixPacksForPrefix(tx, prefixID)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		tx.Rollback()
+		errorlog.LogError("editing prefix", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
-	_, err = DB.Exec("INSERT INTO prefixes(words, color, background, isTimedEvent, userId) VALUES (?, ?, ?, ?, ?)", string(wordsFormatted), r.FormValue("color"), r.FormValue("background"), timedEventInt, c.User.ID)
+	err = tx.Commit()
 	if err != nil {
-		errorlog.LogError("adding prefix", err)
+		errorlog.LogError("editing prefix", err)
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
 		return
 	}
 
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
+
+func routePrefixesMatch(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("name") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	classID := -1
+	if r.FormValue("classId") != "" {
+		var err error
+		classID, err = strconv.Atoi(r.FormValue("classId"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+	}
+
+	prefixes, err := data.GetPrefixesForUser(c.User)
+	if err != nil {
+		errorlog.LogError("matching prefix", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	prefix, match, found := data.NewPrefixMatcher(prefixes).Match(r.FormValue("name"), classID)
+	if !found {
+		writeJSON(w, http.StatusOK, prefixMatchResponse{"ok", false, nil, ""})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, prefixMatchResponse{"ok", true, &prefix, match})
+}
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+	"github.com/MyHomeworkSpace/api-server/util"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// the shortest and longest time that someone can be locked out for
+const rateLimitBaseLockout = time.Minute
+const rateLimitMaxLockout = time.Hour
+
+// once someone has failed this many times their limit, it's probably an attack and not a forgotten password
+const rateLimitAlertMultiplier = 3
+
+// A rateLimit describes how many times a route can be tried before it starts locking people out.
+// Failures are counted both for the IP address of the request and for the account it's trying to get into.
+// After going over the limit, each failure locks the IP or account out for twice as long as the last one.
+type rateLimit struct {
+	Name         string        // used for Redis keys and alerts
+	AccountParam string        // the form value with the account being tried, or empty to use the logged in user
+	AccountPerIP bool          // if set, an account's failures are counted separately for each IP address, so that nobody can lock someone else out
+	CountAll     bool          // if set, every request counts, and not just the ones that fail
+	IPMax        int           // many students share a school's IP address, so this should be pretty high
+	AccountMax   int           // failures allowed for one account, or zero to only limit IP addresses
+	AccountTotal int           // with AccountPerIP, failures allowed for one account from every IP address put together, which should be a lot higher than AccountMax
+	Window       time.Duration // how long it takes for failures to be forgotten, starting from the latest one
+	IgnoreErrors []string      // error codes that don't count as failures, like asking for a second factor
+}
+
+var loginRateLimit = rateLimit{
+	Name:         "login",
+	AccountParam: "email",
+	AccountPerIP: true,
+	IPMax:        50,
+	AccountMax:   5,
+	AccountTotal: 30,
+	Window:       time.Hour,
+	IgnoreErrors: []string{"missing_params", "totp_required", "second_factor_required", "webauthn_challenge_expired"},
+}
+
+var resetRateLimit = rateLimit{
+	Name:         "reset",
+	AccountParam: "email",
+	CountAll:     true,
+	IPMax:        20,
+	AccountMax:   3,
+	Window:       time.Hour,
+}
+
+// for a logged in user confirming something with a TOTP code or their password
+var confirmRateLimit = rateLimit{
+	Name:       "confirm",
+	IPMax:      50,
+	AccountMax: 5,
+	Window:     time.Hour,
+}
+
+// for applications authenticating themselves to the oauth endpoints
+// only a wrong client secret counts, since a server can have plenty of users with expired tokens
+var oauthClientRateLimit = rateLimit{
+	Name:         "oauth_client",
+	IPMax:        50,
+	Window:       time.Hour,
+	IgnoreErrors: []string{"invalid_request", "invalid_grant", "unsupported_grant_type"},
+}
+
+type rateLimitedResponse struct {
+	Status     string `json:"status"`
+	Error      string `json:"error"`
+	RetryAfter int    `json:"retryAfter"`
+}
+
+// a statusRecorder remembers the status code that a route responded with, and the start of its response if it was an error
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	body   []byte
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status >= 400 && len(r.body) < 1024 {
+		r.body = append(r.body, b...)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// errorCode returns the error code that the route responded with, if there was one.
+func (r *statusRecorder) errorCode() string {
+	response := errorResponse{}
+	err := json.Unmarshal(r.body, &response)
+	if err != nil {
+		return ""
+	}
+	return response.Error
+}
+
+/*
+ * helpers
+ */
+
+func getRateLimitLockout(failures int, max int) time.Duration {
+	lockout := rateLimitBaseLockout
+	for i := max + 1; i < failures; i++ {
+		lockout *= 2
+		if lockout >= rateLimitMaxLockout {
+			return rateLimitMaxLockout
+		}
+	}
+	return lockout
+}
+
+// getRateLimitRemaining returns how much longer the given key is locked out for, or zero if it isn't.
+func getRateLimitRemaining(key string) (time.Duration, error) {
+	ttl, err := RedisClient.TTL(key + ":lock").Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// redis gives a negative ttl for a key that doesn't exist
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+// recordRateLimitFailure counts a failure for the given key, and locks it out if it's gone over its limit.
+// It returns how many failures the key has now.
+func recordRateLimitFailure(limit rateLimit, key string, max int, description string) (int, error) {
+	failures, err := RedisClient.Incr(key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	err = RedisClient.Expire(key, limit.Window).Err()
+	if err != nil {
+		return 0, err
+	}
+
+	if int(failures) <= max {
+		return int(failures), nil
+	}
+
+	err = RedisClient.Set(key+":lock", "1", getRateLimitLockout(int(failures), max)).Err()
+	if err != nil {
+		return 0, err
+	}
+
+	if int(failures) == max*rateLimitAlertMultiplier {
+		errorlog.LogError("rate limiting "+limit.Name, fmt.Errorf("possible attack: %d failed attempts from %s", failures, description))
+	}
+
+	return int(failures), nil
+}
+
+func writeRateLimited(w http.ResponseWriter, errorCode string, remaining time.Duration) {
+	retryAfter := int(remaining.Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	writeJSON(w, http.StatusTooManyRequests, rateLimitedResponse{"error", errorCode, retryAfter})
+}
+
+// rateLimited wraps the given route so that it follows the given rateLimit.
+// It's meant to go inside of route(), like route(rateLimited(routeAuthLogin, loginRateLimit), authLevelNone).
+func rateLimited(f routeFunc, limit rateLimit) routeFunc {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+		// without a reverse proxy, the address has a port number, which changes with every connection
+		ipAddress := getRequestRemoteAddr(r)
+		host, _, err := net.SplitHostPort(ipAddress)
+		if err == nil {
+			ipAddress = host
+		}
+		ipKey := "ratelimit:" + limit.Name + ":ip:" + ipAddress
+
+		account := ""
+		if limit.AccountParam != "" {
+			account = strings.ToLower(strings.TrimSpace(r.FormValue(limit.AccountParam)))
+		} else if c.LoggedIn && limit.AccountMax > 0 {
+			account = strconv.Itoa(c.User.ID)
+		}
+		accountKey := "ratelimit:" + limit.Name + ":account:" + account
+		accountDescription := "account " + account
+		accountTotalKey := ""
+		if limit.AccountPerIP {
+			// the account still needs a limit across every IP address, or an attacker could just keep switching them
+			if limit.AccountTotal > 0 {
+				accountTotalKey = accountKey
+			}
+			accountKey += ":ip:" + ipAddress
+			accountDescription += " from IP address " + ipAddress
+		}
+
+		// if redis is having problems, we let the request through, so that nobody gets locked out of their account
+		remaining, err := getRateLimitRemaining(ipKey)
+		if err != nil {
+			errorlog.LogError("checking rate limit", err)
+		} else if remaining > 0 {
+			writeRateLimited(w, "rate_limited", remaining)
+			return
+		}
+
+		if account != "" {
+			remaining, err = getRateLimitRemaining(accountKey)
+			if err != nil {
+				errorlog.LogError("checking rate limit", err)
+			} else if remaining > 0 {
+				writeRateLimited(w, "account_locked", remaining)
+				return
+			}
+		}
+
+		if account != "" && accountTotalKey != "" {
+			remaining, err = getRateLimitRemaining(accountTotalKey)
+			if err != nil {
+				errorlog.LogError("checking rate limit", err)
+			} else if remaining > 0 {
+				writeRateLimited(w, "account_locked", remaining)
+				return
+			}
+		}
+
+		recorder := &statusRecorder{w, http.StatusOK, nil}
+		f(recorder, r, p, c)
+
+		if !limit.CountAll {
+			if recorder.status < 400 {
+				// they got in, so forget about any mistakes they made
+				// the total for the account is left alone, since someone else could be the one making them
+				if account != "" {
+					RedisClient.Del(accountKey)
+				}
+				return
+			}
+
+			failed := (recorder.status < 500 && !util.StringSliceContains(limit.IgnoreErrors, recorder.errorCode()))
+			if !failed {
+				return
+			}
+		}
+
+		_, err = recordRateLimitFailure(limit, ipKey, limit.IPMax, "IP address "+ipAddress)
+		if err != nil {
+			errorlog.LogError("recording rate limit failure", err)
+		}
+
+		if account != "" {
+			_, err = recordRateLimitFailure(limit, accountKey, limit.AccountMax, accountDescription)
+			if err != nil {
+				errorlog.LogError("recording rate limit failure", err)
+			}
+		}
+
+		if account != "" && accountTotalKey != "" {
+			failures, err := recordRateLimitFailure(limit, accountTotalKey, limit.AccountTotal, "account "+account+" from all IP addresses")
+			if err != nil {
+				errorlog.LogError("recording rate limit failure", err)
+			} else if failures == limit.AccountTotal+1 {
+				errorlog.LogError("rate limiting "+limit.Name, fmt.Errorf("account %s went over its limit of %d failed attempts from all IP addresses, slowing down attempts", account, limit.AccountTotal))
+			}
+		}
+	}
+}
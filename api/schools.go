@@ -52,12 +52,26 @@ func routeSchoolsEnroll(w http.ResponseWriter, r *http.Request, p httprouter.Par
 		return
 	}
 
+	syncClasses := false
+	if r.FormValue("syncClasses") != "" {
+		syncClasses, err = strconv.ParseBool(r.FormValue("syncClasses"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+	}
+
 	// check we're not already enrolled
 	enrolled := false
 	for _, userSchool := range c.User.Schools {
 		if userSchool.SchoolID == school.ID() {
 			// we are
 			enrolled = true
+
+			// keep the existing setting when re-enrolling
+			if r.FormValue("syncClasses") == "" {
+				syncClasses = userSchool.SyncClasses
+			}
 		}
 	}
 
@@ -151,7 +165,7 @@ func routeSchoolsEnroll(w http.ResponseWriter, r *http.Request, p httprouter.Par
 	resultDataString := string(resultDataBytes)
 
 	// save the new data
-	_, err = tx.Exec("INSERT INTO schools(schoolId, enabled, data, userId) VALUES(?, 1, ?, ?)", school.ID(), resultDataString, c.User.ID)
+	_, err = tx.Exec("INSERT INTO schools(schoolId, enabled, syncClasses, data, userId) VALUES(?, 1, ?, ?, ?)", school.ID(), syncClasses, resultDataString, c.User.ID)
 	if err != nil {
 		tx.Rollback()
 
@@ -160,6 +174,18 @@ func routeSchoolsEnroll(w http.ResponseWriter, r *http.Request, p httprouter.Par
 		return
 	}
 
+	// create homework classes for the new schedule
+	if syncClasses {
+		err = data.SyncSchoolClasses(tx, school, c.User)
+		if err != nil {
+			tx.Rollback()
+
+			errorlog.LogError("enrolling in school - "+school.ID()+" - syncing classes", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+	}
+
 	// go!
 	err = tx.Commit()
 	if err != nil {
@@ -168,6 +194,8 @@ func routeSchoolsEnroll(w http.ResponseWriter, r *http.Request, p httprouter.Par
 		return
 	}
 
+	queueWebhookEvent(c.User.ID, data.WebhookEventSchoolEnrolled, webhookSchoolEnrollment{school.ID(), reenroll})
+
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
 
@@ -263,6 +291,88 @@ func routeSchoolsSetEnabled(w http.ResponseWriter, r *http.Request, p httprouter
 	writeJSON(w, http.StatusOK, statusResponse{"ok"})
 }
 
+func routeSchoolsSetSyncClasses(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("school") == "" || r.FormValue("syncClasses") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	syncClasses, err := strconv.ParseBool(r.FormValue("syncClasses"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	// find school
+	school, err := MainRegistry.GetSchoolByID(r.FormValue("school"))
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	} else if err != nil {
+		errorlog.LogError("set school's class sync status", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	foundSchool := false
+
+	// check we're already enrolled
+	for _, userSchool := range c.User.Schools {
+		if userSchool.SchoolID == school.ID() {
+			// we are
+			foundSchool = true
+			break
+		}
+	}
+
+	if !foundSchool {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "not_enrolled"})
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("set school's class sync status", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// update its status
+	_, err = tx.Exec(
+		"UPDATE schools SET syncClasses = ? WHERE schoolId = ? AND userId = ?",
+		syncClasses,
+		school.ID(),
+		c.User.ID,
+	)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("set school's class sync status", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// turning it on brings the classes up to date right away
+	// classes that were created before are kept when it's turned off, they just stop being updated
+	if syncClasses {
+		err = data.SyncSchoolClasses(tx, school, c.User)
+		if err != nil {
+			tx.Rollback()
+			errorlog.LogError("set school's class sync status", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("set school's class sync status", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
 func routeSchoolsUnenroll(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
 	if r.FormValue("school") == "" {
 		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+)
+
+// scopeSessionOnly is for routes that application tokens can't use at all, like changing a password or managing applications.
+const scopeSessionOnly data.Scope = ""
+
+// scopeAny is for routes that any application token can use, because they don't touch the user's data.
+const scopeAny data.Scope = "*"
+
+type insufficientScopeResponse struct {
+	Status string     `json:"status"`
+	Error  string     `json:"error"`
+	Scope  data.Scope `json:"scope,omitempty"`
+}
+
+type scopesResponse struct {
+	Status string       `json:"status"`
+	Scopes []data.Scope `json:"scopes"`
+}
+
+func tokenHasScope(scopes []data.Scope, scope data.Scope) bool {
+	if scope == scopeSessionOnly {
+		return false
+	}
+	if scope == scopeAny {
+		return true
+	}
+	return data.ScopesContain(scopes, scope)
+}
+
+// writeInsufficientScope responds to a token that doesn't have the scope a route needs, as described in RFC 6750, section 3.1.
+func writeInsufficientScope(w http.ResponseWriter, scope data.Scope) {
+	authenticate := "Bearer error=\"insufficient_scope\""
+	if scope != scopeSessionOnly {
+		authenticate += ", scope=\"" + string(scope) + "\""
+	}
+	w.Header().Set("WWW-Authenticate", authenticate)
+	writeJSON(w, http.StatusForbidden, insufficientScopeResponse{"error", "insufficient_scope", scope})
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/MyHomeworkSpace/api-server/config"
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/email"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// responses
+type sharesResponse struct {
+	Status   string       `json:"status"`
+	Outgoing []data.Share `json:"outgoing"`
+	Incoming []data.Share `json:"incoming"`
+}
+
+/*
+ * helpers
+ */
+
+func getShareFromForm(w http.ResponseWriter, r *http.Request, action string) (data.Share, bool) {
+	if r.FormValue("id") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return data.Share{}, false
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return data.Share{}, false
+	}
+
+	share, err := data.GetShareByID(id)
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return data.Share{}, false
+	} else if err != nil {
+		errorlog.LogError(action, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return data.Share{}, false
+	}
+
+	return share, true
+}
+
+/*
+ * routes
+ */
+
+func routeSharesGet(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	outgoing, err := data.GetSharesByOwner(c.User.ID)
+	if err != nil {
+		errorlog.LogError("getting shares", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	incoming, err := data.GetSharesForRecipient(c.User)
+	if err != nil {
+		errorlog.LogError("getting shares", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, sharesResponse{"ok", outgoing, incoming})
+}
+
+func routeSharesAdd(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("type") == "" || r.FormValue("id") == "" || r.FormValue("email") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	recipientEmail := strings.TrimSpace(r.FormValue("email"))
+	if !strings.Contains(recipientEmail, "@") {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	if strings.EqualFold(recipientEmail, c.User.Email) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "cannot_share_with_self"})
+		return
+	}
+
+	// check if you are allowed to share the given item
+	var shareType data.ShareType
+	var rows *sql.Rows
+	if r.FormValue("type") == "homework" {
+		shareType = data.ShareTypeHomework
+
+		// copies can't be shared again, since they wouldn't be kept up to date
+		rows, err = DB.Query("SELECT name FROM homework WHERE userId = ? AND trashId IS NULL AND sourceId IS NULL AND id = ?", c.User.ID, id)
+	} else if r.FormValue("type") == "class" {
+		shareType = data.ShareTypeClass
+		rows, err = DB.Query("SELECT name FROM classes WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, id)
+	} else {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+	if err != nil {
+		errorlog.LogError("sharing homework", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
+
+	itemName := ""
+	rows.Scan(&itemName)
+
+	// don't send the same thing to the same person twice
+	existingRows, err := DB.Query("SELECT id FROM shares WHERE type = ? AND itemId = ? AND email = ?", shareType, id, recipientEmail)
+	if err != nil {
+		errorlog.LogError("sharing homework", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	defer existingRows.Close()
+	if existingRows.Next() {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "already_shared"})
+		return
+	}
+
+	// if they already have an account, link it now
+	exists, recipientID, err := data.UserExistsWithEmail(recipientEmail)
+	if err != nil {
+		errorlog.LogError("sharing homework", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	if !exists {
+		recipientID = -1
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("sharing homework", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	_, err = data.CreateShare(tx, shareType, id, c.User.ID, recipientEmail, recipientID)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("sharing homework", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("sharing homework", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// the share is there either way, and shows up on their shares page, so a failed email isn't worth failing the request over
+	err = email.Send(recipientEmail, c.User, "shareInvite", map[string]interface{}{
+		"itemName":   itemName,
+		"isClass":    (shareType == data.ShareTypeClass),
+		"hasAccount": exists,
+		"url":        config.GetCurrent().Server.AppURLBase + "shares",
+	})
+	if err != nil {
+		errorlog.LogError("sending share invite", err)
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeSharesAccept(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("classId") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	share, ok := getShareFromForm(w, r, "accepting share")
+	if !ok {
+		return
+	}
+
+	if !share.IsRecipient(c.User) {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
+
+	if share.Accepted {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "already_accepted"})
+		return
+	}
+
+	classID, err := strconv.Atoi(r.FormValue("classId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	// check if you are allowed to add to the given classId
+	classRows, err := DB.Query("SELECT id FROM classes WHERE userId = ? AND trashId IS NULL AND id = ?", c.User.ID, classID)
+	if err != nil {
+		errorlog.LogError("accepting share", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+	defer classRows.Close()
+	if !classRows.Next() {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("accepting share", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	copies, err := data.AcceptShare(tx, share, c.User.ID, classID)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("accepting share", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("accepting share", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	queueHomeworkWebhookEvents(data.WebhookEventHomeworkCreated, copies)
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeSharesDelete(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	share, ok := getShareFromForm(w, r, "deleting share")
+	if !ok {
+		return
+	}
+
+	// either side can end a share
+	if share.OwnerID != c.User.ID && !share.IsRecipient(c.User) {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("deleting share", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = data.DeleteShare(tx, share.ID)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("deleting share", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("deleting share", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// responses
+type termsResponse struct {
+	Status string      `json:"status"`
+	Terms  []data.Term `json:"terms"`
+}
+type singleTermResponse struct {
+	Status string    `json:"status"`
+	Term   data.Term `json:"term"`
+}
+
+func routeTermsGet(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	terms, err := data.GetTermsForUser(c.User.ID)
+	if err != nil {
+		errorlog.LogError("getting terms", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, termsResponse{"ok", terms})
+}
+
+func routeTermsStart(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	if len(name) > 64 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	// classes that continue into the new term, like a year-long course
+	keepClassIDs := []int{}
+	if r.FormValue("keepIds") != "" {
+		err := json.Unmarshal([]byte(r.FormValue("keepIds")), &keepClassIDs)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("starting term", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	term, err := data.StartTerm(tx, name, c.User.ID, keepClassIDs)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("starting term", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("starting term", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, singleTermResponse{"ok", term})
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// responses
+type trashResponse struct {
+	Status string           `json:"status"`
+	Items  []data.TrashItem `json:"items"`
+}
+
+// the trash routes' own scopes only cover homework, so seeing anything else needs the read scope for it, and restoring or purging it needs the write scope
+var trashItemReadScopes = map[data.TrashItemType]data.Scope{
+	data.TrashItemHomework: data.ScopeHomeworkRead,
+	data.TrashItemClass:    data.ScopeClassesRead,
+	data.TrashItemEvent:    data.ScopeCalendarRead,
+}
+var trashItemWriteScopes = map[data.TrashItemType]data.Scope{
+	data.TrashItemHomework: data.ScopeHomeworkWrite,
+	data.TrashItemClass:    data.ScopeClassesWrite,
+	data.TrashItemEvent:    data.ScopeCalendarWrite,
+}
+
+/*
+ * helpers
+ */
+
+func getTrashItemFromForm(w http.ResponseWriter, r *http.Request, c RouteContext, action string) (data.TrashItem, bool) {
+	if r.FormValue("id") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return data.TrashItem{}, false
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return data.TrashItem{}, false
+	}
+
+	item, err := data.GetTrashItemForUser(id, c.User.ID)
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return data.TrashItem{}, false
+	} else if err != nil {
+		errorlog.LogError(action, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return data.TrashItem{}, false
+	}
+
+	session := GetSessionInfo(r)
+	if session.FromToken && !tokenHasScope(session.Scopes, trashItemWriteScopes[item.Type]) {
+		writeInsufficientScope(w, trashItemWriteScopes[item.Type])
+		return data.TrashItem{}, false
+	}
+
+	return item, true
+}
+
+/*
+ * routes
+ */
+
+func routeTrashGet(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	items, err := data.GetTrashForUser(c.User.ID)
+	if err != nil {
+		errorlog.LogError("getting trash", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	session := GetSessionInfo(r)
+	if session.FromToken {
+		visibleItems := []data.TrashItem{}
+		for _, item := range items {
+			if tokenHasScope(session.Scopes, trashItemReadScopes[item.Type]) {
+				visibleItems = append(visibleItems, item)
+			}
+		}
+		items = visibleItems
+	}
+
+	writeJSON(w, http.StatusOK, trashResponse{"ok", items})
+}
+
+func routeTrashRestore(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	item, ok := getTrashItemFromForm(w, r, c, "restoring from trash")
+	if !ok {
+		return
+	}
+
+	if item.Type == data.TrashItemHomework {
+		// the homework's class has to be restored first, otherwise the homework would have nowhere to go
+		rows, err := DB.Query("SELECT classes.id FROM homework INNER JOIN classes ON homework.classId = classes.id WHERE homework.id = ? AND classes.trashId IS NULL", item.ItemID)
+		if err != nil {
+			errorlog.LogError("restoring from trash", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+			return
+		}
+		defer rows.Close()
+		if !rows.Next() {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "class_in_trash"})
+			return
+		}
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("restoring from trash", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	restored, err := data.RestoreTrashItem(tx, item)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("restoring from trash", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("restoring from trash", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// to anything watching, it's like everything was just created again
+	for _, classID := range restored.ClassIDs {
+		queueClassWebhookEvent(c.User.ID, data.WebhookEventClassCreated, classID)
+	}
+	for _, homeworkID := range restored.HomeworkIDs {
+		queueHomeworkWebhookEvent(c.User.ID, data.WebhookEventHomeworkCreated, homeworkID)
+	}
+	for _, event := range restored.Events {
+		queueWebhookEvent(c.User.ID, data.WebhookEventCalendarEventCreated, webhookItem{event.ID, event.Name})
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeTrashDelete(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	item, ok := getTrashItemFromForm(w, r, c, "permanently deleting from trash")
+	if !ok {
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("permanently deleting from trash", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = data.PurgeTrashItem(tx, item)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("permanently deleting from trash", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("permanently deleting from trash", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
@@ -0,0 +1,419 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+	"github.com/MyHomeworkSpace/api-server/util"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// how many deliveries are shown in a webhook's log
+const webhookDeliveryLogLength = 50
+
+type webhooksResponse struct {
+	Status   string         `json:"status"`
+	Webhooks []data.Webhook `json:"webhooks"`
+}
+
+type newWebhookResponse struct {
+	Status  string       `json:"status"`
+	Webhook data.Webhook `json:"webhook"`
+	Secret  string       `json:"secret"`
+}
+
+type webhookEventsResponse struct {
+	Status string              `json:"status"`
+	Events []data.WebhookEvent `json:"events"`
+}
+
+type webhookDeliveriesResponse struct {
+	Status     string                 `json:"status"`
+	Deliveries []data.WebhookDelivery `json:"deliveries"`
+}
+
+type webhookDeliveryResponse struct {
+	Status     string `json:"status"`
+	DeliveryID int    `json:"deliveryId"`
+}
+
+// webhookItem is the payload of events that just say which item they're about, like deletions.
+// Receivers can fetch the item again if they need more than that.
+type webhookItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type webhookSchoolEnrollment struct {
+	SchoolID string `json:"schoolId"`
+	Reenroll bool   `json:"reenroll"`
+}
+
+/*
+ * helpers
+ */
+
+// queueWebhookEvent tells the user's webhooks about something that just happened.
+// By the time this is called, the change has already been made, so a problem here is logged but doesn't fail the request.
+func queueWebhookEvent(userID int, event data.WebhookEvent, payload interface{}) {
+	err := data.QueueWebhookEvent(userID, event, payload, time.Now())
+	if err != nil {
+		errorlog.LogError("queueing webhook event", err)
+	}
+}
+
+// queueHomeworkWebhookEvent tells the user's webhooks about a change to the given homework, with its details as they are now.
+func queueHomeworkWebhookEvent(userID int, event data.WebhookEvent, homeworkID int) {
+	homework, err := data.GetHomework(homeworkID)
+	if err != nil {
+		errorlog.LogError("queueing webhook event", err)
+		return
+	}
+
+	queueWebhookEvent(userID, event, homework)
+}
+
+// queueHomeworkWebhookEvents tells the webhooks of each homework item's owner about a change to it, like for copies of shared homework.
+func queueHomeworkWebhookEvents(event data.WebhookEvent, homework []data.Homework) {
+	for _, item := range homework {
+		queueHomeworkWebhookEvent(item.UserID, event, item.ID)
+	}
+}
+
+// queueClassWebhookEvent tells the user's webhooks about a change to the given class, with its details as they are now.
+func queueClassWebhookEvent(userID int, event data.WebhookEvent, classID int) {
+	class, err := data.GetClass(classID)
+	if err != nil {
+		errorlog.LogError("queueing webhook event", err)
+		return
+	}
+
+	queueWebhookEvent(userID, event, class)
+}
+
+// getRequestApplicationID returns the application that made the request, or -1 if the user made it themselves.
+func getRequestApplicationID(r *http.Request) int {
+	session := GetSessionInfo(r)
+	if !session.FromToken {
+		return -1
+	}
+	return session.ApplicationID
+}
+
+// getRequestPersonalAccessTokenID returns the personal access token that made the request, or -1 if it wasn't made with one.
+func getRequestPersonalAccessTokenID(r *http.Request) int {
+	session := GetSessionInfo(r)
+	if !session.FromToken || session.PersonalAccessTokenID == 0 {
+		return -1
+	}
+	return session.PersonalAccessTokenID
+}
+
+// checkWebhookEvents checks that the request can subscribe to the given events.
+// If it can't, it responds to the request and returns false.
+func checkWebhookEvents(w http.ResponseWriter, r *http.Request, events []data.WebhookEvent) bool {
+	session := GetSessionInfo(r)
+	if !session.FromToken {
+		return true
+	}
+
+	// tokens can only see events about things they could read anyway
+	for _, event := range events {
+		scope := data.WebhookEventScopes[event]
+		if !data.ScopesContain(session.Scopes, scope) {
+			writeInsufficientScope(w, scope)
+			return false
+		}
+	}
+
+	return true
+}
+
+// isValidWebhookURL checks a URL that a webhook should be sent to. Payloads have the user's data, so they have to be sent securely.
+func isValidWebhookURL(webhookURL string) bool {
+	if len(webhookURL) > 2048 {
+		return false
+	}
+
+	parsedURL, err := url.Parse(webhookURL)
+	if err != nil || parsedURL.Scheme != "https" || parsedURL.Host == "" || parsedURL.User != nil {
+		return false
+	}
+
+	return true
+}
+
+// getWebhookFromForm gets the webhook given in the request, and checks that it belongs to whoever made the request.
+// If something goes wrong, it responds to the request and returns false.
+func getWebhookFromForm(w http.ResponseWriter, r *http.Request, c RouteContext, action string) (data.Webhook, bool) {
+	if r.FormValue("id") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return data.Webhook{}, false
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return data.Webhook{}, false
+	}
+
+	webhook, err := data.GetWebhook(id)
+	if err == data.ErrNotFound {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return data.Webhook{}, false
+	} else if err != nil {
+		errorlog.LogError(action, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return data.Webhook{}, false
+	}
+
+	// applications can only touch their own webhooks
+	applicationID := getRequestApplicationID(r)
+	if webhook.UserID != c.User.ID || (applicationID != -1 && webhook.ApplicationID != applicationID) {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return data.Webhook{}, false
+	}
+
+	// and tokens can only touch webhooks for events that they could subscribe to themselves, since the deliveries have the same data
+	if !checkWebhookEvents(w, r, webhook.Events) {
+		return data.Webhook{}, false
+	}
+
+	return webhook, true
+}
+
+/*
+ * routes
+ */
+
+func routeWebhooksGetAll(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	webhooks, err := data.GetWebhooksForUser(c.User.ID)
+	if err != nil {
+		errorlog.LogError("getting webhooks", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	// applications only see their own webhooks, and tokens only see webhooks for events they could subscribe to
+	session := GetSessionInfo(r)
+	if session.FromToken {
+		applicationID := getRequestApplicationID(r)
+		visibleWebhooks := []data.Webhook{}
+		for _, webhook := range webhooks {
+			if applicationID != -1 && webhook.ApplicationID != applicationID {
+				continue
+			}
+			if !data.WebhookEventsAllowed(session.Scopes, webhook.Events) {
+				continue
+			}
+			visibleWebhooks = append(visibleWebhooks, webhook)
+		}
+		webhooks = visibleWebhooks
+	}
+
+	writeJSON(w, http.StatusOK, webhooksResponse{"ok", webhooks})
+}
+
+func routeWebhooksGetEvents(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	writeJSON(w, http.StatusOK, webhookEventsResponse{"ok", data.AllWebhookEvents})
+}
+
+func routeWebhooksCreate(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("url") == "" || r.FormValue("events") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	if !isValidWebhookURL(r.FormValue("url")) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_url"})
+		return
+	}
+
+	events, err := data.ParseWebhookEvents(r.FormValue("events"))
+	if err != nil || len(events) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	if !checkWebhookEvents(w, r, events) {
+		return
+	}
+
+	existing, err := data.GetWebhooksForUser(c.User.ID)
+	if err != nil {
+		errorlog.LogError("creating webhook", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	if len(existing) >= data.MaxWebhooks {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "too_many_webhooks"})
+		return
+	}
+
+	// the receiver needs the secret to check signatures, and this is the one chance to see it
+	secret, err := util.GenerateRandomString(32)
+	if err != nil {
+		errorlog.LogError("creating webhook", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	webhook := data.Webhook{
+		URL:                   r.FormValue("url"),
+		Events:                events,
+		Enabled:               true,
+		CreatedAt:             int(time.Now().Unix()),
+		ApplicationID:         getRequestApplicationID(r),
+		PersonalAccessTokenID: getRequestPersonalAccessTokenID(r),
+		UserID:                c.User.ID,
+		Secret:                secret,
+	}
+
+	webhook.ID, err = data.AddWebhook(webhook)
+	if err != nil {
+		errorlog.LogError("creating webhook", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newWebhookResponse{"ok", webhook, secret})
+}
+
+func routeWebhooksUpdate(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	webhook, ok := getWebhookFromForm(w, r, c, "updating webhook")
+	if !ok {
+		return
+	}
+
+	// anything that isn't given is left alone
+	if r.FormValue("url") != "" {
+		if !isValidWebhookURL(r.FormValue("url")) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_url"})
+			return
+		}
+		webhook.URL = r.FormValue("url")
+	}
+
+	if r.FormValue("events") != "" {
+		events, err := data.ParseWebhookEvents(r.FormValue("events"))
+		if err != nil || len(events) == 0 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+
+		if !checkWebhookEvents(w, r, events) {
+			return
+		}
+
+		webhook.Events = events
+	}
+
+	if r.FormValue("enabled") != "" {
+		enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+			return
+		}
+		webhook.Enabled = enabled
+	}
+
+	err := data.UpdateWebhook(webhook)
+	if err != nil {
+		errorlog.LogError("updating webhook", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeWebhooksDelete(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	webhook, ok := getWebhookFromForm(w, r, c, "deleting webhook")
+	if !ok {
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		errorlog.LogError("deleting webhook", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = data.DeleteWebhook(tx, webhook.ID)
+	if err != nil {
+		tx.Rollback()
+		errorlog.LogError("deleting webhook", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errorlog.LogError("deleting webhook", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{"ok"})
+}
+
+func routeWebhooksGetDeliveries(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	webhook, ok := getWebhookFromForm(w, r, c, "getting webhook deliveries")
+	if !ok {
+		return
+	}
+
+	deliveries, err := data.GetWebhookDeliveriesForWebhook(webhook.ID, webhookDeliveryLogLength)
+	if err != nil {
+		errorlog.LogError("getting webhook deliveries", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, webhookDeliveriesResponse{"ok", deliveries})
+}
+
+func routeWebhooksRedeliver(w http.ResponseWriter, r *http.Request, p httprouter.Params, c RouteContext) {
+	if r.FormValue("deliveryId") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "missing_params"})
+		return
+	}
+
+	deliveryID, err := strconv.Atoi(r.FormValue("deliveryId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"error", "invalid_params"})
+		return
+	}
+
+	// the webhook is given too, so that getWebhookFromForm can check it belongs to you
+	webhook, ok := getWebhookFromForm(w, r, c, "redelivering webhook")
+	if !ok {
+		return
+	}
+
+	delivery, err := data.GetWebhookDelivery(deliveryID)
+	if err == data.ErrNotFound || (err == nil && delivery.WebhookID != webhook.ID) {
+		writeJSON(w, http.StatusForbidden, errorResponse{"error", "forbidden"})
+		return
+	} else if err != nil {
+		errorlog.LogError("redelivering webhook", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	newDeliveryID, err := data.RedeliverWebhookDelivery(delivery, time.Now())
+	if err != nil {
+		errorlog.LogError("redelivering webhook", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"error", "internal_server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, webhookDeliveryResponse{"ok", newDeliveryID})
+}
@@ -2,14 +2,42 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"fmt"
 	"log"
 	"strconv"
 	"time"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/util"
+
+	"gopkg.in/redis.v5"
 )
 
+// how long a session lasts after signing in
+const sessionLength = 7 * 24 * time.Hour
+
+// how often a session's last seen time is updated, so that we aren't writing on every request
+const sessionTouchInterval = time.Minute
+
 type SessionInfo struct {
-	UserID int
+	UserID                int
+	FromToken             bool         // if the session comes from an auth token, and not a login
+	Scopes                []data.Scope // what an auth token is allowed to do
+	ApplicationID         int          // the application that an auth token belongs to, or -1 for a personal access token
+	PersonalAccessTokenID int          // the personal access token that the session comes from, or 0 if it isn't from one
+}
+
+// SessionMetadata describes a signed-in session, so that a user can tell their sessions apart.
+type SessionMetadata struct {
+	ID        string `json:"id"`
+	CreatedAt int    `json:"createdAt"`
+	LastSeen  int    `json:"lastSeen"`
+	IPAddress string `json:"ipAddress"`
+	UserAgent string `json:"userAgent"`
+	Current   bool   `json:"current"`
 }
 
 // GenerateRandomBytes returns securely generated random bytes.
@@ -39,66 +67,299 @@ func GenerateUID() (string, error) {
 	return GenerateRandomString(26)
 }
 
-// SetSession stores the given value under the given name in the database.
-// If the given name is already used, its value is overwritten.
-func SetSession(name string, value SessionInfo) {
-	result := RedisClient.HMSet("session:"+name, map[string]string{
-		"userId": strconv.Itoa(value.UserID),
-	})
-
-	if result.Err() != nil {
-		log.Println("Error while setting session: ")
-		log.Println(result.Err())
-		return
-	}
-
-	expireResult := RedisClient.Expire("session:"+name, 7*24*time.Hour)
-	if expireResult.Err() != nil {
-		log.Println("Error while setting session: ")
-		log.Println(expireResult.Err())
-		return
-	}
-}
-
 // GetSession retrieves the session information for the given name
 func GetSession(name string) SessionInfo {
 	result := RedisClient.HGetAll("session:" + name)
 	if result.Err() != nil {
 		log.Println("Error while getting session: ")
 		log.Println(result.Err())
-		return SessionInfo{-1}
+		return SessionInfo{UserID: -1}
 	}
 
 	resultMap, err := result.Result()
 	if err != nil {
 		log.Println("Error while getting session: ")
 		log.Println(err)
-		return SessionInfo{-1}
+		return SessionInfo{UserID: -1}
 	}
 
-	retval := SessionInfo{-1}
+	retval := SessionInfo{UserID: -1}
 
 	retval.UserID, err = strconv.Atoi(resultMap["userId"])
 
 	if err != nil {
-		return SessionInfo{-1}
+		return SessionInfo{UserID: -1}
 	}
 
 	return retval
 }
 
 func GetSessionFromAuthToken(authToken string) SessionInfo {
-	rows, err := DB.Query("SELECT users.id FROM application_authorizations INNER JOIN users ON application_authorizations.userId = users.id WHERE application_authorizations.token = ?", authToken)
+	if data.IsPersonalAccessToken(authToken) {
+		return getSessionFromPersonalAccessToken(authToken)
+	}
+
+	rows, err := DB.Query("SELECT users.id, application_authorizations.scopes, application_authorizations.applicationId FROM application_authorizations INNER JOIN users ON application_authorizations.userId = users.id WHERE application_authorizations.token = ?", authToken)
 	if err != nil {
 		log.Println("Error while getting session from auth token:")
 		log.Println(err)
-		return SessionInfo{-1}
+		return SessionInfo{UserID: -1}
 	}
 	defer rows.Close()
-	rows.Next()
 
-	retval := SessionInfo{-1}
-	rows.Scan(&retval.UserID)
+	if !rows.Next() {
+		// not an old-style token, so check if it's an access token from oauth
+		rows, err = DB.Query(
+			"SELECT application_authorizations.userId, application_authorizations.scopes, application_authorizations.applicationId FROM oauth_tokens INNER JOIN application_authorizations ON oauth_tokens.authorizationId = application_authorizations.id WHERE oauth_tokens.tokenHash = ? AND oauth_tokens.type = 'access' AND oauth_tokens.expiresAt > ?",
+			util.HashToken(authToken), time.Now().Unix(),
+		)
+		if err != nil {
+			log.Println("Error while getting session from auth token:")
+			log.Println(err)
+			return SessionInfo{UserID: -1}
+		}
+		defer rows.Close()
+
+		if !rows.Next() {
+			return SessionInfo{UserID: -1}
+		}
+	}
+
+	retval := SessionInfo{UserID: -1, FromToken: true}
+	scopes := ""
+	err = rows.Scan(&retval.UserID, &scopes, &retval.ApplicationID)
+	if err != nil {
+		return SessionInfo{UserID: -1}
+	}
+
+	// a scope that's since been removed isn't worth failing over
+	retval.Scopes, _ = data.ParseScopes(scopes)
 
 	return retval
 }
+
+func getSessionFromPersonalAccessToken(authToken string) SessionInfo {
+	token, err := data.GetPersonalAccessToken(authToken)
+	if err == data.ErrNotFound {
+		return SessionInfo{UserID: -1}
+	} else if err != nil {
+		log.Println("Error while getting session from personal access token:")
+		log.Println(err)
+		return SessionInfo{UserID: -1}
+	}
+
+	now := int(time.Now().Unix())
+	if token.ExpiresAt != -1 && token.ExpiresAt <= now {
+		return SessionInfo{UserID: -1}
+	}
+
+	err = data.UpdatePersonalAccessTokenUse(token.ID, now)
+	if err != nil {
+		log.Println("Error while updating personal access token use:")
+		log.Println(err)
+	}
+
+	return SessionInfo{UserID: token.UserID, FromToken: true, Scopes: token.Scopes, ApplicationID: -1, PersonalAccessTokenID: token.ID}
+}
+
+func getUserSessionsKey(userID int) string {
+	return fmt.Sprintf("user:%d:sessions", userID)
+}
+
+// GetSessionID returns the public ID of the session with the given name.
+// The name of a session is as good as a password, so this is what gets shown to users instead.
+func GetSessionID(name string) string {
+	hash := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(hash[:16])
+}
+
+// StartSession signs the given user in under the given session name, and adds it to their list of sessions.
+func StartSession(name string, userID int, ipAddress string, userAgent string) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	result := RedisClient.HMSet("session:"+name, map[string]string{
+		"userId":    strconv.Itoa(userID),
+		"createdAt": now,
+		"lastSeen":  now,
+		"ipAddress": ipAddress,
+		"userAgent": userAgent,
+	})
+	if result.Err() != nil {
+		log.Println("Error while starting session: ")
+		log.Println(result.Err())
+		return
+	}
+
+	expireResult := RedisClient.Expire("session:"+name, sessionLength)
+	if expireResult.Err() != nil {
+		log.Println("Error while starting session: ")
+		log.Println(expireResult.Err())
+		return
+	}
+
+	// the list only has to last as long as the newest session in it
+	indexKey := getUserSessionsKey(userID)
+	addResult := RedisClient.SAdd(indexKey, name)
+	if addResult.Err() != nil {
+		log.Println("Error while starting session: ")
+		log.Println(addResult.Err())
+		return
+	}
+
+	expireResult = RedisClient.Expire(indexKey, sessionLength)
+	if expireResult.Err() != nil {
+		log.Println("Error while starting session: ")
+		log.Println(expireResult.Err())
+		return
+	}
+}
+
+// EndSession signs out the session with the given name.
+func EndSession(name string) error {
+	userID, err := RedisClient.HGet("session:"+name, "userId").Int64()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	err = RedisClient.Del("session:" + name).Err()
+	if err != nil {
+		return err
+	}
+
+	if userID > 0 {
+		return RedisClient.SRem(getUserSessionsKey(int(userID)), name).Err()
+	}
+
+	return nil
+}
+
+// touchSessionScript updates a session, but only if it still exists, so that one that was just signed out doesn't come back without an expiry.
+// It also adds the session to its user's list, since sessions from before there was a list aren't in it yet.
+var touchSessionScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+
+redis.call("HMSET", KEYS[1], "lastSeen", ARGV[1], "ipAddress", ARGV[2], "userAgent", ARGV[3])
+
+redis.call("SADD", KEYS[2], ARGV[4])
+local ttl = redis.call("TTL", KEYS[1])
+if ttl > 0 and redis.call("TTL", KEYS[2]) < ttl then
+	redis.call("EXPIRE", KEYS[2], ttl)
+end
+
+return 1
+`)
+
+// TouchSession records that the given user's session with the given name was just used, and where it was used from.
+func TouchSession(name string, userID int, ipAddress string, userAgent string) {
+	lastSeen, err := RedisClient.HGet("session:"+name, "lastSeen").Int64()
+	if err == nil && time.Since(time.Unix(lastSeen, 0)) < sessionTouchInterval {
+		return
+	}
+
+	result := touchSessionScript.Run(
+		RedisClient,
+		[]string{"session:" + name, getUserSessionsKey(userID)},
+		strconv.FormatInt(time.Now().Unix(), 10), ipAddress, userAgent, name,
+	)
+	if result.Err() != nil {
+		log.Println("Error while touching session: ")
+		log.Println(result.Err())
+	}
+}
+
+// getSessionNamesForUser returns the names of the given user's sessions, cleaning out any that have expired or been replaced.
+func getSessionNamesForUser(userID int) ([]string, error) {
+	indexKey := getUserSessionsKey(userID)
+	names, err := RedisClient.SMembers(indexKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	validNames := []string{}
+	for _, name := range names {
+		sessionUserID, err := RedisClient.HGet("session:"+name, "userId").Int64()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+
+		if err == redis.Nil || int(sessionUserID) != userID {
+			RedisClient.SRem(indexKey, name)
+			continue
+		}
+
+		validNames = append(validNames, name)
+	}
+
+	return validNames, nil
+}
+
+// GetSessionsForUser returns information about every session the given user is signed in to.
+// The session with the name currentName, if there is one, is marked as the current one.
+func GetSessionsForUser(userID int, currentName string) ([]SessionMetadata, error) {
+	names, err := getSessionNamesForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []SessionMetadata{}
+	for _, name := range names {
+		resultMap, err := RedisClient.HGetAll("session:" + name).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		// older sessions don't have any of this, so these are allowed to fail
+		createdAt, _ := strconv.Atoi(resultMap["createdAt"])
+		lastSeen, _ := strconv.Atoi(resultMap["lastSeen"])
+
+		sessions = append(sessions, SessionMetadata{
+			ID:        GetSessionID(name),
+			CreatedAt: createdAt,
+			LastSeen:  lastSeen,
+			IPAddress: resultMap["ipAddress"],
+			UserAgent: resultMap["userAgent"],
+			Current:   (name == currentName),
+		})
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession signs out the given user's session with the given ID. It returns false if they have no session with that ID.
+func RevokeSession(userID int, id string) (bool, error) {
+	names, err := getSessionNamesForUser(userID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, name := range names {
+		if GetSessionID(name) == id {
+			return true, EndSession(name)
+		}
+	}
+
+	return false, nil
+}
+
+// RevokeOtherSessions signs out all of the given user's sessions, except for the one with the name keepName.
+func RevokeOtherSessions(userID int, keepName string) error {
+	names, err := getSessionNamesForUser(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if name == keepName {
+			continue
+		}
+
+		err = EndSession(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package calendar
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+)
+
+// how far after a cancelled event to look for the next meeting of its class
+const nextMeetingSearchDays = 42
+
+// FindEvent returns the event in the View with the given UniqueID, and whether it was found.
+func (v *View) FindEvent(uniqueID string) (data.Event, bool) {
+	for _, day := range v.Days {
+		for _, event := range day.Events {
+			if event.UniqueID == uniqueID {
+				return event, true
+			}
+		}
+	}
+
+	return data.Event{}, false
+}
+
+// findNextMeeting returns the first event in the View after the given one that's part of the same series and hasn't been cancelled.
+func (v *View) findNextMeeting(after data.Event) (data.Event, bool) {
+	if after.SeriesID == "" {
+		return data.Event{}, false
+	}
+
+	var next data.Event
+	found := false
+	for _, day := range v.Days {
+		for _, event := range day.Events {
+			// a class can have multiple kinds of meetings in the same series (like a lecture and a recitation), so the name has to match too
+			if event.SeriesID != after.SeriesID || event.Name != after.Name || event.Start <= after.Start {
+				continue
+			}
+
+			if isEventCancelled(event) {
+				continue
+			}
+
+			if !found || event.Start < next.Start {
+				next = event
+				found = true
+			}
+		}
+	}
+
+	return next, found
+}
+
+func isEventCancelled(event data.Event) bool {
+	cancelled, _ := event.Tags[data.EventTagCancelled].(bool)
+	return cancelled
+}
+
+// GetEventOriginalDate returns the day that the given event was scheduled for by its provider, before it was moved by an EventChange.
+func GetEventOriginalDate(event data.Event, location *time.Location) string {
+	start := event.Start
+
+	// recurring events that the user made have the start of their series here, and not where they were moved from
+	originalStart, moved := event.Tags[data.EventTagOriginalStart].(int)
+	if moved && event.RecurRule == nil {
+		start = originalStart
+	}
+
+	return time.Unix(int64(start), 0).In(location).Format("2006-01-02")
+}
+
+// UpdateHomeworkForEvent moves the due date of any homework linked to the event that the given EventChange is for, so that it's due whenever that event is now happening.
+// If the event was cancelled, the homework is moved to the next meeting of the same class instead.
+// It returns the homework that was moved, including any copies of it that were shared with other users.
+func UpdateHomeworkForEvent(db *sql.DB, user *data.User, location *time.Location, change data.EventChange) ([]data.Homework, error) {
+	// the links are found by the event's ID, so it doesn't matter how far away the event was moved
+	links, err := data.GetHomeworkEventLinksForEvent(user.ID, change.EventID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(links) == 0 {
+		return []data.Homework{}, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	moved := []data.Homework{}
+	views := map[string]View{}
+	for _, link := range links {
+		// if the event isn't moved or cancelled anymore, it's back on the day it was scheduled for
+		due := link.EventDate
+
+		if change.Cancel {
+			view, haveView := views[link.EventDate]
+			if !haveView {
+				eventDate, err := time.ParseInLocation("2006-01-02", link.EventDate, location)
+				if err != nil {
+					tx.Rollback()
+					return nil, err
+				}
+
+				view, err = GetView(db, user, location, eventDate, eventDate.AddDate(0, 0, nextMeetingSearchDays))
+				if err != nil {
+					tx.Rollback()
+					return nil, err
+				}
+
+				views[link.EventDate] = view
+			}
+
+			event, found := view.FindEvent(link.EventID)
+			if !found {
+				// the event isn't on the schedule anymore, so leave the homework where it is
+				continue
+			}
+
+			event, found = view.findNextMeeting(event)
+			if !found {
+				continue
+			}
+
+			link.EventID = event.UniqueID
+			link.EventDate = GetEventOriginalDate(event, location)
+			err = data.SetHomeworkEventLink(tx, link)
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+
+			due = time.Unix(int64(event.Start), 0).In(location).Format("2006-01-02")
+		} else if change.Start != -1 {
+			due = time.Unix(int64(change.Start), 0).In(location).Format("2006-01-02")
+		}
+
+		_, err = tx.Exec("UPDATE homework SET `due` = ? WHERE id = ?", due, link.HomeworkID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		copies, err := data.SyncSharedHomework(tx, link.HomeworkID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		moved = append(moved, data.Homework{ID: link.HomeworkID, UserID: user.ID})
+		moved = append(moved, copies...)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return moved, nil
+}
@@ -0,0 +1,140 @@
+package officehours
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+)
+
+// A Provider that implements the data.Provider interface for the office hours of a user's classes.
+type Provider struct{}
+
+type officeHoursInfo struct {
+	data.ClassOfficeHours
+
+	ClassName   string
+	HostName    string
+	MeetingLink string
+}
+
+// ID returns the ID of the Provider.
+func (p *Provider) ID() string {
+	return "officehours"
+}
+
+// Name returns the name of the Provider.
+func (p *Provider) Name() string {
+	return "Office Hours"
+}
+
+// UserHasOfficeHours checks if the given user has set office hours for any of their classes, and so if the Provider has anything to show them.
+func UserHasOfficeHours(db *sql.DB, user *data.User) (bool, error) {
+	rows, err := db.Query(
+		"SELECT class_office_hours.id FROM class_office_hours INNER JOIN classes ON class_office_hours.classId = classes.id WHERE class_office_hours.userId = ? AND classes.trashId IS NULL AND classes.archived = 0 LIMIT 1",
+		user.ID,
+	)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	return rows.Next(), nil
+}
+
+// GetData gets the requested calendar data from the provider.
+func (p *Provider) GetData(db *sql.DB, user *data.User, location *time.Location, startTime time.Time, endTime time.Time, dataType data.ProviderDataType) (data.ProviderData, error) {
+	result := data.ProviderData{
+		Announcements: []data.PlannerAnnouncement{},
+		Events:        []data.Event{},
+	}
+
+	if dataType&data.ProviderDataEvents == 0 {
+		return result, nil
+	}
+
+	rows, err := db.Query(
+		"SELECT class_office_hours.id, class_office_hours.classId, IFNULL(class_office_hours.contactId, -1), class_office_hours.dayOfWeek, class_office_hours.`start`, class_office_hours.`end`, class_office_hours.location, class_office_hours.userId, "+
+			"classes.name, IFNULL(class_contacts.name, ''), classes.meetingLink FROM class_office_hours "+
+			"INNER JOIN classes ON class_office_hours.classId = classes.id "+
+			"LEFT JOIN class_contacts ON class_office_hours.contactId = class_contacts.id "+
+			"WHERE class_office_hours.userId = ? AND classes.trashId IS NULL AND classes.archived = 0",
+		user.ID,
+	)
+	if err != nil {
+		return data.ProviderData{}, err
+	}
+	defer rows.Close()
+
+	allOfficeHours := []officeHoursInfo{}
+	for rows.Next() {
+		info := officeHoursInfo{}
+		err = rows.Scan(
+			&info.ID, &info.ClassID, &info.ContactID, &info.DayOfWeek, &info.Start, &info.End, &info.Location, &info.UserID,
+			&info.ClassName, &info.HostName, &info.MeetingLink,
+		)
+		if err != nil {
+			return data.ProviderData{}, err
+		}
+		allOfficeHours = append(allOfficeHours, info)
+	}
+
+	dayCount := int((endTime.Sub(startTime).Hours() / 24) + 0.5)
+	currentDay := startTime
+	for i := 0; i < dayCount; i++ {
+		if i != 0 {
+			currentDay = currentDay.AddDate(0, 0, 1)
+		}
+
+		dayString := currentDay.Format("2006-01-02")
+		dayTime, _ := time.ParseInLocation("2006-01-02", dayString, location)
+		dayOffset := int(dayTime.Unix())
+
+		for _, officeHours := range allOfficeHours {
+			if time.Weekday(officeHours.DayOfWeek) != dayTime.Weekday() {
+				continue
+			}
+
+			event := data.Event{
+				ID:            -1,
+				UniqueID:      strconv.Itoa(officeHours.ID) + "-" + dayString,
+				SeriesID:      strconv.Itoa(officeHours.ID),
+				SeriesName:    officeHours.ClassName + " Office Hours",
+				Name:          officeHours.ClassName + " Office Hours",
+				Start:         dayOffset + officeHours.Start,
+				End:           dayOffset + officeHours.End,
+				StartTimezone: location.String(),
+				EndTimezone:   location.String(),
+				Tags: map[data.EventTagType]interface{}{
+					data.EventTagReadOnly:   true,
+					data.EventTagCancelable: true,
+					data.EventTagClassID:    officeHours.ClassID,
+				},
+				UserID: user.ID,
+			}
+
+			if officeHours.HostName != "" {
+				event.Name += " with " + officeHours.HostName
+			}
+
+			if officeHours.Location != "" {
+				event.Tags[data.EventTagLocation] = officeHours.Location
+			}
+
+			if officeHours.MeetingLink != "" {
+				event.Tags[data.EventTagActions] = []data.EventAction{
+					{
+						Icon: "video-camera",
+						Name: "Join meeting",
+						URL:  officeHours.MeetingLink,
+					},
+				}
+			}
+
+			result.Events = append(result.Events, event)
+		}
+	}
+
+	return result, nil
+}
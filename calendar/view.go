@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/MyHomeworkSpace/api-server/calendar/officehours"
 	"github.com/MyHomeworkSpace/api-server/data"
 
 	set "github.com/deckarep/golang-set"
@@ -131,6 +132,42 @@ func addEventToView(view *View, event data.Event, eventTime time.Time, eventDura
 	}
 }
 
+func addClassActionsToEvent(event *data.Event, class data.HomeworkClass) {
+	actions := []data.EventAction{}
+	existingActions, haveActions := event.Tags[data.EventTagActions].([]data.EventAction)
+	if haveActions {
+		actions = append(actions, existingActions...)
+	}
+
+	if class.Website != "" {
+		actions = append(actions, data.EventAction{
+			Icon: "external-link",
+			Name: "Course website",
+			URL:  class.Website,
+		})
+	}
+
+	if class.Syllabus != "" {
+		actions = append(actions, data.EventAction{
+			Icon: "file-text",
+			Name: "Syllabus",
+			URL:  class.Syllabus,
+		})
+	}
+
+	if class.MeetingLink != "" {
+		actions = append(actions, data.EventAction{
+			Icon: "video-camera",
+			Name: "Join meeting",
+			URL:  class.MeetingLink,
+		})
+	}
+
+	if len(actions) > 0 {
+		event.Tags[data.EventTagActions] = actions
+	}
+}
+
 // GetView retrieves a CalendarView for the given user with the given parameters.
 func GetView(db *sql.DB, user *data.User, location *time.Location, startTime time.Time, endTime time.Time) (View, error) {
 	view := View{
@@ -144,6 +181,28 @@ func GetView(db *sql.DB, user *data.User, location *time.Location, startTime tim
 		return View{}, err
 	}
 
+	hasOfficeHours, err := officehours.UserHasOfficeHours(db, user)
+	if err != nil {
+		return View{}, err
+	}
+
+	if hasOfficeHours {
+		providers = append(providers, &officehours.Provider{})
+	}
+
+	// find the classes that were created from a school's schedule, so that their links can be added to its events
+	classes, err := data.GetClassesForUser(user)
+	if err != nil {
+		return View{}, err
+	}
+
+	classesBySeries := map[string]data.HomeworkClass{}
+	for _, class := range classes {
+		if class.SchoolID != "" {
+			classesBySeries[class.SchoolID+"-"+class.SchoolClassID] = class
+		}
+	}
+
 	for _, schoolInfo := range user.Schools {
 		if !schoolInfo.Enabled {
 			continue
@@ -176,7 +235,7 @@ func GetView(db *sql.DB, user *data.User, location *time.Location, startTime tim
 	plainEventRows, err := db.Query(
 		"SELECT calendar_events.id, calendar_events.name, calendar_events.`start`, calendar_events.`end`, calendar_events.location, calendar_events.`desc`, calendar_events.userId, calendar_event_rules.id, calendar_event_rules.eventId, calendar_event_rules.frequency, calendar_event_rules.interval, calendar_event_rules.byDay, calendar_event_rules.byMonthDay, calendar_event_rules.byMonth, calendar_event_rules.until FROM calendar_events "+
 			"LEFT JOIN calendar_event_rules ON calendar_events.id = calendar_event_rules.eventId "+
-			"WHERE calendar_events.userId = ? AND ((calendar_events.`end` >= ? AND calendar_events.`start` <= ?) OR calendar_event_rules.frequency IS NOT NULL)",
+			"WHERE calendar_events.userId = ? AND calendar_events.trashId IS NULL AND ((calendar_events.`end` >= ? AND calendar_events.`start` <= ?) OR calendar_event_rules.frequency IS NOT NULL)",
 		user.ID, startTime.Unix(), endTime.Unix(),
 	)
 	if err != nil {
@@ -255,7 +314,7 @@ func GetView(db *sql.DB, user *data.User, location *time.Location, startTime tim
 			"FROM calendar_hwevents "+
 			"INNER JOIN homework ON calendar_hwevents.homeworkId = homework.id "+
 			"INNER JOIN classes ON homework.classId = classes.id "+
-			"WHERE calendar_hwevents.userId = ? AND (calendar_hwevents.`end` >= ? AND calendar_hwevents.`start` <= ?)",
+			"WHERE calendar_hwevents.userId = ? AND calendar_hwevents.trashId IS NULL AND classes.archived = 0 AND (calendar_hwevents.`end` >= ? AND calendar_hwevents.`start` <= ?)",
 		user.ID, startTime.Unix(), endTime.Unix(),
 	)
 	if err != nil {
@@ -334,29 +393,107 @@ func GetView(db *sql.DB, user *data.User, location *time.Location, startTime tim
 			event.SeriesID = provider.ID() + "-" + event.SeriesID
 			event.Source = providerIndex
 
+			class, haveClass := classesBySeries[event.SeriesID]
+			if haveClass {
+				addClassActionsToEvent(&event, class)
+			}
+
 			view.Days[dayOffset].Events = append(view.Days[dayOffset].Events, event)
 		}
 	}
 
 	// apply any modifications made by the user
-	eventChangeRows, err := db.Query("SELECT eventID, cancel FROM calendar_event_changes WHERE userID = ?", user.ID)
+	eventChangeRows, err := db.Query("SELECT eventID, cancel, IFNULL(`start`, -1), IFNULL(`end`, -1) FROM calendar_event_changes WHERE userID = ?", user.ID)
 	if err != nil {
 		return View{}, err
 	}
 	defer eventChangeRows.Close()
 
 	cancellations := set.NewSet()
+	moves := map[string]data.EventChange{}
 
 	for eventChangeRows.Next() {
-		eventID, cancel := "", 0
-		err = eventChangeRows.Scan(&eventID, &cancel)
+		eventChange := data.EventChange{}
+		err = eventChangeRows.Scan(&eventChange.EventID, &eventChange.Cancel, &eventChange.Start, &eventChange.End)
 		if err != nil {
 			return View{}, err
 		}
 
-		if cancel == 1 {
-			cancellations.Add(eventID)
+		if eventChange.Cancel {
+			cancellations.Add(eventChange.EventID)
+		}
+
+		if eventChange.Start != -1 && eventChange.End != -1 {
+			moves[eventChange.EventID] = eventChange
+		}
+	}
+
+	// moved events are taken out of their day and put back in wherever they ended up
+	// note that an event can only be moved within the view, since we don't know about events from outside of it
+	movedEvents := []data.Event{}
+	for dayIndex, day := range view.Days {
+		dayEvents := []data.Event{}
+		for _, event := range day.Events {
+			eventChange, moved := moves[event.UniqueID]
+			if !moved {
+				dayEvents = append(dayEvents, event)
+				continue
+			}
+
+			newTags := map[data.EventTagType]interface{}{}
+			for tagType, tagValue := range event.Tags {
+				newTags[tagType] = tagValue
+			}
+			// recurring events that the user made keep the start of their whole series here instead
+			if event.RecurRule == nil {
+				newTags[data.EventTagOriginalStart] = event.Start
+				newTags[data.EventTagOriginalEnd] = event.End
+			}
+			event.Tags = newTags
+
+			event.Start = eventChange.Start
+			event.End = eventChange.End
+
+			movedEvents = append(movedEvents, event)
+		}
+		view.Days[dayIndex].Events = dayEvents
+	}
+
+	for _, event := range movedEvents {
+		eventDate := time.Unix(int64(event.Start), 0)
+		dayOffset := int(math.Floor(eventDate.Sub(startTime).Hours() / 24))
+
+		if dayOffset < 0 || dayOffset > len(view.Days)-1 {
+			continue
 		}
+
+		view.Days[dayOffset].Events = append(view.Days[dayOffset].Events, event)
+	}
+
+	// find homework that's due at one of these events
+	linkedHomeworkRows, err := db.Query(
+		"SELECT homework_events.eventId, homework.id, homework.name, homework.`due`, homework.`desc`, homework.`complete`, homework.classId, homework.userId FROM homework_events "+
+			"INNER JOIN homework ON homework_events.homeworkId = homework.id "+
+			"INNER JOIN classes ON homework.classId = classes.id "+
+			"WHERE homework_events.userId = ? AND homework.trashId IS NULL AND classes.archived = 0 AND homework.`due` >= ? AND homework.`due` <= ?",
+		user.ID, startTime.Format("2006-01-02"), endTime.Format("2006-01-02"),
+	)
+	if err != nil {
+		return View{}, err
+	}
+	defer linkedHomeworkRows.Close()
+
+	linkedHomework := map[string][]data.Homework{}
+
+	for linkedHomeworkRows.Next() {
+		eventID := ""
+		homework := data.Homework{}
+		err = linkedHomeworkRows.Scan(&eventID, &homework.ID, &homework.Name, &homework.Due, &homework.Desc, &homework.Complete, &homework.ClassID, &homework.UserID)
+		if err != nil {
+			return View{}, err
+		}
+
+		linkedHomework[eventID] = append(linkedHomework[eventID], homework)
 	}
 
 	for dayIndex, day := range view.Days {
@@ -364,6 +501,11 @@ func GetView(db *sql.DB, user *data.User, location *time.Location, startTime tim
 			if cancellations.Contains(event.UniqueID) {
 				view.Days[dayIndex].Events[eventIndex].Tags[data.EventTagCancelled] = true
 			}
+
+			homework, haveHomework := linkedHomework[event.UniqueID]
+			if haveHomework {
+				view.Days[dayIndex].Events[eventIndex].Tags[data.EventTagLinkedHomework] = homework
+			}
 		}
 	}
 
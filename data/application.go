@@ -1,18 +1,296 @@
 package data
 
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/util"
+
+	"gopkg.in/redis.v5"
+)
+
+// MaxApplicationDescriptionLength is the longest description that an Application can have.
+const MaxApplicationDescriptionLength = 1000
+
+// MaxApplicationRedirectURIs is the most redirect URIs that an Application can register.
+const MaxApplicationRedirectURIs = 10
+
+// MaxApplicationLogoSize is the largest logo that an Application can have, in bytes.
+const MaxApplicationLogoSize = 256 * 1024
+
+// how many days of request counts are kept for usage stats
+const applicationRequestCountDays = 30
+
 // An Application describes a third-party application designed to integrate with MyHomeworkSpace.
 type Application struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	AuthorName  string `json:"authorName"`
-	ClientID    string `json:"clientId"`
-	CallbackURL string `json:"callbackUrl"`
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
+	AuthorName   string   `json:"authorName"`
+	Description  string   `json:"description"`
+	HasLogo      bool     `json:"hasLogo"`
+	ClientID     string   `json:"clientId"`
+	CallbackURL  string   `json:"callbackUrl"`
+	RedirectURIs []string `json:"redirectUris"` // where oauth can send the user back to, which has to match exactly
+	Scopes       []Scope  `json:"scopes"`       // what the application can ask users for
+
+	HasClientSecret               bool   `json:"hasClientSecret"` // public clients don't, and have to use PKCE instead
+	PreviousClientSecretExpiresAt int    `json:"previousClientSecretExpiresAt"`
+	ClientSecretHash              string `json:"-"`
+	PreviousClientSecretHash      string `json:"-"` // the secret from before the last rotation, which works until PreviousClientSecretExpiresAt
 }
 
 // An ApplicationAuthorization describes a user's authorization of an application's access to their account.
 type ApplicationAuthorization struct {
-	ID            int    `json:"id"`
-	ApplicationID int    `json:"applicationId"`
-	Name          string `json:"name"`
-	AuthorName    string `json:"authorName"`
+	ID            int     `json:"id"`
+	ApplicationID int     `json:"applicationId"`
+	Name          string  `json:"name"`
+	AuthorName    string  `json:"authorName"`
+	Scopes        []Scope `json:"scopes"` // what the user has let the application do
+}
+
+// An ApplicationRequestCount is how many API requests an application made on one day.
+type ApplicationRequestCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// ApplicationStats describes how much an application is being used.
+type ApplicationStats struct {
+	Authorizations       int                       `json:"authorizations"`
+	ActiveAuthorizations int                       `json:"activeAuthorizations"` // ones with a token that still works
+	Requests             []ApplicationRequestCount `json:"requests"`             // for each of the last 30 days, oldest first
+}
+
+// IsConfidential returns whether the application has a client secret.
+func (a Application) IsConfidential() bool {
+	return a.ClientSecretHash != ""
+}
+
+// CheckClientSecret returns whether the given secret is the application's client secret.
+// While a secret is being rotated, the previous one also works until it expires.
+func (a Application) CheckClientSecret(secret string) bool {
+	if !a.IsConfidential() || secret == "" {
+		return false
+	}
+
+	hash := []byte(util.HashToken(secret))
+	if subtle.ConstantTimeCompare(hash, []byte(a.ClientSecretHash)) == 1 {
+		return true
+	}
+
+	if a.PreviousClientSecretHash != "" && int64(a.PreviousClientSecretExpiresAt) > time.Now().Unix() {
+		return subtle.ConstantTimeCompare(hash, []byte(a.PreviousClientSecretHash)) == 1
+	}
+
+	return false
+}
+
+// HasRedirectURI returns whether the given URI is exactly one of the application's redirect URIs.
+func (a Application) HasRedirectURI(uri string) bool {
+	for _, redirectURI := range a.RedirectURIs {
+		if redirectURI == uri {
+			return true
+		}
+	}
+	return false
+}
+
+const applicationSelect = "SELECT id, name, authorName, IFNULL(description, ''), logo IS NOT NULL, clientId, callbackUrl, scopes, IFNULL(clientSecretHash, ''), IFNULL(previousClientSecretHash, ''), IFNULL(previousClientSecretExpiresAt, -1) FROM applications "
+
+func scanApplications(rows *sql.Rows) ([]Application, error) {
+	applications := []Application{}
+	for rows.Next() {
+		application := Application{}
+		scopes := ""
+		err := rows.Scan(
+			&application.ID,
+			&application.Name,
+			&application.AuthorName,
+			&application.Description,
+			&application.HasLogo,
+			&application.ClientID,
+			&application.CallbackURL,
+			&scopes,
+			&application.ClientSecretHash,
+			&application.PreviousClientSecretHash,
+			&application.PreviousClientSecretExpiresAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		application.Scopes, _ = ParseScopes(scopes)
+		application.HasClientSecret = application.IsConfidential()
+		applications = append(applications, application)
+	}
+	return applications, nil
+}
+
+func getApplications(query string, args ...interface{}) ([]Application, error) {
+	rows, err := DB.Query(applicationSelect+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applications, err := scanApplications(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range applications {
+		applications[i].RedirectURIs, err = getApplicationRedirectURIs(applications[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return applications, nil
+}
+
+func getApplicationRedirectURIs(applicationID int) ([]string, error) {
+	rows, err := DB.Query("SELECT uri FROM application_redirect_uris WHERE applicationId = ? ORDER BY id ASC", applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	uris := []string{}
+	for rows.Next() {
+		uri := ""
+		err = rows.Scan(&uri)
+		if err != nil {
+			return nil, err
+		}
+		uris = append(uris, uri)
+	}
+
+	return uris, nil
+}
+
+// GetApplicationByClientID fetches the application with the given client ID.
+func GetApplicationByClientID(clientID string) (Application, error) {
+	applications, err := getApplications("WHERE clientId = ?", clientID)
+	if err != nil {
+		return Application{}, err
+	}
+
+	if len(applications) == 0 {
+		return Application{}, ErrNotFound
+	}
+
+	return applications[0], nil
+}
+
+// GetApplicationForOwner fetches the application with the given ID, if it was made by the given user.
+func GetApplicationForOwner(id int, userID int) (Application, error) {
+	applications, err := getApplications("WHERE id = ? AND userId = ?", id, userID)
+	if err != nil {
+		return Application{}, err
+	}
+
+	if len(applications) == 0 {
+		return Application{}, ErrNotFound
+	}
+
+	return applications[0], nil
+}
+
+// GetApplicationsForOwner returns all of the applications that the given user has made.
+func GetApplicationsForOwner(userID int) ([]Application, error) {
+	return getApplications("WHERE userId = ? ORDER BY id ASC", userID)
+}
+
+// SetApplicationRedirectURIs replaces the redirect URIs of the given application.
+func SetApplicationRedirectURIs(tx *sql.Tx, applicationID int, uris []string) error {
+	_, err := tx.Exec("DELETE FROM application_redirect_uris WHERE applicationId = ?", applicationID)
+	if err != nil {
+		return err
+	}
+
+	for _, uri := range uris {
+		_, err = tx.Exec("INSERT INTO application_redirect_uris(uri, applicationId) VALUES(?, ?)", uri, applicationID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetApplicationLogo returns the PNG logo of the application with the given client ID, or ErrNotFound if it doesn't have one.
+func GetApplicationLogo(clientID string) ([]byte, error) {
+	rows, err := DB.Query("SELECT logo FROM applications WHERE clientId = ? AND logo IS NOT NULL", clientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, ErrNotFound
+	}
+
+	logo := []byte{}
+	err = rows.Scan(&logo)
+	if err != nil {
+		return nil, err
+	}
+
+	return logo, nil
+}
+
+func getApplicationRequestCountKey(applicationID int, date string) string {
+	return fmt.Sprintf("application:%d:requests:%s", applicationID, date)
+}
+
+// RecordApplicationRequest counts an API request made with one of the given application's tokens.
+func RecordApplicationRequest(applicationID int, now time.Time) error {
+	key := getApplicationRequestCountKey(applicationID, now.UTC().Format("2006-01-02"))
+
+	err := RedisClient.Incr(key).Err()
+	if err != nil {
+		return err
+	}
+
+	return RedisClient.Expire(key, (applicationRequestCountDays+1)*24*time.Hour).Err()
+}
+
+// GetApplicationStats returns usage stats for the given application.
+func GetApplicationStats(applicationID int, now time.Time) (ApplicationStats, error) {
+	stats := ApplicationStats{}
+
+	rows, err := DB.Query(
+		"SELECT COUNT(*), IFNULL(SUM(token IS NOT NULL OR EXISTS(SELECT id FROM oauth_tokens WHERE oauth_tokens.authorizationId = application_authorizations.id AND oauth_tokens.expiresAt > ?)), 0) FROM application_authorizations WHERE applicationId = ?",
+		now.Unix(), applicationID,
+	)
+	if err != nil {
+		return ApplicationStats{}, err
+	}
+	defer rows.Close()
+
+	rows.Next()
+	err = rows.Scan(&stats.Authorizations, &stats.ActiveAuthorizations)
+	if err != nil {
+		return ApplicationStats{}, err
+	}
+
+	stats.Requests = []ApplicationRequestCount{}
+	for i := applicationRequestCountDays - 1; i >= 0; i-- {
+		date := now.UTC().AddDate(0, 0, -i).Format("2006-01-02")
+
+		count := 0
+		countString, err := RedisClient.Get(getApplicationRequestCountKey(applicationID, date)).Result()
+		if err == nil {
+			count, _ = strconv.Atoi(countString)
+		} else if err != redis.Nil {
+			return ApplicationStats{}, err
+		}
+
+		stats.Requests = append(stats.Requests, ApplicationRequestCount{date, count})
+	}
+
+	return stats, nil
 }
@@ -0,0 +1,149 @@
+package data
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// An AuditEvent is the kind of thing that an AuditLogEntry records.
+type AuditEvent string
+
+// The AuditEvents that can be recorded.
+const (
+	AuditEventAccountCreate           AuditEvent = "account_create"
+	AuditEventAccountDeleteRequest    AuditEvent = "account_delete_request"
+	AuditEventLogin                   AuditEvent = "login"
+	AuditEventLoginFailed             AuditEvent = "login_failed"
+	AuditEventLogout                  AuditEvent = "logout"
+	AuditEventPasswordChange          AuditEvent = "password_change"
+	AuditEventPasswordResetRequest    AuditEvent = "password_reset_request"
+	AuditEventPasswordReset           AuditEvent = "password_reset"
+	AuditEventEmailChangeRequest      AuditEvent = "email_change_request"
+	AuditEventEmailChange             AuditEvent = "email_change"
+	AuditEventTOTPEnroll              AuditEvent = "totp_enroll"
+	AuditEventTOTPUnenroll            AuditEvent = "totp_unenroll"
+	AuditEventRecoveryCodesRegenerate AuditEvent = "recovery_codes_regenerate"
+	AuditEvent2FAResetRequest         AuditEvent = "2fa_reset_request"
+	AuditEvent2FAReset                AuditEvent = "2fa_reset"
+	AuditEventWebAuthnKeyAdd          AuditEvent = "webauthn_key_add"
+	AuditEventWebAuthnKeyRemove       AuditEvent = "webauthn_key_remove"
+	AuditEventSessionRevoke           AuditEvent = "session_revoke"
+	AuditEventSessionRevokeOthers     AuditEvent = "session_revoke_others"
+	AuditEventApplicationAuthorize    AuditEvent = "application_authorize"
+	AuditEventApplicationRevoke       AuditEvent = "application_revoke"
+	AuditEventPersonalTokenCreate     AuditEvent = "personal_token_create"
+	AuditEventPersonalTokenRevoke     AuditEvent = "personal_token_revoke"
+)
+
+// MaxAuditLogPageSize is the most entries that can be fetched from the audit log at once.
+const MaxAuditLogPageSize = 100
+
+// An AuditLogEntry records a security-related event on a user's account.
+// Entries are never changed or removed once they're added.
+type AuditLogEntry struct {
+	ID        int             `json:"id"`
+	UserID    int             `json:"userId"`
+	Event     AuditEvent      `json:"event"`
+	Details   json.RawMessage `json:"details"`
+	IPAddress string          `json:"ipAddress"`
+	UserAgent string          `json:"userAgent"`
+	Timestamp int             `json:"timestamp"`
+}
+
+// An AuditLogFilter narrows down a search of the audit log. Fields that are left empty, or -1 for numbers, aren't used.
+type AuditLogFilter struct {
+	UserID    int
+	Event     AuditEvent
+	IPAddress string
+	Start     int
+	End       int
+	Before    int // only entries with an ID less than this, for getting the next page
+	Limit     int
+}
+
+// AddAuditLogEntry adds the given entry to the audit log. Its ID is ignored, and the details can be anything that can be turned into JSON.
+func AddAuditLogEntry(entry AuditLogEntry, details interface{}) error {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	userAgent := entry.UserAgent
+	if len(userAgent) > 512 {
+		// cutting it off can split a character, which the database won't take
+		userAgent = strings.ToValidUTF8(userAgent[:512], "")
+	}
+
+	_, err = DB.Exec(
+		"INSERT INTO audit_log(userId, event, details, ipAddress, userAgent, `timestamp`) VALUES(?, ?, ?, ?, ?, ?)",
+		entry.UserID, string(entry.Event), string(detailsJSON), entry.IPAddress, userAgent, entry.Timestamp,
+	)
+	return err
+}
+
+// QueryAuditLog returns the entries of the audit log that match the given filter, newest first.
+func QueryAuditLog(filter AuditLogFilter) ([]AuditLogEntry, error) {
+	conditions := []string{}
+	args := []interface{}{}
+
+	if filter.UserID != -1 {
+		conditions = append(conditions, "userId = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.Event != "" {
+		conditions = append(conditions, "event = ?")
+		args = append(args, string(filter.Event))
+	}
+	if filter.IPAddress != "" {
+		conditions = append(conditions, "ipAddress = ?")
+		args = append(args, filter.IPAddress)
+	}
+	if filter.Start != -1 {
+		conditions = append(conditions, "`timestamp` >= ?")
+		args = append(args, filter.Start)
+	}
+	if filter.End != -1 {
+		conditions = append(conditions, "`timestamp` < ?")
+		args = append(args, filter.End)
+	}
+	if filter.Before != -1 {
+		conditions = append(conditions, "id < ?")
+		args = append(args, filter.Before)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > MaxAuditLogPageSize {
+		limit = MaxAuditLogPageSize
+	}
+
+	query := "SELECT id, userId, event, details, ipAddress, userAgent, `timestamp` FROM audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditLogEntry{}
+	for rows.Next() {
+		entry := AuditLogEntry{}
+		details := ""
+		err = rows.Scan(&entry.ID, &entry.UserID, &entry.Event, &details, &entry.IPAddress, &entry.UserAgent, &entry.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		entry.Details = json.RawMessage(details)
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -17,6 +17,7 @@ const (
 	EmailTokenResetPassword
 	EmailTokenChangeEmail
 	EmailTokenVerifyEmail
+	EmailTokenReset2FA
 )
 
 // An EmailToken is used for situations like an email change or a password reset, where a confirmation email must be sent.
@@ -150,7 +151,7 @@ func GetUserByID(id int) (User, error) {
 
 	user.Schools = []SchoolInfo{}
 
-	schoolRows, err := DB.Query("SELECT id, schoolId, enabled, data, userId FROM schools WHERE userId = ?", user.ID)
+	schoolRows, err := DB.Query("SELECT id, schoolId, enabled, syncClasses, data, userId FROM schools WHERE userId = ?", user.ID)
 	if err != nil {
 		return User{}, err
 	}
@@ -160,7 +161,7 @@ func GetUserByID(id int) (User, error) {
 		info := SchoolInfo{}
 		dataString := ""
 
-		err := schoolRows.Scan(&info.EnrollmentID, &info.SchoolID, &info.Enabled, &dataString, &info.UserID)
+		err := schoolRows.Scan(&info.EnrollmentID, &info.SchoolID, &info.Enabled, &info.SyncClasses, &dataString, &info.UserID)
 		if err != nil {
 			return User{}, err
 		}
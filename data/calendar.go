@@ -54,6 +54,7 @@ const (
 	EventTagInstanceEnd
 	EventTagIsContinuation
 	EventTagContinues
+	EventTagLinkedHomework
 )
 
 // An Event is an event on a user's calendar. It could be from their schedule, homework, or manually added.
@@ -81,9 +82,12 @@ type EventAction struct {
 }
 
 // An EventChange is a modification a user makes to an Event that came from a provider.
+// If Start and End are not -1, the Event has been moved to that time.
 type EventChange struct {
 	EventID string `json:"eventID"`
 	Cancel  bool   `json:"cancel"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
 	UserID  int    `json:"userID"`
 }
 
@@ -0,0 +1,74 @@
+package data
+
+// A ClassContactRole describes who a ClassContact is in a class.
+type ClassContactRole int
+
+// The available ClassContactRoles.
+const (
+	ClassContactRoleInstructor ClassContactRole = 0
+	ClassContactRoleTA         ClassContactRole = 1
+)
+
+// A ClassContact is a person who teaches or helps with a HomeworkClass, like an instructor or a TA.
+type ClassContact struct {
+	ID      int              `json:"id"`
+	ClassID int              `json:"classId"`
+	Name    string           `json:"name"`
+	Role    ClassContactRole `json:"role"`
+	Email   string           `json:"email"`
+	UserID  int              `json:"userId"`
+}
+
+// ClassOfficeHours are a weekly time that someone holds office hours for a HomeworkClass.
+type ClassOfficeHours struct {
+	ID        int    `json:"id"`
+	ClassID   int    `json:"classId"`
+	ContactID int    `json:"contactId"` // -1 if it's not held by one of the class's contacts
+	DayOfWeek int    `json:"dayOfWeek"` // same as time.Weekday, so 0 is Sunday
+	Start     int    `json:"start"`     // seconds after midnight
+	End       int    `json:"end"`       // seconds after midnight
+	Location  string `json:"location"`
+	UserID    int    `json:"userId"`
+}
+
+// GetContactsForClass returns all ClassContacts for the given class.
+func GetContactsForClass(classID int) ([]ClassContact, error) {
+	rows, err := DB.Query("SELECT id, classId, name, role, email, userId FROM class_contacts WHERE classId = ? ORDER BY role ASC, name ASC", classID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contacts := []ClassContact{}
+	for rows.Next() {
+		contact := ClassContact{}
+		err = rows.Scan(&contact.ID, &contact.ClassID, &contact.Name, &contact.Role, &contact.Email, &contact.UserID)
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, contact)
+	}
+
+	return contacts, nil
+}
+
+// GetOfficeHoursForClass returns all ClassOfficeHours for the given class.
+func GetOfficeHoursForClass(classID int) ([]ClassOfficeHours, error) {
+	rows, err := DB.Query("SELECT id, classId, IFNULL(contactId, -1), dayOfWeek, `start`, `end`, location, userId FROM class_office_hours WHERE classId = ? ORDER BY dayOfWeek ASC, `start` ASC", classID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	officeHours := []ClassOfficeHours{}
+	for rows.Next() {
+		item := ClassOfficeHours{}
+		err = rows.Scan(&item.ID, &item.ClassID, &item.ContactID, &item.DayOfWeek, &item.Start, &item.End, &item.Location, &item.UserID)
+		if err != nil {
+			return nil, err
+		}
+		officeHours = append(officeHours, item)
+	}
+
+	return officeHours, nil
+}
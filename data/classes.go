@@ -1,29 +1,80 @@
 package data
 
+import "database/sql"
+
 // A HomeworkClass is a class that can be associated with Homework items.
 type HomeworkClass struct {
-	ID        int    `json:"id"`
-	Name      string `json:"name"`
-	Teacher   string `json:"teacher"`
-	Color     string `json:"color"`
-	SortIndex int    `json:"sortIndex"`
-	UserID    int    `json:"userId"`
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Teacher string `json:"teacher"`
+	Color   string `json:"color"`
+
+	// links to the class's website, syllabus, and video meeting, which are empty if not set
+	Website     string `json:"website"`
+	Syllabus    string `json:"syllabus"`
+	MeetingLink string `json:"meetingLink"`
+
+	SortIndex int  `json:"sortIndex"`
+	UserID    int  `json:"userId"`
+	TermID    int  `json:"termId"`
+	Archived  bool `json:"archived"`
+
+	// SchoolID and SchoolClassID link the class to the school schedule it was created from, and are empty otherwise.
+	SchoolID      string `json:"schoolId"`
+	SchoolClassID string `json:"schoolClassId"`
 }
 
-// GetClassesForUser gets all HomeworkClasses for the given user.
-func GetClassesForUser(user *User) ([]HomeworkClass, error) {
-	rows, err := DB.Query("SELECT id, name, teacher, color, sortIndex, userId FROM classes WHERE userId = ? ORDER BY sortIndex ASC", user.ID)
+const classSelect = "SELECT id, name, teacher, color, website, syllabus, meetingLink, sortIndex, userId, IFNULL(termId, -1), archived, IFNULL(schoolId, ''), IFNULL(schoolClassId, '') FROM classes "
+
+func getClassesForUser(user *User, includeArchived bool) ([]HomeworkClass, error) {
+	rows, err := DB.Query(classSelect+"WHERE userId = ? AND trashId IS NULL AND (archived = 0 OR ? = 1) ORDER BY sortIndex ASC", user.ID, includeArchived)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return scanClasses(rows)
+}
+
+func scanClasses(rows *sql.Rows) ([]HomeworkClass, error) {
 	classes := []HomeworkClass{}
 	for rows.Next() {
-		resp := HomeworkClass{-1, "", "", "", -1, -1}
-		rows.Scan(&resp.ID, &resp.Name, &resp.Teacher, &resp.Color, &resp.SortIndex, &resp.UserID)
+		resp := HomeworkClass{-1, "", "", "", "", "", "", -1, -1, -1, false, "", ""}
+		archivedInt := -1
+		rows.Scan(&resp.ID, &resp.Name, &resp.Teacher, &resp.Color, &resp.Website, &resp.Syllabus, &resp.MeetingLink, &resp.SortIndex, &resp.UserID, &resp.TermID, &archivedInt, &resp.SchoolID, &resp.SchoolClassID)
+		resp.Archived = (archivedInt == 1)
 		classes = append(classes, resp)
 	}
 
 	return classes, nil
 }
+
+// GetClassesForUser gets all HomeworkClasses for the given user that haven't been archived.
+func GetClassesForUser(user *User) ([]HomeworkClass, error) {
+	return getClassesForUser(user, false)
+}
+
+// GetAllClassesForUser gets all HomeworkClasses for the given user, including archived ones.
+func GetAllClassesForUser(user *User) ([]HomeworkClass, error) {
+	return getClassesForUser(user, true)
+}
+
+// GetClass returns the class with the given ID, even if it's in the trash.
+func GetClass(id int) (HomeworkClass, error) {
+	rows, err := DB.Query(classSelect+"WHERE id = ?", id)
+	if err != nil {
+		return HomeworkClass{}, err
+	}
+	defer rows.Close()
+
+	classes, err := scanClasses(rows)
+	if err != nil {
+		return HomeworkClass{}, err
+	}
+
+	if len(classes) == 0 {
+		return HomeworkClass{}, ErrNotFound
+	}
+
+	return classes[0], nil
+}
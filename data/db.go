@@ -3,8 +3,18 @@ package data
 import (
 	"database/sql"
 
+	"github.com/go-sql-driver/mysql"
 	"gopkg.in/redis.v5"
 )
 
+// mysqlErrorDuplicateKey is the MySQL error number for ER_DUP_ENTRY.
+const mysqlErrorDuplicateKey = 1062
+
 var DB *sql.DB
 var RedisClient *redis.Client
+
+// IsDuplicateKeyError checks if the given error came from a query that would have broken a UNIQUE key.
+func IsDuplicateKeyError(err error) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	return ok && mysqlErr.Number == mysqlErrorDuplicateKey
+}
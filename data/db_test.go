@@ -0,0 +1,125 @@
+package data
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+)
+
+// testDatabase stands in for MySQL in tests, by recording every query and giving back canned results.
+type testDatabase struct {
+	queries      []testQuery
+	columns      []string
+	rows         [][]driver.Value
+	rowsAffected int64
+}
+
+type testQuery struct {
+	query string
+	args  []driver.Value
+}
+
+var currentTestDatabase *testDatabase
+
+func init() {
+	sql.Register("mhstest", testDriver{})
+}
+
+// useTestDatabase points DB at a new testDatabase, until the returned function is called.
+func useTestDatabase(t *testing.T) (*testDatabase, func()) {
+	db, err := sql.Open("mhstest", "")
+	if err != nil {
+		t.Fatalf("opening test database: %s", err)
+	}
+
+	oldDB := DB
+	DB = db
+	currentTestDatabase = &testDatabase{rowsAffected: 1}
+
+	return currentTestDatabase, func() {
+		DB = oldDB
+		currentTestDatabase = nil
+		db.Close()
+	}
+}
+
+type testDriver struct{}
+
+func (testDriver) Open(name string) (driver.Conn, error) {
+	return testConn{}, nil
+}
+
+type testConn struct{}
+
+func (testConn) Prepare(query string) (driver.Stmt, error) {
+	return testStmt{query}, nil
+}
+
+func (testConn) Close() error {
+	return nil
+}
+
+func (testConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("data: the test database doesn't support transactions")
+}
+
+type testStmt struct {
+	query string
+}
+
+func (s testStmt) Close() error {
+	return nil
+}
+
+func (s testStmt) NumInput() int {
+	return -1
+}
+
+func (s testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	db := currentTestDatabase
+	db.queries = append(db.queries, testQuery{s.query, args})
+	return testResult{int64(len(db.queries)), db.rowsAffected}, nil
+}
+
+func (s testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	db := currentTestDatabase
+	db.queries = append(db.queries, testQuery{s.query, args})
+	return &testRows{db.columns, db.rows}, nil
+}
+
+type testResult struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r testResult) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r testResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+type testRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *testRows) Columns() []string {
+	return r.columns
+}
+
+func (r *testRows) Close() error {
+	return nil
+}
+
+func (r *testRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,141 @@
+package data
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DefaultDigestSettings are the settings used for a user who has never changed them. The UserID and UnsubscribeToken have to be filled in.
+var DefaultDigestSettings = DigestSettings{
+	UserID:    -1,
+	Daily:     false,
+	Weekly:    false,
+	WeeklyDay: time.Sunday,
+	SendTime:  7 * 60,
+	Timezone:  "America/New_York",
+}
+
+// DigestSettings control which digest emails a user gets, and when.
+// Weekly digests are sent instead of the daily one on WeeklyDay, and both are sent at SendTime in the user's timezone.
+type DigestSettings struct {
+	UserID           int          `json:"userId"`
+	Daily            bool         `json:"daily"`
+	Weekly           bool         `json:"weekly"`
+	WeeklyDay        time.Weekday `json:"weeklyDay"`
+	SendTime         int          `json:"sendTime"` // minutes after midnight
+	Timezone         string       `json:"timezone"`
+	UnsubscribeToken string       `json:"-"`
+	LastDailySent    string       `json:"-"` // empty if it was never sent
+	LastWeeklySent   string       `json:"-"` // empty if it was never sent
+}
+
+const digestSettingsSelect = "SELECT userId, daily, weekly, weeklyDay, sendTime, timezone, unsubscribeToken, IFNULL(lastDailySent, ''), IFNULL(lastWeeklySent, '') FROM digest_settings "
+
+func scanDigestSettings(rows *sql.Rows) ([]DigestSettings, error) {
+	allSettings := []DigestSettings{}
+	for rows.Next() {
+		settings := DigestSettings{}
+		dailyInt, weeklyInt := -1, -1
+		err := rows.Scan(&settings.UserID, &dailyInt, &weeklyInt, &settings.WeeklyDay, &settings.SendTime, &settings.Timezone, &settings.UnsubscribeToken, &settings.LastDailySent, &settings.LastWeeklySent)
+		if err != nil {
+			return nil, err
+		}
+		settings.Daily = (dailyInt == 1)
+		settings.Weekly = (weeklyInt == 1)
+		allSettings = append(allSettings, settings)
+	}
+	return allSettings, nil
+}
+
+// GetDigestSettingsForUser fetches the DigestSettings of the given user, or ErrNotFound if they've never set them.
+func GetDigestSettingsForUser(userID int) (DigestSettings, error) {
+	rows, err := DB.Query(digestSettingsSelect+"WHERE userId = ?", userID)
+	if err != nil {
+		return DigestSettings{}, err
+	}
+	defer rows.Close()
+
+	allSettings, err := scanDigestSettings(rows)
+	if err != nil {
+		return DigestSettings{}, err
+	}
+
+	if len(allSettings) == 0 {
+		return DigestSettings{}, ErrNotFound
+	}
+
+	return allSettings[0], nil
+}
+
+// GetDigestSettingsByUnsubscribeToken fetches the DigestSettings with the given unsubscribe token, or ErrNotFound if there aren't any.
+func GetDigestSettingsByUnsubscribeToken(token string) (DigestSettings, error) {
+	rows, err := DB.Query(digestSettingsSelect+"WHERE unsubscribeToken = ?", token)
+	if err != nil {
+		return DigestSettings{}, err
+	}
+	defer rows.Close()
+
+	allSettings, err := scanDigestSettings(rows)
+	if err != nil {
+		return DigestSettings{}, err
+	}
+
+	if len(allSettings) == 0 {
+		return DigestSettings{}, ErrNotFound
+	}
+
+	return allSettings[0], nil
+}
+
+// GetEnabledDigestSettings returns the DigestSettings of every user who gets at least one kind of digest.
+func GetEnabledDigestSettings(db *sql.DB) ([]DigestSettings, error) {
+	rows, err := db.Query(digestSettingsSelect + "WHERE daily = 1 OR weekly = 1")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanDigestSettings(rows)
+}
+
+// SaveDigestSettings creates or updates the given DigestSettings. The times that digests were last sent aren't changed.
+func SaveDigestSettings(settings DigestSettings) error {
+	_, err := DB.Exec(
+		"INSERT INTO digest_settings(userId, daily, weekly, weeklyDay, sendTime, timezone, unsubscribeToken) VALUES(?, ?, ?, ?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE daily = VALUES(daily), weekly = VALUES(weekly), weeklyDay = VALUES(weeklyDay), sendTime = VALUES(sendTime), timezone = VALUES(timezone)",
+		settings.UserID, settings.Daily, settings.Weekly, settings.WeeklyDay, settings.SendTime, settings.Timezone, settings.UnsubscribeToken,
+	)
+	return err
+}
+
+// ClaimDigest marks the given user's digest as sent on the given day, which should be in their timezone, if it hasn't been already.
+// This happens before the digest is actually sent, so that if two runs of the task overlap, only the one that gets the claim sends it.
+// A weekly digest takes the place of the daily one, so it counts as both.
+func ClaimDigest(db *sql.DB, userID int, day string, weekly bool) (bool, error) {
+	var result sql.Result
+	var err error
+	if weekly {
+		result, err = db.Exec("UPDATE digest_settings SET lastDailySent = ?, lastWeeklySent = ? WHERE userId = ? AND (lastWeeklySent IS NULL OR lastWeeklySent != ?)", day, day, userID, day)
+	} else {
+		result, err = db.Exec("UPDATE digest_settings SET lastDailySent = ? WHERE userId = ? AND (lastDailySent IS NULL OR lastDailySent != ?)", day, userID, day)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return (rowsAffected > 0), nil
+}
+
+// ReleaseDigestClaim undoes ClaimDigest for a digest that couldn't be sent, putting back the times from the given settings, so that it gets tried again.
+func ReleaseDigestClaim(db *sql.DB, settings DigestSettings) error {
+	_, err := db.Exec(
+		"UPDATE digest_settings SET lastDailySent = NULLIF(?, ''), lastWeeklySent = NULLIF(?, '') WHERE userId = ?",
+		settings.LastDailySent, settings.LastWeeklySent, settings.UserID,
+	)
+	return err
+}
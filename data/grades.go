@@ -0,0 +1,126 @@
+package data
+
+import "database/sql"
+
+// A GradeCategory is a group of graded items in a class, like "Problem sets" or "Exams", that counts for a certain weight of the final grade.
+type GradeCategory struct {
+	ID      int     `json:"id"`
+	ClassID int     `json:"classId"`
+	Name    string  `json:"name"`
+	Weight  float64 `json:"weight"`
+	UserID  int     `json:"userId"`
+}
+
+// A GradeItem is something that's graded, like a homework assignment or an exam. It might be linked to a Homework item.
+type GradeItem struct {
+	ID         int      `json:"id"`
+	CategoryID int      `json:"categoryId"`
+	ClassID    int      `json:"classId"`
+	HomeworkID int      `json:"homeworkId"` // -1 if it's not linked to homework
+	Name       string   `json:"name"`
+	Score      *float64 `json:"score"` // nil if it hasn't been graded yet
+	MaxScore   float64  `json:"maxScore"`
+	UserID     int      `json:"userId"`
+}
+
+func getGradeCategories(query string, args ...interface{}) ([]GradeCategory, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []GradeCategory{}
+	for rows.Next() {
+		category := GradeCategory{}
+		err = rows.Scan(&category.ID, &category.ClassID, &category.Name, &category.Weight, &category.UserID)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, nil
+}
+
+func getGradeItems(query string, args ...interface{}) ([]GradeItem, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []GradeItem{}
+	for rows.Next() {
+		item := GradeItem{}
+		score := sql.NullFloat64{}
+		err = rows.Scan(&item.ID, &item.CategoryID, &item.ClassID, &item.HomeworkID, &item.Name, &score, &item.MaxScore, &item.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if score.Valid {
+			item.Score = &score.Float64
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// GetGradeCategoryByID returns the GradeCategory with the given ID, or ErrNotFound if it doesn't exist.
+func GetGradeCategoryByID(id int) (GradeCategory, error) {
+	categories, err := getGradeCategories("SELECT id, classId, name, weight, userId FROM grade_categories WHERE id = ?", id)
+	if err != nil {
+		return GradeCategory{}, err
+	}
+
+	if len(categories) == 0 {
+		return GradeCategory{}, ErrNotFound
+	}
+
+	return categories[0], nil
+}
+
+// GetGradeCategoriesForClass returns all GradeCategories in the given class.
+func GetGradeCategoriesForClass(classID int) ([]GradeCategory, error) {
+	return getGradeCategories("SELECT id, classId, name, weight, userId FROM grade_categories WHERE classId = ? ORDER BY id ASC", classID)
+}
+
+// GetGradeCategoriesForUser returns all GradeCategories in the given user's classes that haven't been deleted.
+func GetGradeCategoriesForUser(userID int) ([]GradeCategory, error) {
+	return getGradeCategories(
+		"SELECT grade_categories.id, grade_categories.classId, grade_categories.name, grade_categories.weight, grade_categories.userId FROM grade_categories "+
+			"INNER JOIN classes ON grade_categories.classId = classes.id "+
+			"WHERE grade_categories.userId = ? AND classes.trashId IS NULL ORDER BY grade_categories.id ASC",
+		userID,
+	)
+}
+
+// GetGradeItemByID returns the GradeItem with the given ID, or ErrNotFound if it doesn't exist.
+func GetGradeItemByID(id int) (GradeItem, error) {
+	items, err := getGradeItems("SELECT id, categoryId, classId, IFNULL(homeworkId, -1), name, score, maxScore, userId FROM grade_items WHERE id = ?", id)
+	if err != nil {
+		return GradeItem{}, err
+	}
+
+	if len(items) == 0 {
+		return GradeItem{}, ErrNotFound
+	}
+
+	return items[0], nil
+}
+
+// GetGradeItemsForClass returns all GradeItems in the given class.
+func GetGradeItemsForClass(classID int) ([]GradeItem, error) {
+	return getGradeItems("SELECT id, categoryId, classId, IFNULL(homeworkId, -1), name, score, maxScore, userId FROM grade_items WHERE classId = ? ORDER BY id ASC", classID)
+}
+
+// GetGradeItemsForUser returns all GradeItems in the given user's classes that haven't been deleted.
+func GetGradeItemsForUser(userID int) ([]GradeItem, error) {
+	return getGradeItems(
+		"SELECT grade_items.id, grade_items.categoryId, grade_items.classId, IFNULL(grade_items.homeworkId, -1), grade_items.name, grade_items.score, grade_items.maxScore, grade_items.userId FROM grade_items "+
+			"INNER JOIN classes ON grade_items.classId = classes.id "+
+			"WHERE grade_items.userId = ? AND classes.trashId IS NULL ORDER BY grade_items.id ASC",
+		userID,
+	)
+}
@@ -9,3 +9,24 @@ type Homework struct {
 	ClassID  int    `json:"classId"`
 	UserID   int    `json:"userId"`
 }
+
+// GetHomework returns the homework with the given ID, even if it's in the trash.
+func GetHomework(id int) (Homework, error) {
+	rows, err := DB.Query("SELECT id, name, `due`, `desc`, `complete`, classId, userId FROM homework WHERE id = ?", id)
+	if err != nil {
+		return Homework{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return Homework{}, ErrNotFound
+	}
+
+	homework := Homework{}
+	err = rows.Scan(&homework.ID, &homework.Name, &homework.Due, &homework.Desc, &homework.Complete, &homework.ClassID, &homework.UserID)
+	if err != nil {
+		return Homework{}, err
+	}
+
+	return homework, nil
+}
@@ -0,0 +1,76 @@
+package data
+
+import "database/sql"
+
+// A HomeworkEventLink connects a Homework item to a meeting of a class on the user's schedule, so that the homework is due whenever that meeting happens.
+type HomeworkEventLink struct {
+	HomeworkID int    `json:"homeworkId"`
+	EventID    string `json:"eventId"`
+	EventDate  string `json:"eventDate"` // the day the event was scheduled for by its provider, before any EventChanges
+	UserID     int    `json:"userId"`
+}
+
+func scanHomeworkEventLinks(rows *sql.Rows) ([]HomeworkEventLink, error) {
+	links := []HomeworkEventLink{}
+	for rows.Next() {
+		link := HomeworkEventLink{}
+		err := rows.Scan(&link.HomeworkID, &link.EventID, &link.EventDate, &link.UserID)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+
+	return links, nil
+}
+
+// GetHomeworkEventLinkForHomework returns the HomeworkEventLink for the given homework, or ErrNotFound if it isn't linked to an event.
+func GetHomeworkEventLinkForHomework(homeworkID int) (HomeworkEventLink, error) {
+	rows, err := DB.Query("SELECT homeworkId, eventId, eventDate, userId FROM homework_events WHERE homeworkId = ?", homeworkID)
+	if err != nil {
+		return HomeworkEventLink{}, err
+	}
+	defer rows.Close()
+
+	links, err := scanHomeworkEventLinks(rows)
+	if err != nil {
+		return HomeworkEventLink{}, err
+	}
+
+	if len(links) == 0 {
+		return HomeworkEventLink{}, ErrNotFound
+	}
+
+	return links[0], nil
+}
+
+// GetHomeworkEventLinksForEvent returns all HomeworkEventLinks from the given user's homework to the event with the given UniqueID.
+func GetHomeworkEventLinksForEvent(userID int, eventID string) ([]HomeworkEventLink, error) {
+	rows, err := DB.Query(
+		"SELECT homework_events.homeworkId, homework_events.eventId, homework_events.eventDate, homework_events.userId FROM homework_events "+
+			"INNER JOIN homework ON homework_events.homeworkId = homework.id "+
+			"WHERE homework_events.userId = ? AND homework_events.eventId = ? AND homework.trashId IS NULL",
+		userID, eventID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanHomeworkEventLinks(rows)
+}
+
+// SetHomeworkEventLink links a homework item to an event, replacing whatever it was linked to before.
+func SetHomeworkEventLink(tx *sql.Tx, link HomeworkEventLink) error {
+	_, err := tx.Exec(
+		"INSERT INTO homework_events(homeworkId, eventId, eventDate, userId) VALUES(?, ?, ?, ?) ON DUPLICATE KEY UPDATE eventId = VALUES(eventId), eventDate = VALUES(eventDate)",
+		link.HomeworkID, link.EventID, link.EventDate, link.UserID,
+	)
+	return err
+}
+
+// DeleteHomeworkEventLink removes the link between the given homework and its event, if there is one.
+func DeleteHomeworkEventLink(tx *sql.Tx, homeworkID int) error {
+	_, err := tx.Exec("DELETE FROM homework_events WHERE homeworkId = ?", homeworkID)
+	return err
+}
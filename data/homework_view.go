@@ -0,0 +1,103 @@
+package data
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// A HomeworkView sorts a user's homework into the columns shown on the homework page.
+type HomeworkView struct {
+	TomorrowName string
+	ShowToday    bool
+	Overdue      []Homework
+	Today        []Homework
+	Tomorrow     []Homework
+	Soon         []Homework
+	Longterm     []Homework
+}
+
+// GetHiddenClassesForUser returns the IDs of the classes that the given user has hidden from the homework page.
+func GetHiddenClassesForUser(userID int) ([]int, error) {
+	hiddenPref, err := GetPrefForUser("homeworkHiddenClasses", userID)
+	if err == ErrNotFound {
+		return []int{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	hiddenClasses := []int{}
+	err = json.Unmarshal([]byte(hiddenPref.Value), &hiddenClasses)
+	if err != nil {
+		// just ignore the error
+		return []int{}, nil
+	}
+
+	return hiddenClasses, nil
+}
+
+// SortHomeworkView sorts the given homework, which should be in order of due date, into a HomeworkView as of the given time.
+// If showToday is false, or if it's the weekend, homework due today goes in the overdue column instead of its own.
+func SortHomeworkView(homework []Homework, now time.Time, showToday bool) (HomeworkView, error) {
+	view := HomeworkView{
+		TomorrowName: "Tomorrow",
+		ShowToday:    showToday,
+		Overdue:      []Homework{},
+		Today:        []Homework{},
+		Tomorrow:     []Homework{},
+		Soon:         []Homework{},
+		Longterm:     []Homework{},
+	}
+
+	tomorrowTimeToThreshold := 24 * time.Hour
+
+	if now.Weekday() == time.Friday || now.Weekday() == time.Saturday {
+		view.TomorrowName = "Monday"
+		if now.Weekday() == time.Friday {
+			tomorrowTimeToThreshold = 3 * 24 * time.Hour
+		} else {
+			tomorrowTimeToThreshold = 2 * 24 * time.Hour
+		}
+	}
+
+	if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
+		view.ShowToday = false
+	}
+
+	for _, item := range homework {
+		dueDate, err := time.ParseInLocation("2006-01-02", item.Due, now.Location())
+		if err != nil {
+			return HomeworkView{}, err
+		}
+
+		timeUntilDue := dueDate.Sub(now)
+		if timeUntilDue < 0 {
+			if timeUntilDue > 0-(24*time.Hour) {
+				// it's in the today column
+				view.Today = append(view.Today, item)
+			} else if item.Complete == 0 {
+				// it's overdue
+				view.Overdue = append(view.Overdue, item)
+			}
+		} else if timeUntilDue <= tomorrowTimeToThreshold {
+			// it's in the tomorrow column
+			view.Tomorrow = append(view.Tomorrow, item)
+		} else if timeUntilDue <= 5*24*time.Hour {
+			// it's in the soon column
+			view.Soon = append(view.Soon, item)
+		} else {
+			// it's in the longterm column
+			view.Longterm = append(view.Longterm, item)
+		}
+	}
+
+	if !view.ShowToday {
+		for _, item := range view.Today {
+			if item.Complete == 0 {
+				view.Overdue = append(view.Overdue, item)
+			}
+		}
+		view.Today = []Homework{}
+	}
+
+	return view, nil
+}
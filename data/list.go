@@ -0,0 +1,37 @@
+package data
+
+import (
+	"strings"
+
+	"github.com/MyHomeworkSpace/api-server/util"
+)
+
+// parseList reads a space-separated list of values, like scopes or webhook events, skipping duplicates.
+// Values that aren't in allowed are left out, and make the second return value false.
+func parseList(text string, allowed []string) ([]string, bool) {
+	values := []string{}
+	valid := true
+
+	for _, part := range strings.Fields(text) {
+		if !util.StringSliceContains(allowed, part) {
+			valid = false
+			continue
+		}
+		if !util.StringSliceContains(values, part) {
+			values = append(values, part)
+		}
+	}
+
+	return values, valid
+}
+
+// formatList turns the given values into a space-separated list, in the same order as allowed, so that it doesn't depend on how the values were given.
+func formatList(values []string, allowed []string) string {
+	parts := []string{}
+	for _, value := range allowed {
+		if util.StringSliceContains(values, value) {
+			parts = append(parts, value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
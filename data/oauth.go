@@ -0,0 +1,210 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/util"
+
+	"gopkg.in/redis.v5"
+)
+
+// OAuthAccessTokenLifetime is how long an access token can be used before the application has to refresh it.
+const OAuthAccessTokenLifetime = time.Hour
+
+// OAuthRefreshTokenLifetime is how long a refresh token can go unused before the user has to authorize the application again.
+const OAuthRefreshTokenLifetime = 60 * 24 * time.Hour
+
+// OAuthCodeLifetime is how long an application has to exchange an authorization code for tokens.
+const OAuthCodeLifetime = 10 * time.Minute
+
+// An OAuthTokenType is the kind of an OAuthToken.
+type OAuthTokenType string
+
+// The OAuthTokenTypes that can be issued.
+const (
+	OAuthTokenAccess  OAuthTokenType = "access"
+	OAuthTokenRefresh OAuthTokenType = "refresh"
+)
+
+// An OAuthToken is an access or refresh token that an application got through OAuth.
+// Only a hash of the token itself is stored.
+type OAuthToken struct {
+	ID              int
+	Type            OAuthTokenType
+	ParentID        int // for an access token, the refresh token it was issued with, or -1
+	CreatedAt       int
+	ExpiresAt       int
+	AuthorizationID int
+	ApplicationID   int
+	UserID          int
+	Scopes          []Scope // from the authorization, since every token for it can do the same things
+}
+
+// An OAuthCode is an authorization code, which an application exchanges for tokens once the user has approved it.
+type OAuthCode struct {
+	AuthorizationID int    `json:"authorizationId"`
+	ApplicationID   int    `json:"applicationId"`
+	UserID          int    `json:"userId"`
+	RedirectURI     string `json:"redirectUri"` // empty if the application didn't give one and the default was used
+	CodeChallenge   string `json:"codeChallenge"`
+}
+
+const oauthTokenSelect = "SELECT oauth_tokens.id, oauth_tokens.type, IFNULL(oauth_tokens.parentId, -1), oauth_tokens.createdAt, oauth_tokens.expiresAt, oauth_tokens.authorizationId, application_authorizations.applicationId, application_authorizations.userId, application_authorizations.scopes FROM oauth_tokens INNER JOIN application_authorizations ON oauth_tokens.authorizationId = application_authorizations.id "
+
+func getOAuthCodeKey(code string) string {
+	return "oauth_code:" + util.HashToken(code)
+}
+
+// GetOAuthToken looks up the given token. It doesn't check whether the token has expired.
+func GetOAuthToken(token string) (OAuthToken, error) {
+	rows, err := DB.Query(oauthTokenSelect+"WHERE oauth_tokens.tokenHash = ?", util.HashToken(token))
+	if err != nil {
+		return OAuthToken{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return OAuthToken{}, ErrNotFound
+	}
+
+	result := OAuthToken{}
+	scopes := ""
+	err = rows.Scan(&result.ID, &result.Type, &result.ParentID, &result.CreatedAt, &result.ExpiresAt, &result.AuthorizationID, &result.ApplicationID, &result.UserID, &scopes)
+	if err != nil {
+		return OAuthToken{}, err
+	}
+
+	result.Scopes, _ = ParseScopes(scopes)
+
+	return result, nil
+}
+
+func insertOAuthToken(tx *sql.Tx, tokenType OAuthTokenType, parentID int, createdAt time.Time, lifetime time.Duration, authorizationID int) (string, int, error) {
+	token, err := util.GenerateRandomString(40)
+	if err != nil {
+		return "", -1, err
+	}
+
+	var parent interface{}
+	if parentID != -1 {
+		parent = parentID
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO oauth_tokens(type, tokenHash, parentId, createdAt, expiresAt, authorizationId) VALUES(?, ?, ?, ?, ?, ?)",
+		string(tokenType), util.HashToken(token), parent, createdAt.Unix(), createdAt.Add(lifetime).Unix(), authorizationID,
+	)
+	if err != nil {
+		return "", -1, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", -1, err
+	}
+
+	return token, int(id), nil
+}
+
+// IssueOAuthTokens creates a new access token and refresh token for the given authorization.
+// When a refresh token is being exchanged, its ID should be passed as replacing, or -1 otherwise. The old refresh token is deleted, and any access tokens issued with it are moved over to the new one, so that revoking the new one still revokes them.
+// If the old refresh token is already gone, ErrNotFound is returned, and the transaction should be rolled back.
+func IssueOAuthTokens(tx *sql.Tx, authorizationID int, replacing int, now time.Time) (string, string, error) {
+	// expired tokens aren't good for anything, so this is as good a time as any to clean them up
+	_, err := tx.Exec("DELETE FROM oauth_tokens WHERE authorizationId = ? AND expiresAt < ?", authorizationID, now.Unix())
+	if err != nil {
+		return "", "", err
+	}
+
+	if replacing != -1 {
+		// if this doesn't find anything, the token was already exchanged by another request
+		result, err := tx.Exec("DELETE FROM oauth_tokens WHERE id = ?", replacing)
+		if err != nil {
+			return "", "", err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return "", "", err
+		}
+		if rowsAffected == 0 {
+			return "", "", ErrNotFound
+		}
+	}
+
+	refreshToken, refreshID, err := insertOAuthToken(tx, OAuthTokenRefresh, -1, now, OAuthRefreshTokenLifetime, authorizationID)
+	if err != nil {
+		return "", "", err
+	}
+
+	accessToken, _, err := insertOAuthToken(tx, OAuthTokenAccess, refreshID, now, OAuthAccessTokenLifetime, authorizationID)
+	if err != nil {
+		return "", "", err
+	}
+
+	if replacing != -1 {
+		_, err = tx.Exec("UPDATE oauth_tokens SET parentId = ? WHERE parentId = ?", refreshID, replacing)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// RevokeOAuthToken deletes the given token, along with any access tokens that were issued with it.
+func RevokeOAuthToken(id int) error {
+	_, err := DB.Exec("DELETE FROM oauth_tokens WHERE id = ? OR parentId = ?", id, id)
+	return err
+}
+
+// SaveOAuthCode creates a new authorization code with the given details, and returns it.
+func SaveOAuthCode(details OAuthCode) (string, error) {
+	code, err := util.GenerateRandomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return "", err
+	}
+
+	err = RedisClient.Set(getOAuthCodeKey(code), string(detailsJSON), OAuthCodeLifetime).Err()
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// UseOAuthCode looks up the given authorization code, and makes sure that it can't be used again.
+// If two requests try to use the same code, only one of them gets it.
+func UseOAuthCode(code string) (OAuthCode, error) {
+	key := getOAuthCodeKey(code)
+
+	detailsJSON, err := RedisClient.Get(key).Result()
+	if err == redis.Nil {
+		return OAuthCode{}, ErrNotFound
+	} else if err != nil {
+		return OAuthCode{}, err
+	}
+
+	deleted, err := RedisClient.Del(key).Result()
+	if err != nil {
+		return OAuthCode{}, err
+	}
+	if deleted == 0 {
+		return OAuthCode{}, ErrNotFound
+	}
+
+	details := OAuthCode{}
+	err = json.Unmarshal([]byte(detailsJSON), &details)
+	if err != nil {
+		return OAuthCode{}, err
+	}
+
+	return details, nil
+}
@@ -0,0 +1,133 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"strings"
+
+	"github.com/MyHomeworkSpace/api-server/util"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token, so that they're easy to recognize if one gets leaked.
+const PersonalAccessTokenPrefix = "mhs_pat_"
+
+// MaxPersonalAccessTokenNameLength is the longest name that a PersonalAccessToken can have.
+const MaxPersonalAccessTokenNameLength = 100
+
+// MaxPersonalAccessTokens is the most personal access tokens that one user can have.
+const MaxPersonalAccessTokens = 50
+
+// A PersonalAccessToken is a token that a user made for their own scripts, which works like an application's token.
+// Only a hash of the token itself is stored.
+type PersonalAccessToken struct {
+	ID         int     `json:"id"`
+	Name       string  `json:"name"`
+	Scopes     []Scope `json:"scopes"`
+	CreatedAt  int     `json:"createdAt"`
+	ExpiresAt  int     `json:"expiresAt"`  // -1 if the token never expires
+	LastUsedAt int     `json:"lastUsedAt"` // -1 if the token was never used
+	UserID     int     `json:"userId"`
+}
+
+const personalAccessTokenSelect = "SELECT id, name, scopes, createdAt, IFNULL(expiresAt, -1), IFNULL(lastUsedAt, -1), userId FROM personal_access_tokens "
+
+func scanPersonalAccessTokens(rows *sql.Rows) ([]PersonalAccessToken, error) {
+	tokens := []PersonalAccessToken{}
+	for rows.Next() {
+		token := PersonalAccessToken{}
+		scopes := ""
+		err := rows.Scan(&token.ID, &token.Name, &scopes, &token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt, &token.UserID)
+		if err != nil {
+			return nil, err
+		}
+		token.Scopes, _ = ParseScopes(scopes)
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+// IsPersonalAccessToken returns whether the given token looks like a personal access token, rather than an application's token.
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// GetPersonalAccessToken looks up the given token. It doesn't check whether the token has expired.
+func GetPersonalAccessToken(token string) (PersonalAccessToken, error) {
+	rows, err := DB.Query(personalAccessTokenSelect+"WHERE tokenHash = ?", util.HashToken(token))
+	if err != nil {
+		return PersonalAccessToken{}, err
+	}
+	defer rows.Close()
+
+	tokens, err := scanPersonalAccessTokens(rows)
+	if err != nil {
+		return PersonalAccessToken{}, err
+	}
+
+	if len(tokens) == 0 {
+		return PersonalAccessToken{}, ErrNotFound
+	}
+
+	return tokens[0], nil
+}
+
+// GetPersonalAccessTokensForUser returns all of the given user's personal access tokens, oldest first.
+func GetPersonalAccessTokensForUser(userID int) ([]PersonalAccessToken, error) {
+	rows, err := DB.Query(personalAccessTokenSelect+"WHERE userId = ? ORDER BY id ASC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPersonalAccessTokens(rows)
+}
+
+// AddPersonalAccessToken creates a new personal access token from the given details, and returns it.
+// The ID and LastUsedAt of the details are ignored, and an ExpiresAt of -1 means that the token never expires.
+func AddPersonalAccessToken(details PersonalAccessToken) (string, error) {
+	random, err := util.GenerateRandomBytes(32)
+	if err != nil {
+		return "", err
+	}
+
+	// no padding or dashes, so that the whole token can be selected with a double click
+	token := PersonalAccessTokenPrefix + hex.EncodeToString(random)
+
+	var expiresAt interface{}
+	if details.ExpiresAt != -1 {
+		expiresAt = details.ExpiresAt
+	}
+
+	_, err = DB.Exec(
+		"INSERT INTO personal_access_tokens(name, tokenHash, scopes, createdAt, expiresAt, userId) VALUES(?, ?, ?, ?, ?, ?)",
+		details.Name, util.HashToken(token), FormatScopes(details.Scopes), details.CreatedAt, expiresAt, details.UserID,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// DeletePersonalAccessToken deletes the personal access token with the given ID, if it belongs to the given user.
+// It returns whether there was a token to delete.
+func DeletePersonalAccessToken(tx *sql.Tx, id int, userID int) (bool, error) {
+	result, err := tx.Exec("DELETE FROM personal_access_tokens WHERE id = ? AND userId = ?", id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return (rowsAffected > 0), nil
+}
+
+// UpdatePersonalAccessTokenUse records that the personal access token with the given ID was just used.
+// To save on writes, it's only updated if the last use was more than a minute ago.
+func UpdatePersonalAccessTokenUse(id int, usedAt int) error {
+	_, err := DB.Exec("UPDATE personal_access_tokens SET lastUsedAt = ? WHERE id = ? AND (lastUsedAt IS NULL OR lastUsedAt < ?)", usedAt, id, usedAt-60)
+	return err
+}
@@ -1,19 +1,36 @@
 package data
 
 import (
+	"database/sql"
 	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
 )
 
 // A Prefix defines a group of words that get automatically recognized (for example: HW, Test, Quiz)
+// The words can also be phrases of multiple words, or regular expressions if Regex is set.
 type Prefix struct {
 	ID         int      `json:"id"`
+	Name       string   `json:"name"` // shown in place of the words, and required for regular expressions, which aren't meant to be read
 	Background string   `json:"background"`
 	Color      string   `json:"color"`
 	Words      []string `json:"words"`
 	TimedEvent bool     `json:"timedEvent"`
 	Default    bool     `json:"default"`
+	Regex      bool     `json:"regex"`
+	ClassIDs   []int    `json:"classIds"` // empty if the prefix applies to all classes
+	Priority   int      `json:"priority"` // higher priorities are checked first
+	PackID     int      `json:"packId"`   // 0 if the prefix doesn't come from a PrefixPack
 }
 
+// MaxPrefixPatternLength is the longest a regular expression in a Prefix can be.
+const MaxPrefixPatternLength = 256
+
+// MaxPrefixNameLength is the longest a Prefix's name can be.
+const MaxPrefixNameLength = 64
+
 // DefaultPrefixes is the list of prefixes that all users start out with.
 var DefaultPrefixes = []Prefix{
 	{
@@ -140,7 +157,40 @@ const FallbackBackground = "FFD3BD"
 // FallbackColor is the text color of a word that does not have an associated prefix.
 const FallbackColor = "000000"
 
-// GetPrefixesForUser returns a list of all prefixes for the given user, factoring in schools and custom settings.
+const prefixRowSelect = "SELECT prefixes.id, prefixes.name, prefixes.background, prefixes.color, prefixes.words, prefixes.isTimedEvent, prefixes.isRegex, prefixes.classIds, prefixes.priority"
+
+// scanPrefix reads a row selected with prefixRowSelect, followed by any extra columns.
+func scanPrefix(rows *sql.Rows, extra ...interface{}) (Prefix, error) {
+	resp := Prefix{}
+
+	timedEventInt := -1
+	regexInt := -1
+	wordsListString := ""
+	classIDsString := ""
+
+	err := rows.Scan(append([]interface{}{&resp.ID, &resp.Name, &resp.Background, &resp.Color, &wordsListString, &timedEventInt, &regexInt, &classIDsString, &resp.Priority}, extra...)...)
+	if err != nil {
+		return Prefix{}, err
+	}
+
+	err = json.Unmarshal([]byte(wordsListString), &resp.Words)
+	if err != nil {
+		return Prefix{}, err
+	}
+
+	err = json.Unmarshal([]byte(classIDsString), &resp.ClassIDs)
+	if err != nil {
+		return Prefix{}, err
+	}
+
+	resp.TimedEvent = (timedEventInt == 1)
+	resp.Regex = (regexInt == 1)
+
+	return resp, nil
+}
+
+// GetPrefixesForUser returns a list of all prefixes for the given user, factoring in schools, subscribed packs, and custom settings.
+// The list goes from defaults, to schools, to packs, to the user's own prefixes, so that when two prefixes have the same priority, the more personal one wins.
 func GetPrefixesForUser(user *User) ([]Prefix, error) {
 	prefixes := DefaultPrefixes
 
@@ -151,30 +201,174 @@ func GetPrefixesForUser(user *User) ([]Prefix, error) {
 		}
 	}
 
+	// add any packs the user subscribed to
+	packPrefixes, err := getPrefixesForSubscriber(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	// load user settings
-	rows, err := DB.Query("SELECT id, background, color, words, isTimedEvent FROM prefixes WHERE userId = ?", user.ID)
+	userPrefixes := []Prefix{}
+	rows, err := DB.Query(prefixRowSelect+" FROM prefixes WHERE userId = ? ORDER BY id ASC", user.ID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		resp := Prefix{}
+		resp, err := scanPrefix(rows)
+		if err != nil {
+			return nil, err
+		}
 
-		timedEventInt := -1
-		wordsListString := ""
+		userPrefixes = append(userPrefixes, resp)
+	}
 
-		rows.Scan(&resp.ID, &resp.Background, &resp.Color, &wordsListString, &timedEventInt)
+	clampPackPrefixPriorities(packPrefixes, userPrefixes)
 
-		err := json.Unmarshal([]byte(wordsListString), &resp.Words)
-		if err != nil {
-			return nil, err
+	prefixes = append(prefixes, packPrefixes...)
+	prefixes = append(prefixes, userPrefixes...)
+
+	return prefixes, nil
+}
+
+// clampPackPrefixPriorities lowers the priority of any pack prefixes that are above the user's own lowest priority.
+// A pack's author picks its priorities for their own prefixes, and shouldn't be able to override the subscriber's.
+func clampPackPrefixPriorities(packPrefixes []Prefix, userPrefixes []Prefix) {
+	if len(userPrefixes) == 0 {
+		return
+	}
+
+	lowestPriority := userPrefixes[0].Priority
+	for _, prefix := range userPrefixes {
+		if prefix.Priority < lowestPriority {
+			lowestPriority = prefix.Priority
+		}
+	}
+
+	// a tie goes to the user's prefix, since it comes later in the list
+	for i := range packPrefixes {
+		if packPrefixes[i].Priority > lowestPriority {
+			packPrefixes[i].Priority = lowestPriority
+		}
+	}
+}
+
+// DisplayName returns what the Prefix should be called when it's shown on its own, like in a list of stats.
+func (p Prefix) DisplayName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+
+	if !p.Regex && len(p.Words) > 0 {
+		return p.Words[0]
+	}
+
+	// only regular expression prefixes from before names were added can end up here
+	return "Custom prefix"
+}
+
+// CompilePrefixPattern compiles a regular expression from a Prefix. The pattern is case-insensitive and has to match at the start of the homework name.
+// Like plain words, the match has to end at a space or at the end of the name, so that a pattern like "test" doesn't match "testing".
+func CompilePrefixPattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?i:" + pattern + `)(?:\s|$)`)
+}
+
+// normalizePrefixText lowercases the given text and collapses any runs of whitespace into single spaces.
+func normalizePrefixText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+type prefixMatcherEntry struct {
+	prefix   Prefix
+	phrases  []string
+	patterns []*regexp.Regexp
+}
+
+// A PrefixMatcher finds which Prefix applies to a homework name.
+// Prefixes are tried from highest to lowest priority. If two prefixes have the same priority, the one later in the list wins, so that a user's custom prefixes override the defaults.
+type PrefixMatcher struct {
+	entries []prefixMatcherEntry
+}
+
+// NewPrefixMatcher creates a PrefixMatcher for the given list of prefixes. Regular expressions that fail to compile are skipped.
+func NewPrefixMatcher(prefixes []Prefix) *PrefixMatcher {
+	entries := []prefixMatcherEntry{}
+	for i := len(prefixes) - 1; i >= 0; i-- {
+		entry := prefixMatcherEntry{prefix: prefixes[i]}
+		for _, word := range prefixes[i].Words {
+			if prefixes[i].Regex {
+				pattern, err := CompilePrefixPattern(word)
+				if err != nil {
+					continue
+				}
+				entry.patterns = append(entry.patterns, pattern)
+			} else if phrase := normalizePrefixText(word); phrase != "" {
+				entry.phrases = append(entry.phrases, phrase)
+			}
 		}
+		entries = append(entries, entry)
+	}
 
-		resp.TimedEvent = (timedEventInt == 1)
+	// the list was reversed above, so a stable sort keeps later prefixes first within the same priority
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].prefix.Priority > entries[j].prefix.Priority
+	})
 
-		prefixes = append(prefixes, resp)
+	return &PrefixMatcher{entries}
+}
+
+func (e *prefixMatcherEntry) appliesToClass(classID int) bool {
+	if len(e.prefix.ClassIDs) == 0 {
+		return true
 	}
 
-	return prefixes, nil
+	for _, prefixClassID := range e.prefix.ClassIDs {
+		if prefixClassID == classID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Match finds the prefix that applies to the given homework name in the given class, along with the part of the name that it matched.
+// Prefixes that are limited to certain classes never match if classID is -1.
+func (m *PrefixMatcher) Match(name string, classID int) (Prefix, string, bool) {
+	trimmedName := strings.TrimSpace(name)
+	normalizedName := normalizePrefixText(name)
+	if normalizedName == "" {
+		return Prefix{}, "", false
+	}
+
+	for _, entry := range m.entries {
+		if !entry.appliesToClass(classID) {
+			continue
+		}
+
+		for _, phrase := range entry.phrases {
+			if normalizedName == phrase || strings.HasPrefix(normalizedName, phrase+" ") {
+				// give back the words as they were written in the name
+				matchWords := strings.Fields(trimmedName)[:len(strings.Fields(phrase))]
+				return entry.prefix, strings.Join(matchWords, " "), true
+			}
+		}
+
+		for _, pattern := range entry.patterns {
+			// the space that ends the match isn't part of it
+			match := strings.TrimRightFunc(pattern.FindString(trimmedName), unicode.IsSpace)
+			if match != "" {
+				return entry.prefix, match, true
+			}
+		}
+	}
+
+	return Prefix{}, "", false
+}
+
+// MatchPrefix finds the prefix that applies to the given homework name in the given class.
+// It's a shortcut for creating a PrefixMatcher and using it once.
+func MatchPrefix(prefixes []Prefix, name string, classID int) (Prefix, bool) {
+	prefix, _, found := NewPrefixMatcher(prefixes).Match(name, classID)
+	return prefix, found
 }
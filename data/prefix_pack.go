@@ -0,0 +1,182 @@
+package data
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/util"
+)
+
+// A PrefixPack is a named set of a user's prefixes that other users can subscribe to.
+// Packs point at the author's own prefixes, so subscribers see any changes the author makes to them.
+type PrefixPack struct {
+	ID              int    `json:"id"`
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	AuthorID        int    `json:"authorId"`
+	AuthorName      string `json:"authorName"`
+	PrefixCount     int    `json:"prefixCount"`
+	SubscriberCount int    `json:"subscriberCount"`
+	CreatedAt       int    `json:"createdAt"`
+	UpdatedAt       int    `json:"updatedAt"`
+}
+
+const prefixPackSelect = "SELECT prefix_packs.id, prefix_packs.name, prefix_packs.description, prefix_packs.authorId, users.name, " +
+	"(SELECT COUNT(*) FROM prefix_pack_items WHERE prefix_pack_items.packId = prefix_packs.id) AS prefixCount, " +
+	"(SELECT COUNT(*) FROM prefix_pack_subscriptions WHERE prefix_pack_subscriptions.packId = prefix_packs.id) AS subscriberCount, " +
+	"prefix_packs.createdAt, prefix_packs.updatedAt FROM prefix_packs " +
+	"INNER JOIN users ON prefix_packs.authorId = users.id "
+
+func scanPrefixPacks(rows *sql.Rows) ([]PrefixPack, error) {
+	packs := []PrefixPack{}
+	for rows.Next() {
+		pack := PrefixPack{}
+		err := rows.Scan(&pack.ID, &pack.Name, &pack.Description, &pack.AuthorID, &pack.AuthorName, &pack.PrefixCount, &pack.SubscriberCount, &pack.CreatedAt, &pack.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, pack)
+	}
+	return packs, nil
+}
+
+// GetPrefixPackByID fetches the PrefixPack with the given ID.
+func GetPrefixPackByID(id int) (PrefixPack, error) {
+	rows, err := DB.Query(prefixPackSelect+"WHERE prefix_packs.id = ?", id)
+	if err != nil {
+		return PrefixPack{}, err
+	}
+	defer rows.Close()
+
+	packs, err := scanPrefixPacks(rows)
+	if err != nil {
+		return PrefixPack{}, err
+	}
+
+	if len(packs) == 0 {
+		return PrefixPack{}, ErrNotFound
+	}
+
+	return packs[0], nil
+}
+
+// GetPrefixPacksByAuthor returns all PrefixPacks that the given user has published.
+func GetPrefixPacksByAuthor(userID int) ([]PrefixPack, error) {
+	rows, err := DB.Query(prefixPackSelect+"WHERE prefix_packs.authorId = ? ORDER BY prefix_packs.createdAt DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPrefixPacks(rows)
+}
+
+// GetPrefixPacksForSubscriber returns all PrefixPacks that the given user is subscribed to, in the order they subscribed to them.
+func GetPrefixPacksForSubscriber(userID int) ([]PrefixPack, error) {
+	rows, err := DB.Query(
+		prefixPackSelect+"INNER JOIN prefix_pack_subscriptions ON prefix_pack_subscriptions.packId = prefix_packs.id WHERE prefix_pack_subscriptions.userId = ? ORDER BY prefix_pack_subscriptions.id ASC",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPrefixPacks(rows)
+}
+
+// SearchPrefixPacks returns the most popular PrefixPacks whose name contains the given text. An empty query returns the most popular packs overall.
+func SearchPrefixPacks(query string, limit int) ([]PrefixPack, error) {
+	rows, err := DB.Query(
+		prefixPackSelect+"WHERE prefix_packs.name LIKE ? HAVING prefixCount > 0 ORDER BY subscriberCount DESC, prefix_packs.updatedAt DESC, prefix_packs.id ASC LIMIT ?",
+		"%"+util.EscapeLike(query)+"%", limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPrefixPacks(rows)
+}
+
+// GetPrefixesForPack returns the prefixes that are part of the given pack, as a subscriber would see them.
+func GetPrefixesForPack(packID int) ([]Prefix, error) {
+	rows, err := DB.Query(
+		prefixRowSelect+", prefix_pack_items.packId FROM prefix_pack_items INNER JOIN prefixes ON prefix_pack_items.prefixId = prefixes.id WHERE prefix_pack_items.packId = ? ORDER BY prefixes.id ASC",
+		packID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPackPrefixes(rows)
+}
+
+// getPrefixesForSubscriber returns the prefixes from every pack that the given user is subscribed to.
+// Packs are in the order the user subscribed to them, so that a newer subscription overrides an older one.
+func getPrefixesForSubscriber(userID int) ([]Prefix, error) {
+	rows, err := DB.Query(
+		prefixRowSelect+", prefix_pack_items.packId FROM prefix_pack_subscriptions "+
+			"INNER JOIN prefix_pack_items ON prefix_pack_subscriptions.packId = prefix_pack_items.packId "+
+			"INNER JOIN prefixes ON prefix_pack_items.prefixId = prefixes.id "+
+			"WHERE prefix_pack_subscriptions.userId = ? ORDER BY prefix_pack_subscriptions.id ASC, prefixes.id ASC",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPackPrefixes(rows)
+}
+
+func scanPackPrefixes(rows *sql.Rows) ([]Prefix, error) {
+	prefixes := []Prefix{}
+	for rows.Next() {
+		packID := -1
+		prefix, err := scanPrefix(rows, &packID)
+		if err != nil {
+			return nil, err
+		}
+
+		// the author's classes mean nothing to subscribers, and the prefix was limited to them for a reason
+		// packs can't be published with these, but the author could have limited the prefix afterwards
+		if len(prefix.ClassIDs) > 0 {
+			continue
+		}
+
+		// subscribers can't edit the prefix
+		prefix.ID = -1
+		prefix.PackID = packID
+
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// SetPrefixPackItems replaces the prefixes in the given pack.
+func SetPrefixPackItems(tx *sql.Tx, packID int, prefixIDs []int) error {
+	_, err := tx.Exec("DELETE FROM prefix_pack_items WHERE packId = ?", packID)
+	if err != nil {
+		return err
+	}
+
+	for _, prefixID := range prefixIDs {
+		_, err = tx.Exec("INSERT INTO prefix_pack_items(packId, prefixId) VALUES(?, ?)", packID, prefixID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// TouchPrefixPacksForPrefix marks every pack containing the given prefix as updated, so that subscribers can tell it changed.
+func TouchPrefixPacksForPrefix(tx *sql.Tx, prefixID int) error {
+	_, err := tx.Exec(
+		"UPDATE prefix_packs INNER JOIN prefix_pack_items ON prefix_packs.id = prefix_pack_items.packId SET prefix_packs.updatedAt = ? WHERE prefix_pack_items.prefixId = ?",
+		time.Now().Unix(), prefixID,
+	)
+	return err
+}
@@ -0,0 +1,148 @@
+package data
+
+import "testing"
+
+var testPrefixes = []Prefix{
+	{ID: -1, Words: []string{"HW", "Read"}, Default: true},
+	{ID: -1, Words: []string{"Test", "Exam"}, Default: true},
+	{ID: 1, Words: []string{"Problem Set", "pset"}},
+	{ID: 2, Words: []string{`ch(apter)?\s*\d+`}, Regex: true},
+	{ID: 3, Words: []string{"Lab"}, ClassIDs: []int{5}},
+	{ID: 4, Words: []string{"HW"}},
+	{ID: 5, Words: []string{"Test"}, Priority: -1},
+	{ID: 6, Words: []string{`(take-home )?test`}, Regex: true, ClassIDs: []int{7}, Priority: 10},
+}
+
+func testMatch(t *testing.T, name string, classID int, expectedID int, expectedMatch string) {
+	t.Helper()
+
+	prefix, match, found := NewPrefixMatcher(testPrefixes).Match(name, classID)
+	if expectedMatch == "" {
+		if found {
+			t.Errorf("%q in class %d: expected no match, got prefix %d", name, classID, prefix.ID)
+		}
+		return
+	}
+
+	if !found {
+		t.Errorf("%q in class %d: expected prefix %d, got no match", name, classID, expectedID)
+		return
+	}
+	if prefix.ID != expectedID || match != expectedMatch {
+		t.Errorf("%q in class %d: expected prefix %d matching %q, got prefix %d matching %q", name, classID, expectedID, expectedMatch, prefix.ID, match)
+	}
+}
+
+func TestPrefixMatcherWords(t *testing.T) {
+	testMatch(t, "read chapter 4", 1, -1, "read")
+	testMatch(t, "  Exam  on Friday", 1, -1, "Exam")
+	testMatch(t, "Reading", 1, -1, "")
+	testMatch(t, "", 1, -1, "")
+
+	// a later prefix with the same priority overrides an earlier one
+	testMatch(t, "HW page 12", 1, 4, "HW")
+}
+
+func TestPrefixMatcherPhrases(t *testing.T) {
+	testMatch(t, "Problem   set 3", 1, 1, "Problem set")
+	testMatch(t, "problem set", 1, 1, "problem set")
+	testMatch(t, "Problem sets", 1, -1, "")
+	testMatch(t, "Problem", 1, -1, "")
+}
+
+func TestPrefixMatcherRegex(t *testing.T) {
+	testMatch(t, "Ch 5 questions", 1, 2, "Ch 5")
+	testMatch(t, "chapter12 notes", 1, 2, "chapter12")
+
+	// patterns only match at the start of the name
+	testMatch(t, "Read ch 5", 1, -1, "Read")
+
+	// and have to end where a word does
+	testMatch(t, "Ch 5b questions", 1, -1, "")
+	testMatch(t, "Take-home testing", 7, -1, "")
+	testMatch(t, "Take-home test", 7, 6, "Take-home test")
+}
+
+func TestPrefixDisplayName(t *testing.T) {
+	if name := testPrefixes[2].DisplayName(); name != "Problem Set" {
+		t.Errorf("expected a plain prefix to be named after its first word, got %q", name)
+	}
+
+	if name := testPrefixes[3].DisplayName(); name == testPrefixes[3].Words[0] {
+		t.Errorf("expected a pattern to not be shown as a name, got %q", name)
+	}
+
+	named := Prefix{Name: "Chapters", Words: []string{`ch(apter)?\s*\d+`}, Regex: true}
+	if name := named.DisplayName(); name != "Chapters" {
+		t.Errorf("expected the prefix's own name, got %q", name)
+	}
+}
+
+func TestPrefixMatcherClassScope(t *testing.T) {
+	testMatch(t, "Lab report", 5, 3, "Lab")
+	testMatch(t, "Lab report", 6, -1, "")
+	testMatch(t, "Lab report", -1, -1, "")
+}
+
+func TestPrefixMatcherPriority(t *testing.T) {
+	// the custom Test prefix has a lower priority, so the default one wins
+	testMatch(t, "Test on Monday", 1, -1, "Test")
+
+	// the class-scoped pattern has the highest priority
+	testMatch(t, "Take-home test", 7, 6, "Take-home test")
+	testMatch(t, "Test on Monday", 7, 6, "Test")
+}
+
+func TestPrefixMatcherInvalidRegex(t *testing.T) {
+	prefixes := []Prefix{
+		{ID: 1, Words: []string{"(unclosed", "quiz"}, Regex: true},
+	}
+
+	prefix, found := MatchPrefix(prefixes, "Quiz tomorrow", -1)
+	if !found || prefix.ID != 1 {
+		t.Errorf("expected the valid pattern to still match, got %v %v", found, prefix.ID)
+	}
+}
+
+func TestPrefixMatcherPackConflicts(t *testing.T) {
+	// GetPrefixesForUser puts packs after the defaults and before the user's own prefixes
+	prefixes := []Prefix{
+		{ID: -1, Words: []string{"Quiz"}, Default: true},
+		{ID: -1, Words: []string{"Quiz", "Pset"}, PackID: 1},
+		{ID: -1, Words: []string{"Pset"}, PackID: 2},
+		{ID: 8, Words: []string{"Quiz"}},
+	}
+
+	prefix, found := MatchPrefix(prefixes, "Quiz on Friday", -1)
+	if !found || prefix.ID != 8 {
+		t.Errorf("expected the user's own prefix to win, got %v %+v", found, prefix)
+	}
+
+	prefix, found = MatchPrefix(prefixes, "Pset 4", -1)
+	if !found || prefix.PackID != 2 {
+		t.Errorf("expected the newer subscription to win, got %v %+v", found, prefix)
+	}
+}
+
+func TestClampPackPrefixPriorities(t *testing.T) {
+	packPrefixes := []Prefix{
+		{ID: -1, Words: []string{"Quiz"}, PackID: 1, Priority: 1000},
+		{ID: -1, Words: []string{"Pset"}, PackID: 1, Priority: -20},
+	}
+	userPrefixes := []Prefix{
+		{ID: 8, Words: []string{"Quiz"}, ClassIDs: []int{3}},
+		{ID: 9, Words: []string{"Reading"}, Priority: -5},
+	}
+
+	clampPackPrefixPriorities(packPrefixes, userPrefixes)
+	if packPrefixes[0].Priority != -5 || packPrefixes[1].Priority != -20 {
+		t.Errorf("expected pack priorities to be clamped to -5, got %d and %d", packPrefixes[0].Priority, packPrefixes[1].Priority)
+	}
+
+	// the user's class-scoped prefix now wins over the pack in that class
+	prefixes := append(append([]Prefix{}, packPrefixes...), userPrefixes...)
+	prefix, found := MatchPrefix(prefixes, "Quiz on Friday", 3)
+	if !found || prefix.ID != 8 {
+		t.Errorf("expected the user's own prefix to win, got %v %+v", found, prefix)
+	}
+}
@@ -27,6 +27,7 @@ type School interface {
 
 	Enroll(tx *sql.Tx, user *User, params map[string]interface{}) (map[string]interface{}, error)
 	Unenroll(tx *sql.Tx, user *User) error
+	Classes(tx *sql.Tx, user *User) ([]SchoolClass, error)
 	NeedsUpdate(db *sql.DB) (bool, error)
 }
 
@@ -54,6 +55,7 @@ type SchoolInfo struct {
 	EnrollmentID int    `json:"enrollmentID"`
 	SchoolID     string `json:"schoolID"`
 	Enabled      bool   `json:"enabled"`
+	SyncClasses  bool   `json:"syncClasses"`
 	DisplayName  string `json:"displayName"`
 	ShortName    string `json:"shortName"`
 	UserDetails  string `json:"userDetails"`
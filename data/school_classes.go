@@ -0,0 +1,70 @@
+package data
+
+import (
+	"database/sql"
+	"hash/fnv"
+)
+
+// A SchoolClass is a class that a user is taking, as given by the schedule of a School they're enrolled in.
+type SchoolClass struct {
+	// SourceID identifies the class within its School, and should stay the same when the user re-enrolls.
+	// It's the same as the SeriesID of the class's events from the School's CalendarProvider.
+	SourceID string
+	Name     string
+	Teacher  string
+}
+
+// schoolClassColors are the colors given to classes created from a school schedule. They're the same as the default classes.
+var schoolClassColors = []string{"ff4d40", "ffa540", "40ff73", "4071ff", "ff4086", "4d4d4d"}
+
+// getSchoolClassColor picks a color for a class created from a school schedule.
+// It's based on the class's source, so that the class ends up with the same color if it's ever created again.
+func getSchoolClassColor(schoolID string, sourceID string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(schoolID + ":" + sourceID))
+	return schoolClassColors[hash.Sum32()%uint32(len(schoolClassColors))]
+}
+
+// SyncSchoolClasses creates a HomeworkClass for each class in the user's schedule at the given School, and updates the ones that were created before.
+// A class's color is never changed, and classes that the user has moved to the trash are left alone.
+func SyncSchoolClasses(tx *sql.Tx, school School, user *User) error {
+	schoolClasses, err := school.Classes(tx, user)
+	if err != nil {
+		return err
+	}
+
+	for _, schoolClass := range schoolClasses {
+		rows, err := tx.Query("SELECT id, IF(trashId IS NULL, 0, 1) FROM classes WHERE userId = ? AND schoolId = ? AND schoolClassId = ?", user.ID, school.ID(), schoolClass.SourceID)
+		if err != nil {
+			return err
+		}
+
+		found := rows.Next()
+		classID := -1
+		trashed := 0
+		if found {
+			err = rows.Scan(&classID, &trashed)
+		}
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			_, err = tx.Exec(
+				"INSERT INTO classes(name, teacher, color, sortIndex, termId, schoolId, schoolClassId, userId) VALUES(?, ?, ?, (SELECT * FROM (SELECT COUNT(*) FROM classes WHERE userId = ? AND trashId IS NULL) AS sortIndex), (SELECT id FROM terms WHERE userId = ? ORDER BY startedAt DESC, id DESC LIMIT 1), ?, ?, ?)",
+				schoolClass.Name, schoolClass.Teacher, getSchoolClassColor(school.ID(), schoolClass.SourceID), user.ID, user.ID, school.ID(), schoolClass.SourceID, user.ID,
+			)
+			if err != nil {
+				return err
+			}
+		} else if trashed == 0 {
+			_, err = tx.Exec("UPDATE classes SET name = ?, teacher = ? WHERE id = ?", schoolClass.Name, schoolClass.Teacher, classID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package data
+
+import "errors"
+
+// A Scope is a permission that a user can give to an application, like being able to see their homework.
+type Scope string
+
+// The Scopes that applications can ask for.
+const (
+	ScopeHomeworkRead  Scope = "homework:read"
+	ScopeHomeworkWrite Scope = "homework:write"
+	ScopeCalendarRead  Scope = "calendar:read"
+	ScopeCalendarWrite Scope = "calendar:write"
+	ScopeClassesRead   Scope = "classes:read"
+	ScopeClassesWrite  Scope = "classes:write"
+	ScopeGradesRead    Scope = "grades:read"
+	ScopeGradesWrite   Scope = "grades:write"
+	ScopePrefs         Scope = "prefs"
+)
+
+// AllScopes is every Scope, in the order they should be shown to users.
+var AllScopes = []Scope{
+	ScopeHomeworkRead,
+	ScopeHomeworkWrite,
+	ScopeCalendarRead,
+	ScopeCalendarWrite,
+	ScopeClassesRead,
+	ScopeClassesWrite,
+	ScopeGradesRead,
+	ScopeGradesWrite,
+	ScopePrefs,
+}
+
+// ErrInvalidScope is returned when parsing a scope that doesn't exist.
+var ErrInvalidScope = errors.New("data: invalid scope")
+
+// ParseScopes reads a space-separated list of scopes, as used by OAuth and stored in the database.
+// Scopes that don't exist cause ErrInvalidScope, but the ones that do exist are still returned.
+func ParseScopes(text string) ([]Scope, error) {
+	values, valid := parseList(text, scopeStrings(AllScopes))
+
+	scopes := []Scope{}
+	for _, value := range values {
+		scopes = append(scopes, Scope(value))
+	}
+
+	if !valid {
+		return scopes, ErrInvalidScope
+	}
+	return scopes, nil
+}
+
+// FormatScopes turns the given scopes into a space-separated list, in the same order as AllScopes.
+func FormatScopes(scopes []Scope) string {
+	return formatList(scopeStrings(scopes), scopeStrings(AllScopes))
+}
+
+func scopeStrings(scopes []Scope) []string {
+	values := []string{}
+	for _, scope := range scopes {
+		values = append(values, string(scope))
+	}
+	return values
+}
+
+// ScopesContain returns whether the given scope is in the list.
+func ScopesContain(scopes []Scope, scope Scope) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopesContainAll returns whether every scope in want is in the list.
+func ScopesContainAll(scopes []Scope, want []Scope) bool {
+	for _, scope := range want {
+		if !ScopesContain(scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// MergeScopes returns every scope that's in either list.
+func MergeScopes(a []Scope, b []Scope) []Scope {
+	merged := append([]Scope{}, a...)
+	for _, scope := range b {
+		if !ScopesContain(merged, scope) {
+			merged = append(merged, scope)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,33 @@
+package data
+
+import "testing"
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes("  homework:read prefs homework:read calendar:read ")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if FormatScopes(scopes) != "homework:read calendar:read prefs" {
+		t.Errorf("expected scopes to be deduplicated and sorted, got %q", FormatScopes(scopes))
+	}
+
+	scopes, err = ParseScopes("homework:read admin")
+	if err != ErrInvalidScope {
+		t.Errorf("expected ErrInvalidScope, got %v", err)
+	}
+	if len(scopes) != 1 || scopes[0] != ScopeHomeworkRead {
+		t.Errorf("expected the valid scope to be kept, got %v", scopes)
+	}
+
+	scopes, err = ParseScopes("")
+	if err != nil || len(scopes) != 0 {
+		t.Errorf("expected no scopes, got %v (error %v)", scopes, err)
+	}
+}
+
+func TestMergeScopes(t *testing.T) {
+	merged := MergeScopes([]Scope{ScopeHomeworkRead, ScopePrefs}, []Scope{ScopePrefs, ScopeClassesWrite})
+	if !ScopesContainAll(merged, []Scope{ScopeHomeworkRead, ScopePrefs, ScopeClassesWrite}) || len(merged) != 3 {
+		t.Errorf("unexpected merged scopes %v", merged)
+	}
+}
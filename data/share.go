@@ -0,0 +1,315 @@
+package data
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// A ShareType describes what was shared.
+type ShareType int
+
+// The available share types.
+const (
+	ShareTypeHomework ShareType = iota
+	ShareTypeClass
+)
+
+// A Share gives another user a linked copy of a homework item, or of everything in a class.
+// The recipient is identified by email, so that people can be invited before they have an account.
+// Copies made for a share keep their own complete state, but follow the due date and description of the original.
+type Share struct {
+	ID               int       `json:"id"`
+	Type             ShareType `json:"type"`
+	ItemID           int       `json:"itemId"`
+	Name             string    `json:"name"`
+	OwnerID          int       `json:"ownerId"`
+	OwnerName        string    `json:"ownerName"`
+	Email            string    `json:"email"`
+	RecipientID      int       `json:"recipientId"`
+	RecipientClassID int       `json:"recipientClassId"`
+	Accepted         bool      `json:"accepted"`
+	CreatedAt        int       `json:"createdAt"`
+}
+
+const shareSelect = "SELECT shares.id, shares.type, shares.itemId, IFNULL(IF(shares.type = 0, homework.name, classes.name), ''), shares.ownerId, users.name, shares.email, IFNULL(shares.recipientId, -1), IFNULL(shares.recipientClassId, -1), shares.accepted, shares.createdAt FROM shares " +
+	"LEFT JOIN homework ON shares.type = 0 AND shares.itemId = homework.id " +
+	"LEFT JOIN classes ON shares.type = 1 AND shares.itemId = classes.id " +
+	"INNER JOIN users ON shares.ownerId = users.id "
+
+func scanShares(rows *sql.Rows) ([]Share, error) {
+	shares := []Share{}
+	for rows.Next() {
+		share := Share{}
+		acceptedInt := -1
+		err := rows.Scan(&share.ID, &share.Type, &share.ItemID, &share.Name, &share.OwnerID, &share.OwnerName, &share.Email, &share.RecipientID, &share.RecipientClassID, &acceptedInt, &share.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		share.Accepted = (acceptedInt == 1)
+		shares = append(shares, share)
+	}
+	return shares, nil
+}
+
+// GetShareByID fetches the Share with the given ID.
+func GetShareByID(id int) (Share, error) {
+	rows, err := DB.Query(shareSelect+"WHERE shares.id = ?", id)
+	if err != nil {
+		return Share{}, err
+	}
+	defer rows.Close()
+
+	shares, err := scanShares(rows)
+	if err != nil {
+		return Share{}, err
+	}
+
+	if len(shares) == 0 {
+		return Share{}, ErrNotFound
+	}
+
+	return shares[0], nil
+}
+
+// GetSharesByOwner returns all Shares that the given user has created.
+func GetSharesByOwner(userID int) ([]Share, error) {
+	rows, err := DB.Query(shareSelect+"WHERE shares.ownerId = ? ORDER BY shares.createdAt DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanShares(rows)
+}
+
+// GetSharesForRecipient returns all Shares that have been sent to the given user.
+// Shares sent to the user's email address before they had an account are only included once that address is verified.
+func GetSharesForRecipient(user *User) ([]Share, error) {
+	rows, err := DB.Query(
+		shareSelect+"WHERE shares.recipientId = ? OR (shares.recipientId IS NULL AND shares.email = ? AND ? = 1) ORDER BY shares.createdAt DESC",
+		user.ID, user.Email, user.EmailVerified,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanShares(rows)
+}
+
+// IsRecipient checks if the given user is who the share was sent to.
+func (share Share) IsRecipient(user *User) bool {
+	if share.RecipientID != -1 {
+		return share.RecipientID == user.ID
+	}
+
+	return user.EmailVerified && strings.EqualFold(share.Email, user.Email)
+}
+
+// CreateShare records a new, unaccepted Share, and returns its ID.
+// If the email address belongs to an existing user, recipientID should be their ID. Otherwise, it should be -1.
+func CreateShare(tx *sql.Tx, shareType ShareType, itemID int, ownerID int, email string, recipientID int) (int, error) {
+	var recipient *int
+	if recipientID != -1 {
+		recipient = &recipientID
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO shares(type, itemId, ownerId, email, recipientId, accepted, createdAt) VALUES(?, ?, ?, ?, ?, 0, ?)",
+		shareType, itemID, ownerID, email, recipient, time.Now().Unix(),
+	)
+	if err != nil {
+		return -1, err
+	}
+
+	shareID, err := result.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+
+	return int(shareID), nil
+}
+
+// copySharedHomework gives a copy of the given homework to the recipient of a share, and returns the copy.
+func copySharedHomework(tx *sql.Tx, shareID int, recipientID int, recipientClassID int, homework Homework) (Homework, error) {
+	result, err := tx.Exec(
+		"INSERT INTO homework(name, `due`, `desc`, `complete`, classId, userId, sourceId, shareId) VALUES(?, ?, ?, 0, ?, ?, ?, ?)",
+		homework.Name, homework.Due, homework.Desc, recipientClassID, recipientID, homework.ID, shareID,
+	)
+	if err != nil {
+		return Homework{}, err
+	}
+
+	copyID, err := result.LastInsertId()
+	if err != nil {
+		return Homework{}, err
+	}
+
+	return Homework{
+		ID:      int(copyID),
+		Name:    homework.Name,
+		Due:     homework.Due,
+		Desc:    homework.Desc,
+		ClassID: recipientClassID,
+		UserID:  recipientID,
+	}, nil
+}
+
+// AcceptShare marks the given Share as accepted by the given user, and copies the shared homework into the given class.
+// For a class, only homework that isn't due yet is copied over. It returns the copies that were made.
+func AcceptShare(tx *sql.Tx, share Share, recipientID int, recipientClassID int) ([]Homework, error) {
+	_, err := tx.Exec("UPDATE shares SET recipientId = ?, recipientClassId = ?, accepted = 1 WHERE id = ?", recipientID, recipientClassID, share.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows *sql.Rows
+	if share.Type == ShareTypeHomework {
+		rows, err = tx.Query("SELECT id, name, `due`, `desc` FROM homework WHERE id = ? AND trashId IS NULL", share.ItemID)
+	} else {
+		rows, err = tx.Query("SELECT id, name, `due`, `desc` FROM homework WHERE classId = ? AND trashId IS NULL AND `due` >= ?", share.ItemID, time.Now().Format("2006-01-02"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	homeworkToCopy := []Homework{}
+	for rows.Next() {
+		homework := Homework{}
+		err = rows.Scan(&homework.ID, &homework.Name, &homework.Due, &homework.Desc)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		homeworkToCopy = append(homeworkToCopy, homework)
+	}
+	rows.Close()
+
+	copies := []Homework{}
+	for _, homework := range homeworkToCopy {
+		homeworkCopy, err := copySharedHomework(tx, share.ID, recipientID, recipientClassID, homework)
+		if err != nil {
+			return nil, err
+		}
+		copies = append(copies, homeworkCopy)
+	}
+
+	return copies, nil
+}
+
+// ShareNewHomework gives copies of a newly added homework item to everyone its class has been shared with, and returns the copies that were made.
+func ShareNewHomework(tx *sql.Tx, homework Homework) ([]Homework, error) {
+	// skip any recipients who have since deleted the class they were putting things in, or who already have a copy from somewhere else
+	rows, err := tx.Query(
+		"SELECT shares.id, shares.recipientId, shares.recipientClassId FROM shares INNER JOIN classes ON shares.recipientClassId = classes.id WHERE shares.type = ? AND shares.itemId = ? AND shares.accepted = 1 AND classes.trashId IS NULL "+
+			"AND NOT EXISTS(SELECT copies.id FROM homework AS copies WHERE copies.sourceId = ? AND copies.userId = shares.recipientId)",
+		ShareTypeClass, homework.ClassID, homework.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	shares := []Share{}
+	for rows.Next() {
+		share := Share{}
+		err = rows.Scan(&share.ID, &share.RecipientID, &share.RecipientClassID)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	rows.Close()
+
+	copies := []Homework{}
+	for _, share := range shares {
+		homeworkCopy, err := copySharedHomework(tx, share.ID, share.RecipientID, share.RecipientClassID, homework)
+		if err != nil {
+			return nil, err
+		}
+		copies = append(copies, homeworkCopy)
+	}
+
+	return copies, nil
+}
+
+// ShareMovedHomework gives copies of a homework item that was just moved into another class to everyone that class has been shared with.
+// Copies that were shared with the user aren't passed along any further. It returns the copies that were made.
+func ShareMovedHomework(tx *sql.Tx, homeworkID int) ([]Homework, error) {
+	rows, err := tx.Query("SELECT id, name, `due`, `desc`, classId FROM homework WHERE id = ? AND trashId IS NULL AND sourceId IS NULL", homeworkID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !rows.Next() {
+		rows.Close()
+		return []Homework{}, nil
+	}
+
+	homework := Homework{}
+	err = rows.Scan(&homework.ID, &homework.Name, &homework.Due, &homework.Desc, &homework.ClassID)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return ShareNewHomework(tx, homework)
+}
+
+// SyncSharedHomework updates all copies of the given homework item to match its due date and description, and returns the copies.
+func SyncSharedHomework(tx *sql.Tx, homeworkID int) ([]Homework, error) {
+	_, err := tx.Exec(
+		"UPDATE homework AS copies INNER JOIN homework AS source ON copies.sourceId = source.id SET copies.`due` = source.`due`, copies.`desc` = source.`desc` WHERE source.id = ?",
+		homeworkID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query("SELECT id, userId FROM homework WHERE sourceId = ? AND trashId IS NULL", homeworkID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	copies := []Homework{}
+	for rows.Next() {
+		homeworkCopy := Homework{}
+		err = rows.Scan(&homeworkCopy.ID, &homeworkCopy.UserID)
+		if err != nil {
+			return nil, err
+		}
+		copies = append(copies, homeworkCopy)
+	}
+
+	return copies, nil
+}
+
+// DeleteShare removes the given Share. Any copies that were made for it are kept, but no longer follow the original.
+func DeleteShare(tx *sql.Tx, shareID int) error {
+	_, err := tx.Exec("UPDATE homework SET sourceId = NULL, shareId = NULL WHERE shareId = ?", shareID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM shares WHERE id = ?", shareID)
+	return err
+}
+
+// deleteSharesForTrash cleans up any Shares that involve items about to be purged from the trash.
+func deleteSharesForTrash(tx *sql.Tx, trashID int) error {
+	// copies of purged homework stay around, but they no longer have anything to follow
+	_, err := tx.Exec("UPDATE homework AS copies INNER JOIN homework AS source ON copies.sourceId = source.id SET copies.sourceId = NULL, copies.shareId = NULL WHERE source.trashId = ?", trashID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE shares FROM shares INNER JOIN homework ON shares.type = ? AND shares.itemId = homework.id WHERE homework.trashId = ?", ShareTypeHomework, trashID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE shares FROM shares INNER JOIN classes ON (shares.type = ? AND shares.itemId = classes.id) OR shares.recipientClassId = classes.id WHERE classes.trashId = ?", ShareTypeClass, trashID)
+	return err
+}
@@ -0,0 +1,127 @@
+package data
+
+import (
+	"database/sql"
+	"time"
+)
+
+// A StudySession is a block of time spent working on a Homework item.
+// A session is running while its timer is going, paused when it has stopped counting but can be resumed, and stopped once End is set.
+type StudySession struct {
+	ID           int `json:"id"`
+	HomeworkID   int `json:"homeworkId"`
+	Start        int `json:"start"`
+	End          int `json:"end"`          // -1 if the session hasn't been stopped
+	Duration     int `json:"duration"`     // seconds, not counting the part since RunningSince
+	RunningSince int `json:"runningSince"` // -1 if the timer isn't running
+	HWEventID    int `json:"hwEventId"`    // the calendar_hwevents block this was logged from, or -1
+	UserID       int `json:"userId"`
+}
+
+// Running checks if the session's timer is currently going.
+func (s StudySession) Running() bool {
+	return s.RunningSince != -1
+}
+
+// Stopped checks if the session has been finished.
+func (s StudySession) Stopped() bool {
+	return s.End != -1
+}
+
+// TotalDuration returns the number of seconds spent in the session as of the given time, including any part that is still running.
+func (s StudySession) TotalDuration(now time.Time) int {
+	if s.Running() && int(now.Unix()) > s.RunningSince {
+		return s.Duration + int(now.Unix()) - s.RunningSince
+	}
+	return s.Duration
+}
+
+const studySessionSelect = "SELECT id, homeworkId, `start`, IFNULL(`end`, -1), duration, IFNULL(runningSince, -1), IFNULL(hwEventId, -1), userId FROM study_sessions "
+
+func scanStudySessions(rows *sql.Rows) ([]StudySession, error) {
+	sessions := []StudySession{}
+	for rows.Next() {
+		session := StudySession{}
+		err := rows.Scan(&session.ID, &session.HomeworkID, &session.Start, &session.End, &session.Duration, &session.RunningSince, &session.HWEventID, &session.UserID)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// GetStudySessionByID fetches the StudySession with the given ID, if it belongs to the given user.
+func GetStudySessionByID(id int, userID int) (StudySession, error) {
+	rows, err := DB.Query(studySessionSelect+"WHERE id = ? AND userId = ?", id, userID)
+	if err != nil {
+		return StudySession{}, err
+	}
+	defer rows.Close()
+
+	sessions, err := scanStudySessions(rows)
+	if err != nil {
+		return StudySession{}, err
+	}
+
+	if len(sessions) == 0 {
+		return StudySession{}, ErrNotFound
+	}
+
+	return sessions[0], nil
+}
+
+// GetStudySessionsForHomework returns all StudySessions for the given homework item, oldest first.
+func GetStudySessionsForHomework(homeworkID int) ([]StudySession, error) {
+	rows, err := DB.Query(studySessionSelect+"WHERE homeworkId = ? ORDER BY `start` ASC, id ASC", homeworkID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanStudySessions(rows)
+}
+
+// GetActiveStudySessionsForUser returns the given user's StudySessions that haven't been stopped yet.
+func GetActiveStudySessionsForUser(userID int) ([]StudySession, error) {
+	rows, err := DB.Query(studySessionSelect+"WHERE userId = ? AND `end` IS NULL ORDER BY `start` ASC, id ASC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanStudySessions(rows)
+}
+
+// PauseStudySession stops counting time for the given session, adding what was counted so far to its duration.
+func PauseStudySession(tx *sql.Tx, session StudySession, now time.Time) error {
+	_, err := tx.Exec("UPDATE study_sessions SET duration = ?, runningSince = NULL WHERE id = ?", session.TotalDuration(now), session.ID)
+	return err
+}
+
+// StopStudySession finishes the given session, adding any time that was still running to its duration.
+func StopStudySession(tx *sql.Tx, session StudySession, now time.Time) error {
+	_, err := tx.Exec("UPDATE study_sessions SET duration = ?, runningSince = NULL, `end` = ? WHERE id = ?", session.TotalDuration(now), now.Unix(), session.ID)
+	return err
+}
+
+// GetHomeworkEstimate returns how many minutes the given homework item is expected to take, or -1 if no estimate was set.
+func GetHomeworkEstimate(homeworkID int) (int, error) {
+	rows, err := DB.Query("SELECT minutes FROM homework_estimates WHERE homeworkId = ?", homeworkID)
+	if err != nil {
+		return -1, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return -1, nil
+	}
+
+	minutes := -1
+	err = rows.Scan(&minutes)
+	if err != nil {
+		return -1, err
+	}
+
+	return minutes, nil
+}
@@ -0,0 +1,97 @@
+package data
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Term is a period of time, like a semester, that a user's classes belong to.
+// The term that was started most recently is the user's current term, and new classes are added to it.
+type Term struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	StartedAt int    `json:"startedAt"`
+	UserID    int    `json:"userId"`
+}
+
+// GetTermsForUser returns all Terms for the given user, starting with the current one.
+func GetTermsForUser(userID int) ([]Term, error) {
+	rows, err := DB.Query("SELECT id, name, startedAt, userId FROM terms WHERE userId = ? ORDER BY startedAt DESC, id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	terms := []Term{}
+	for rows.Next() {
+		term := Term{}
+		err = rows.Scan(&term.ID, &term.Name, &term.StartedAt, &term.UserID)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+
+	return terms, nil
+}
+
+// GetCurrentTermForUser returns the given user's current Term, or ErrNotFound if they've never started one.
+func GetCurrentTermForUser(userID int) (Term, error) {
+	terms, err := GetTermsForUser(userID)
+	if err != nil {
+		return Term{}, err
+	}
+
+	if len(terms) == 0 {
+		return Term{}, ErrNotFound
+	}
+
+	return terms[0], nil
+}
+
+// StartTerm creates a new Term for the given user and makes it their current one.
+// All of the user's active classes are archived, except for the ones in keepClassIDs, which are moved into the new term.
+func StartTerm(tx *sql.Tx, name string, userID int, keepClassIDs []int) (Term, error) {
+	term := Term{
+		Name:      name,
+		StartedAt: int(time.Now().Unix()),
+		UserID:    userID,
+	}
+
+	result, err := tx.Exec("INSERT INTO terms(name, startedAt, userId) VALUES(?, ?, ?)", term.Name, term.StartedAt, term.UserID)
+	if err != nil {
+		return Term{}, err
+	}
+
+	termID, err := result.LastInsertId()
+	if err != nil {
+		return Term{}, err
+	}
+	term.ID = int(termID)
+
+	keepIDStrings := []string{}
+	for _, id := range keepClassIDs {
+		keepIDStrings = append(keepIDStrings, strconv.Itoa(id))
+	}
+	keepIDList := strings.Join(keepIDStrings, ",")
+
+	_, err = tx.Exec(
+		"UPDATE classes SET archived = 1 WHERE userId = ? AND trashId IS NULL AND archived = 0 AND FIND_IN_SET(id, ?) = 0",
+		userID, keepIDList,
+	)
+	if err != nil {
+		return Term{}, err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE classes SET termId = ? WHERE userId = ? AND trashId IS NULL AND archived = 0 AND FIND_IN_SET(id, ?) > 0",
+		term.ID, userID, keepIDList,
+	)
+	if err != nil {
+		return Term{}, err
+	}
+
+	return term, nil
+}
@@ -0,0 +1,165 @@
+package data
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TOTPRecoveryCodeCount is how many recovery codes a user gets at a time.
+const TOTPRecoveryCodeCount = 10
+
+// how many characters are in a recovery code, not counting the dash in the middle
+const totpRecoveryCodeLength = 10
+
+// letters and numbers that are hard to mix up when reading them off a piece of paper
+const totpRecoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// NormalizeTOTPRecoveryCode strips out the formatting of a recovery code, so that it can be typed in however the user likes.
+func NormalizeTOTPRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.Replace(code, "-", "", -1)
+	code = strings.Replace(code, " ", "", -1)
+	return code
+}
+
+// IsTOTPRecoveryCode returns whether the given code looks like a recovery code, which is checked before going through the user's codes.
+func IsTOTPRecoveryCode(code string) bool {
+	code = NormalizeTOTPRecoveryCode(code)
+	if len(code) != totpRecoveryCodeLength {
+		return false
+	}
+
+	for _, character := range code {
+		if !strings.ContainsRune(totpRecoveryCodeAlphabet, character) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// recovery codes are short enough to guess from a leaked hash, so they're hashed like passwords are
+func hashTOTPRecoveryCode(code string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(NormalizeTOTPRecoveryCode(code)), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func checkTOTPRecoveryCode(codeHash string, code string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(codeHash), []byte(NormalizeTOTPRecoveryCode(code))) == nil
+}
+
+func generateTOTPRecoveryCode() (string, error) {
+	code := ""
+	for i := 0; i < totpRecoveryCodeLength; i++ {
+		if i == totpRecoveryCodeLength/2 {
+			code += "-"
+		}
+
+		index, err := rand.Int(rand.Reader, big.NewInt(int64(len(totpRecoveryCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+
+		code += string(totpRecoveryCodeAlphabet[index.Int64()])
+	}
+	return code, nil
+}
+
+// ReplaceTOTPRecoveryCodes throws out the given user's recovery codes and creates new ones.
+// It returns the new codes, which should be shown to the user, since only their hashes are kept.
+func ReplaceTOTPRecoveryCodes(tx *sql.Tx, userID int, createdAt int) ([]string, error) {
+	_, err := tx.Exec("DELETE FROM totp_recovery_codes WHERE userId = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := []string{}
+	for i := 0; i < TOTPRecoveryCodeCount; i++ {
+		code, err := generateTOTPRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		codeHash, err := hashTOTPRecoveryCode(code)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec("INSERT INTO totp_recovery_codes(codeHash, createdAt, userId) VALUES(?, ?, ?)", codeHash, createdAt, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// GetUnusedTOTPRecoveryCodeCount returns how many recovery codes the given user has left.
+func GetUnusedTOTPRecoveryCodeCount(userID int) (int, error) {
+	rows, err := DB.Query("SELECT COUNT(*) FROM totp_recovery_codes WHERE userId = ? AND usedAt IS NULL", userID)
+	if err != nil {
+		return -1, err
+	}
+	defer rows.Close()
+
+	count := -1
+	rows.Next()
+	err = rows.Scan(&count)
+	if err != nil {
+		return -1, err
+	}
+
+	return count, nil
+}
+
+// UseTOTPRecoveryCode checks if the given code is one of the user's unused recovery codes, and if so, marks it as used.
+func UseTOTPRecoveryCode(userID int, code string, usedAt int) (bool, error) {
+	// the hashes are salted, so each one has to be checked
+	rows, err := DB.Query("SELECT id, codeHash FROM totp_recovery_codes WHERE userId = ? AND usedAt IS NULL", userID)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	matchedID := -1
+	for rows.Next() {
+		id := -1
+		codeHash := ""
+		err = rows.Scan(&id, &codeHash)
+		if err != nil {
+			return false, err
+		}
+
+		if checkTOTPRecoveryCode(codeHash, code) {
+			matchedID = id
+			break
+		}
+	}
+	rows.Close()
+
+	if matchedID == -1 {
+		return false, nil
+	}
+
+	// the code could have been used by another request since it was checked
+	result, err := DB.Exec("UPDATE totp_recovery_codes SET usedAt = ? WHERE id = ? AND usedAt IS NULL", usedAt, matchedID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return (rowsAffected > 0), nil
+}
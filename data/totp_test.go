@@ -0,0 +1,40 @@
+package data
+
+import "testing"
+
+func TestCheckTOTPRecoveryCode(t *testing.T) {
+	codeHash, err := hashTOTPRecoveryCode("abcde-fghjk")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !checkTOTPRecoveryCode(codeHash, "ABCDE FGHJK") {
+		t.Errorf("expected the code to match however it was typed")
+	}
+	if checkTOTPRecoveryCode(codeHash, "abcde-fghjm") {
+		t.Errorf("expected a different code to not match")
+	}
+
+	otherHash, err := hashTOTPRecoveryCode("abcde-fghjk")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if otherHash == codeHash {
+		t.Errorf("expected the same code to be hashed differently each time")
+	}
+}
+
+func TestIsTOTPRecoveryCode(t *testing.T) {
+	for _, code := range []string{"abcde-fghjk", "ABCDE FGHJK", "abcdefghjk"} {
+		if !IsTOTPRecoveryCode(code) {
+			t.Errorf("expected %q to be a recovery code", code)
+		}
+	}
+
+	// a TOTP code, one that's too short, and one with letters that are never used
+	for _, code := range []string{"123456", "abcde-fghj", "abcde-fghji"} {
+		if IsTOTPRecoveryCode(code) {
+			t.Errorf("expected %q to not be a recovery code", code)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package data
+
+import (
+	"database/sql"
+	"time"
+)
+
+// A TrashItemType describes what kind of item was moved to the trash.
+type TrashItemType int
+
+// The available trash item types.
+const (
+	TrashItemHomework TrashItemType = iota
+	TrashItemClass
+	TrashItemEvent
+)
+
+// TrashRetention is how long an item stays in the trash before it's permanently deleted.
+const TrashRetention = 30 * 24 * time.Hour
+
+// A TrashItem is something that a user deleted, which can still be restored until it's purged.
+// Any items that were deleted along with it (for example, a class's homework) share its ID.
+type TrashItem struct {
+	ID        int           `json:"id"`
+	Type      TrashItemType `json:"type"`
+	ItemID    int           `json:"itemId"`
+	Name      string        `json:"name"`
+	DeletedAt int           `json:"deletedAt"`
+	ExpiresAt int           `json:"expiresAt"`
+	UserID    int           `json:"userId"`
+}
+
+func scanTrashItems(rows *sql.Rows) ([]TrashItem, error) {
+	items := []TrashItem{}
+	for rows.Next() {
+		item := TrashItem{}
+		err := rows.Scan(&item.ID, &item.Type, &item.ItemID, &item.Name, &item.DeletedAt, &item.UserID)
+		if err != nil {
+			return nil, err
+		}
+		item.ExpiresAt = item.DeletedAt + int(TrashRetention.Seconds())
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// AddToTrash records that the given item was deleted, and returns the ID of the new TrashItem.
+// The caller is responsible for setting the trashId of the item and anything deleted along with it.
+func AddToTrash(tx *sql.Tx, itemType TrashItemType, itemID int, name string, userID int) (int, error) {
+	result, err := tx.Exec(
+		"INSERT INTO trash(type, itemId, name, deletedAt, userId) VALUES(?, ?, ?, ?, ?)",
+		itemType, itemID, name, time.Now().Unix(), userID,
+	)
+	if err != nil {
+		return -1, err
+	}
+
+	trashID, err := result.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+
+	return int(trashID), nil
+}
+
+// TrashHomework moves the given homework item, along with its calendar events, to the trash.
+func TrashHomework(tx *sql.Tx, id int, name string, userID int) (int, error) {
+	trashID, err := AddToTrash(tx, TrashItemHomework, id, name, userID)
+	if err != nil {
+		return -1, err
+	}
+
+	_, err = tx.Exec("UPDATE homework SET trashId = ? WHERE id = ?", trashID, id)
+	if err != nil {
+		return -1, err
+	}
+
+	_, err = tx.Exec("UPDATE calendar_hwevents SET trashId = ? WHERE homeworkId = ? AND trashId IS NULL", trashID, id)
+	if err != nil {
+		return -1, err
+	}
+
+	return trashID, nil
+}
+
+// GetTrashForUser returns all TrashItems belonging to the given user, most recently deleted first.
+func GetTrashForUser(userID int) ([]TrashItem, error) {
+	rows, err := DB.Query("SELECT id, type, itemId, name, deletedAt, userId FROM trash WHERE userId = ? ORDER BY deletedAt DESC, id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTrashItems(rows)
+}
+
+// GetTrashItemForUser fetches the TrashItem with the given ID, if it belongs to the given user.
+func GetTrashItemForUser(id int, userID int) (TrashItem, error) {
+	rows, err := DB.Query("SELECT id, type, itemId, name, deletedAt, userId FROM trash WHERE id = ? AND userId = ?", id, userID)
+	if err != nil {
+		return TrashItem{}, err
+	}
+	defer rows.Close()
+
+	items, err := scanTrashItems(rows)
+	if err != nil {
+		return TrashItem{}, err
+	}
+
+	if len(items) == 0 {
+		return TrashItem{}, ErrNotFound
+	}
+
+	return items[0], nil
+}
+
+// GetExpiredTrash returns all TrashItems, across all users, that were deleted before the given time.
+func GetExpiredTrash(db *sql.DB, before time.Time) ([]TrashItem, error) {
+	rows, err := db.Query("SELECT id, type, itemId, name, deletedAt, userId FROM trash WHERE deletedAt < ?", before.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTrashItems(rows)
+}
+
+// RestoredTrash is what RestoreTrashItem brought back, so that webhooks can be told about it.
+type RestoredTrash struct {
+	HomeworkIDs []int
+	ClassIDs    []int
+	Events      []Event // only the ID and Name are filled in
+}
+
+func getTrashedIDs(tx *sql.Tx, table string, trashID int) ([]int, error) {
+	rows, err := tx.Query("SELECT id FROM "+table+" WHERE trashId = ?", trashID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		id := -1
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// RestoreTrashItem brings back the given TrashItem, along with everything that was deleted with it.
+func RestoreTrashItem(tx *sql.Tx, item TrashItem) (RestoredTrash, error) {
+	restored := RestoredTrash{}
+	var err error
+
+	// find everything before it's restored, since the trashId is how it's found
+	restored.HomeworkIDs, err = getTrashedIDs(tx, "homework", item.ID)
+	if err != nil {
+		return RestoredTrash{}, err
+	}
+
+	restored.ClassIDs, err = getTrashedIDs(tx, "classes", item.ID)
+	if err != nil {
+		return RestoredTrash{}, err
+	}
+
+	eventRows, err := tx.Query("SELECT id, name FROM calendar_events WHERE trashId = ?", item.ID)
+	if err != nil {
+		return RestoredTrash{}, err
+	}
+	for eventRows.Next() {
+		event := Event{}
+		err = eventRows.Scan(&event.ID, &event.Name)
+		if err != nil {
+			eventRows.Close()
+			return RestoredTrash{}, err
+		}
+		restored.Events = append(restored.Events, event)
+	}
+	eventRows.Close()
+
+	_, err = tx.Exec("UPDATE calendar_hwevents SET trashId = NULL WHERE trashId = ?", item.ID)
+	if err != nil {
+		return RestoredTrash{}, err
+	}
+
+	_, err = tx.Exec("UPDATE homework SET trashId = NULL WHERE trashId = ?", item.ID)
+	if err != nil {
+		return RestoredTrash{}, err
+	}
+
+	if item.Type == TrashItemClass {
+		// put the class back at the end of the list
+		_, err = tx.Exec(
+			"UPDATE classes SET sortIndex = (SELECT * FROM (SELECT COUNT(*) FROM classes WHERE userId = ? AND trashId IS NULL) AS sortIndex), trashId = NULL WHERE trashId = ?",
+			item.UserID, item.ID,
+		)
+		if err != nil {
+			return RestoredTrash{}, err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE calendar_events SET trashId = NULL WHERE trashId = ?", item.ID)
+	if err != nil {
+		return RestoredTrash{}, err
+	}
+
+	_, err = tx.Exec("DELETE FROM trash WHERE id = ?", item.ID)
+	if err != nil {
+		return RestoredTrash{}, err
+	}
+
+	return restored, nil
+}
+
+// PurgeTrashItem permanently deletes the given TrashItem, along with everything that was deleted with it.
+func PurgeTrashItem(tx *sql.Tx, item TrashItem) error {
+	err := deleteSharesForTrash(tx, item.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM calendar_hwevents WHERE trashId = ?", item.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE homework_events FROM homework_events INNER JOIN homework ON homework_events.homeworkId = homework.id WHERE homework.trashId = ?", item.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE study_sessions FROM study_sessions INNER JOIN homework ON study_sessions.homeworkId = homework.id WHERE homework.trashId = ?", item.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE homework_estimates FROM homework_estimates INNER JOIN homework ON homework_estimates.homeworkId = homework.id WHERE homework.trashId = ?", item.ID)
+	if err != nil {
+		return err
+	}
+
+	// grades are kept, they just aren't attached to the homework anymore
+	_, err = tx.Exec("UPDATE grade_items INNER JOIN homework ON grade_items.homeworkId = homework.id SET grade_items.homeworkId = NULL WHERE homework.trashId = ?", item.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM homework WHERE trashId = ?", item.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE grade_items FROM grade_items INNER JOIN classes ON grade_items.classId = classes.id WHERE classes.trashId = ?", item.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE grade_categories FROM grade_categories INNER JOIN classes ON grade_categories.classId = classes.id WHERE classes.trashId = ?", item.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE class_contacts FROM class_contacts INNER JOIN classes ON class_contacts.classId = classes.id WHERE classes.trashId = ?", item.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE class_office_hours FROM class_office_hours INNER JOIN classes ON class_office_hours.classId = classes.id WHERE classes.trashId = ?", item.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM classes WHERE trashId = ?", item.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE calendar_event_rules FROM calendar_event_rules INNER JOIN calendar_events ON calendar_event_rules.eventId = calendar_events.id WHERE calendar_events.trashId = ?", item.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM calendar_events WHERE trashId = ?", item.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM trash WHERE id = ?", item.ID)
+	return err
+}
@@ -0,0 +1,142 @@
+package data
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/duo-labs/webauthn/webauthn"
+)
+
+// MaxWebAuthnCredentialNameLength is the longest name that a WebAuthnCredential can have.
+const MaxWebAuthnCredentialNameLength = 100
+
+// A WebAuthnCredential is a security key or passkey that a user has registered with their account.
+type WebAuthnCredential struct {
+	ID         int                 `json:"id"`
+	Name       string              `json:"name"`
+	CreatedAt  int                 `json:"createdAt"`
+	LastUsedAt int                 `json:"lastUsedAt"` // -1 if the key was never used to log in
+	UserID     int                 `json:"userId"`
+	Credential webauthn.Credential `json:"-"`
+}
+
+// A WebAuthnUser wraps a User and their credentials, so that they can be passed to the WebAuthn library.
+type WebAuthnUser struct {
+	User        *User
+	Credentials []WebAuthnCredential
+}
+
+// WebAuthnID returns the user handle that authenticators store for the user.
+func (u WebAuthnUser) WebAuthnID() []byte {
+	return []byte(strconv.Itoa(u.User.ID))
+}
+
+// WebAuthnName returns the name that authenticators show to tell accounts apart.
+func (u WebAuthnUser) WebAuthnName() string {
+	return u.User.Email
+}
+
+// WebAuthnDisplayName returns the user's name.
+func (u WebAuthnUser) WebAuthnDisplayName() string {
+	return u.User.Name
+}
+
+// WebAuthnIcon returns an icon for the user, which we don't have.
+func (u WebAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+// WebAuthnCredentials returns the credentials that the user has registered.
+func (u WebAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := []webauthn.Credential{}
+	for _, credential := range u.Credentials {
+		credentials = append(credentials, credential.Credential)
+	}
+	return credentials
+}
+
+const webAuthnCredentialSelect = "SELECT id, name, credentialId, publicKey, attestationType, aaguid, signCount, createdAt, IFNULL(lastUsedAt, -1), userId FROM webauthn_credentials "
+
+func scanWebAuthnCredentials(rows *sql.Rows) ([]WebAuthnCredential, error) {
+	credentials := []WebAuthnCredential{}
+	for rows.Next() {
+		credential := WebAuthnCredential{}
+		err := rows.Scan(
+			&credential.ID,
+			&credential.Name,
+			&credential.Credential.ID,
+			&credential.Credential.PublicKey,
+			&credential.Credential.AttestationType,
+			&credential.Credential.Authenticator.AAGUID,
+			&credential.Credential.Authenticator.SignCount,
+			&credential.CreatedAt,
+			&credential.LastUsedAt,
+			&credential.UserID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+	return credentials, nil
+}
+
+// GetWebAuthnCredentialByID fetches the WebAuthnCredential with the given ID, if it belongs to the given user.
+func GetWebAuthnCredentialByID(id int, userID int) (WebAuthnCredential, error) {
+	rows, err := DB.Query(webAuthnCredentialSelect+"WHERE id = ? AND userId = ?", id, userID)
+	if err != nil {
+		return WebAuthnCredential{}, err
+	}
+	defer rows.Close()
+
+	credentials, err := scanWebAuthnCredentials(rows)
+	if err != nil {
+		return WebAuthnCredential{}, err
+	}
+
+	if len(credentials) == 0 {
+		return WebAuthnCredential{}, ErrNotFound
+	}
+
+	return credentials[0], nil
+}
+
+// GetWebAuthnCredentialsForUser returns all WebAuthnCredentials registered by the given user, oldest first.
+func GetWebAuthnCredentialsForUser(userID int) ([]WebAuthnCredential, error) {
+	rows, err := DB.Query(webAuthnCredentialSelect+"WHERE userId = ? ORDER BY id ASC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanWebAuthnCredentials(rows)
+}
+
+// AddWebAuthnCredential saves a newly registered credential for the given user, and returns its ID.
+func AddWebAuthnCredential(userID int, name string, credential webauthn.Credential, createdAt int) (int, error) {
+	aaguid := credential.Authenticator.AAGUID
+	if aaguid == nil {
+		aaguid = []byte{}
+	}
+
+	result, err := DB.Exec(
+		"INSERT INTO webauthn_credentials(name, credentialId, publicKey, attestationType, aaguid, signCount, createdAt, userId) VALUES(?, ?, ?, ?, ?, ?, ?, ?)",
+		name, credential.ID, credential.PublicKey, credential.AttestationType, aaguid, credential.Authenticator.SignCount, createdAt, userID,
+	)
+	if err != nil {
+		return -1, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+
+	return int(id), nil
+}
+
+// UpdateWebAuthnCredentialUse records that the credential with the given credential ID was used to log in, and stores its new sign counter.
+func UpdateWebAuthnCredentialUse(credentialID []byte, signCount uint32, usedAt int) error {
+	_, err := DB.Exec("UPDATE webauthn_credentials SET signCount = ?, lastUsedAt = ? WHERE credentialId = ?", signCount, usedAt, credentialID)
+	return err
+}
@@ -0,0 +1,579 @@
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/util"
+)
+
+// MaxWebhooks is the most webhooks that one user can have, including ones made by applications.
+const MaxWebhooks = 20
+
+// WebhookDeliveryRetention is how long deliveries are kept in the log.
+const WebhookDeliveryRetention = 30 * 24 * time.Hour
+
+// how long a delivery is left alone after being claimed, so that a crash in the middle of sending it doesn't lose it
+const webhookDeliveryClaimLength = 5 * time.Minute
+
+// the longest response body that's kept in the delivery log
+const webhookMaxResponseBodyLength = 1000
+
+// how long to wait before retrying a delivery, after each failed attempt
+var webhookRetryDelays = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+}
+
+// A WebhookEvent is something that happened to a user's data, which webhooks can be told about.
+type WebhookEvent string
+
+// The WebhookEvents that webhooks can subscribe to.
+const (
+	WebhookEventHomeworkCreated      WebhookEvent = "homework.created"
+	WebhookEventHomeworkUpdated      WebhookEvent = "homework.updated"
+	WebhookEventHomeworkCompleted    WebhookEvent = "homework.completed"
+	WebhookEventHomeworkDeleted      WebhookEvent = "homework.deleted"
+	WebhookEventClassCreated         WebhookEvent = "class.created"
+	WebhookEventClassUpdated         WebhookEvent = "class.updated"
+	WebhookEventClassDeleted         WebhookEvent = "class.deleted"
+	WebhookEventCalendarEventCreated WebhookEvent = "calendar_event.created"
+	WebhookEventCalendarEventUpdated WebhookEvent = "calendar_event.updated"
+	WebhookEventCalendarEventDeleted WebhookEvent = "calendar_event.deleted"
+	WebhookEventCalendarEventChanged WebhookEvent = "calendar_event.changed" // a school schedule event was moved or cancelled
+	WebhookEventSchoolEnrolled       WebhookEvent = "school.enrolled"
+)
+
+// WebhookEventScopes is the Scope an application needs to get each WebhookEvent.
+var WebhookEventScopes = map[WebhookEvent]Scope{
+	WebhookEventHomeworkCreated:      ScopeHomeworkRead,
+	WebhookEventHomeworkUpdated:      ScopeHomeworkRead,
+	WebhookEventHomeworkCompleted:    ScopeHomeworkRead,
+	WebhookEventHomeworkDeleted:      ScopeHomeworkRead,
+	WebhookEventClassCreated:         ScopeClassesRead,
+	WebhookEventClassUpdated:         ScopeClassesRead,
+	WebhookEventClassDeleted:         ScopeClassesRead,
+	WebhookEventCalendarEventCreated: ScopeCalendarRead,
+	WebhookEventCalendarEventUpdated: ScopeCalendarRead,
+	WebhookEventCalendarEventDeleted: ScopeCalendarRead,
+	WebhookEventCalendarEventChanged: ScopeCalendarRead,
+	WebhookEventSchoolEnrolled:       ScopeCalendarRead,
+}
+
+// AllWebhookEvents is every WebhookEvent, in the order they should be shown to users.
+var AllWebhookEvents = []WebhookEvent{
+	WebhookEventHomeworkCreated,
+	WebhookEventHomeworkUpdated,
+	WebhookEventHomeworkCompleted,
+	WebhookEventHomeworkDeleted,
+	WebhookEventClassCreated,
+	WebhookEventClassUpdated,
+	WebhookEventClassDeleted,
+	WebhookEventCalendarEventCreated,
+	WebhookEventCalendarEventUpdated,
+	WebhookEventCalendarEventDeleted,
+	WebhookEventCalendarEventChanged,
+	WebhookEventSchoolEnrolled,
+}
+
+// ErrInvalidWebhookEvent is returned when parsing a webhook event that doesn't exist.
+var ErrInvalidWebhookEvent = errors.New("data: invalid webhook event")
+
+// A WebhookDeliveryStatus is how far along a WebhookDelivery is.
+type WebhookDeliveryStatus string
+
+// The WebhookDeliveryStatuses that a delivery can have.
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed" // every attempt failed, and it won't be retried
+)
+
+// A Webhook is a URL that gets sent events about a user's data.
+type Webhook struct {
+	ID                    int            `json:"id"`
+	URL                   string         `json:"url"`
+	Events                []WebhookEvent `json:"events"`
+	Enabled               bool           `json:"enabled"`
+	CreatedAt             int            `json:"createdAt"`
+	ApplicationID         int            `json:"applicationId"`         // the application that made the webhook, or -1 if the user made it themselves
+	PersonalAccessTokenID int            `json:"personalAccessTokenId"` // the personal access token that made the webhook, or -1 if it wasn't made with one
+	UserID                int            `json:"userId"`
+
+	// Secret is used to sign payloads. Unlike tokens, it can't be hashed, since it's needed to make the signatures.
+	Secret string `json:"-"`
+}
+
+// A WebhookDelivery is one event being sent to a Webhook. It's queued until it's delivered, and then kept as a log.
+type WebhookDelivery struct {
+	ID             int                   `json:"id"`
+	EventID        string                `json:"eventId"` // the same for redeliveries, so that receivers can ignore events they've already seen
+	Event          WebhookEvent          `json:"event"`
+	Payload        string                `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  int                   `json:"nextAttemptAt"`  // -1 if it won't be attempted again
+	LastAttemptAt  int                   `json:"lastAttemptAt"`  // -1 if it hasn't been attempted yet
+	ResponseStatus int                   `json:"responseStatus"` // -1 if the last attempt didn't get a response
+	ResponseBody   string                `json:"responseBody"`
+	Error          string                `json:"error"`
+	CreatedAt      int                   `json:"createdAt"`
+	WebhookID      int                   `json:"webhookId"`
+}
+
+// A WebhookPayload is the body of a request sent to a Webhook.
+type WebhookPayload struct {
+	ID        string       `json:"id"`
+	Type      WebhookEvent `json:"type"`
+	CreatedAt int64        `json:"createdAt"`
+	UserID    int          `json:"userId"`
+	Data      interface{}  `json:"data"`
+}
+
+// ParseWebhookEvents reads a space-separated list of webhook events, the same way as ParseScopes.
+func ParseWebhookEvents(text string) ([]WebhookEvent, error) {
+	values, valid := parseList(text, webhookEventStrings(AllWebhookEvents))
+
+	events := []WebhookEvent{}
+	for _, value := range values {
+		events = append(events, WebhookEvent(value))
+	}
+
+	if !valid {
+		return events, ErrInvalidWebhookEvent
+	}
+	return events, nil
+}
+
+// FormatWebhookEvents turns the given events into a space-separated list, in the same order as AllWebhookEvents.
+func FormatWebhookEvents(events []WebhookEvent) string {
+	return formatList(webhookEventStrings(events), webhookEventStrings(AllWebhookEvents))
+}
+
+func webhookEventStrings(events []WebhookEvent) []string {
+	values := []string{}
+	for _, event := range events {
+		values = append(values, string(event))
+	}
+	return values
+}
+
+// WebhookEventsAllowed returns whether the given scopes are enough to get every one of the given events.
+func WebhookEventsAllowed(scopes []Scope, events []WebhookEvent) bool {
+	for _, event := range events {
+		if !ScopesContain(scopes, WebhookEventScopes[event]) {
+			return false
+		}
+	}
+	return true
+}
+
+// webhookWantsEvent returns whether a webhook subscribed to the given events should get the given event.
+// Webhooks made with a token are limited to what the token can see now, which are given as scopes, so that a webhook can't outlive the token's access.
+func webhookWantsEvent(events []WebhookEvent, fromToken bool, scopes []Scope, event WebhookEvent) bool {
+	if !webhookEventsContain(events, event) {
+		return false
+	}
+
+	if fromToken && !ScopesContain(scopes, WebhookEventScopes[event]) {
+		return false
+	}
+
+	return true
+}
+
+func webhookEventsContain(events []WebhookEvent, event WebhookEvent) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// SignWebhookPayload returns the signature of a payload sent at the given time, which is an HMAC-SHA256 of the time and the payload.
+// The time is included so that receivers can reject old payloads that are being replayed.
+func SignWebhookPayload(secret string, timestamp int64, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "." + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GetWebhookRetryDelay returns how long to wait before retrying a delivery that has failed the given number of times.
+// If it shouldn't be retried anymore, false is returned.
+func GetWebhookRetryDelay(attempts int) (time.Duration, bool) {
+	if attempts < 1 || attempts > len(webhookRetryDelays) {
+		return 0, false
+	}
+	return webhookRetryDelays[attempts-1], true
+}
+
+const webhookSelect = "SELECT id, url, secret, events, enabled, createdAt, IFNULL(applicationId, -1), IFNULL(personalAccessTokenId, -1), userId FROM webhooks "
+
+func scanWebhooks(rows *sql.Rows) ([]Webhook, error) {
+	webhooks := []Webhook{}
+	for rows.Next() {
+		webhook := Webhook{}
+		events := ""
+		err := rows.Scan(&webhook.ID, &webhook.URL, &webhook.Secret, &events, &webhook.Enabled, &webhook.CreatedAt, &webhook.ApplicationID, &webhook.PersonalAccessTokenID, &webhook.UserID)
+		if err != nil {
+			return nil, err
+		}
+		webhook.Events, _ = ParseWebhookEvents(events)
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+// GetWebhook returns the webhook with the given ID.
+func GetWebhook(id int) (Webhook, error) {
+	rows, err := DB.Query(webhookSelect+"WHERE id = ?", id)
+	if err != nil {
+		return Webhook{}, err
+	}
+	defer rows.Close()
+
+	webhooks, err := scanWebhooks(rows)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	if len(webhooks) == 0 {
+		return Webhook{}, ErrNotFound
+	}
+
+	return webhooks[0], nil
+}
+
+// GetWebhooksForUser returns all of the given user's webhooks, including ones made by applications, oldest first.
+func GetWebhooksForUser(userID int) ([]Webhook, error) {
+	rows, err := DB.Query(webhookSelect+"WHERE userId = ? ORDER BY id ASC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanWebhooks(rows)
+}
+
+// AddWebhook creates a new webhook with the given details, and returns its ID.
+func AddWebhook(webhook Webhook) (int, error) {
+	var applicationID interface{}
+	if webhook.ApplicationID != -1 {
+		applicationID = webhook.ApplicationID
+	}
+
+	var personalAccessTokenID interface{}
+	if webhook.PersonalAccessTokenID != -1 {
+		personalAccessTokenID = webhook.PersonalAccessTokenID
+	}
+
+	result, err := DB.Exec(
+		"INSERT INTO webhooks(url, secret, events, enabled, createdAt, applicationId, personalAccessTokenId, userId) VALUES(?, ?, ?, ?, ?, ?, ?, ?)",
+		webhook.URL, webhook.Secret, FormatWebhookEvents(webhook.Events), webhook.Enabled, webhook.CreatedAt, applicationID, personalAccessTokenID, webhook.UserID,
+	)
+	if err != nil {
+		return -1, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+
+	return int(id), nil
+}
+
+// UpdateWebhook saves the URL, events, and enabled status of the given webhook.
+func UpdateWebhook(webhook Webhook) error {
+	_, err := DB.Exec(
+		"UPDATE webhooks SET url = ?, events = ?, enabled = ? WHERE id = ?",
+		webhook.URL, FormatWebhookEvents(webhook.Events), webhook.Enabled, webhook.ID,
+	)
+	return err
+}
+
+// DeleteWebhook deletes the given webhook, along with its delivery log.
+func DeleteWebhook(tx *sql.Tx, id int) error {
+	_, err := tx.Exec("DELETE FROM webhook_deliveries WHERE webhookId = ?", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM webhooks WHERE id = ?", id)
+	return err
+}
+
+// DeleteWebhooksForApplication deletes the webhooks that the given application made for the given user, or for every user if userID is -1.
+func DeleteWebhooksForApplication(tx *sql.Tx, applicationID int, userID int) error {
+	_, err := tx.Exec(
+		"DELETE webhook_deliveries FROM webhook_deliveries INNER JOIN webhooks ON webhook_deliveries.webhookId = webhooks.id WHERE webhooks.applicationId = ? AND (webhooks.userId = ? OR ? = -1)",
+		applicationID, userID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM webhooks WHERE applicationId = ? AND (userId = ? OR ? = -1)", applicationID, userID, userID)
+	return err
+}
+
+// DeleteWebhooksForPersonalAccessToken deletes the webhooks that were made with the given personal access token.
+func DeleteWebhooksForPersonalAccessToken(tx *sql.Tx, personalAccessTokenID int) error {
+	_, err := tx.Exec(
+		"DELETE webhook_deliveries FROM webhook_deliveries INNER JOIN webhooks ON webhook_deliveries.webhookId = webhooks.id WHERE webhooks.personalAccessTokenId = ?",
+		personalAccessTokenID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM webhooks WHERE personalAccessTokenId = ?", personalAccessTokenID)
+	return err
+}
+
+// QueueWebhookEvent queues a delivery of the given event to each of the user's webhooks that wants it.
+// Webhooks made by applications only get events that the user has let the application see, and ones made with a personal access token only get events that the token can see.
+func QueueWebhookEvent(userID int, event WebhookEvent, data interface{}, now time.Time) error {
+	// an expired token can't see anything, the same as one that was revoked
+	rows, err := DB.Query(
+		"SELECT webhooks.id, webhooks.events, IFNULL(webhooks.applicationId, -1), IFNULL(webhooks.personalAccessTokenId, -1), "+
+			"IFNULL(application_authorizations.scopes, ''), IFNULL(personal_access_tokens.scopes, '') FROM webhooks "+
+			"LEFT JOIN application_authorizations ON webhooks.applicationId = application_authorizations.applicationId AND webhooks.userId = application_authorizations.userId "+
+			"LEFT JOIN personal_access_tokens ON webhooks.personalAccessTokenId = personal_access_tokens.id AND (personal_access_tokens.expiresAt IS NULL OR personal_access_tokens.expiresAt > ?) "+
+			"WHERE webhooks.userId = ? AND webhooks.enabled = 1",
+		now.Unix(), userID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	webhookIDs := []int{}
+	for rows.Next() {
+		webhookID := -1
+		eventsString := ""
+		applicationID := -1
+		personalAccessTokenID := -1
+		applicationScopesString := ""
+		tokenScopesString := ""
+		err = rows.Scan(&webhookID, &eventsString, &applicationID, &personalAccessTokenID, &applicationScopesString, &tokenScopesString)
+		if err != nil {
+			return err
+		}
+
+		events, _ := ParseWebhookEvents(eventsString)
+		scopesString := applicationScopesString
+		if personalAccessTokenID != -1 {
+			scopesString = tokenScopesString
+		}
+		scopes, _ := ParseScopes(scopesString)
+
+		if !webhookWantsEvent(events, applicationID != -1 || personalAccessTokenID != -1, scopes, event) {
+			continue
+		}
+
+		webhookIDs = append(webhookIDs, webhookID)
+	}
+	rows.Close()
+
+	if len(webhookIDs) == 0 {
+		return nil
+	}
+
+	eventID, err := util.GenerateRandomString(32)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(WebhookPayload{eventID, event, now.Unix(), userID, data})
+	if err != nil {
+		return err
+	}
+
+	for _, webhookID := range webhookIDs {
+		_, err = insertWebhookDelivery(webhookID, eventID, event, string(payload), now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func insertWebhookDelivery(webhookID int, eventID string, event WebhookEvent, payload string, now time.Time) (int, error) {
+	result, err := DB.Exec(
+		"INSERT INTO webhook_deliveries(eventId, event, payload, status, attempts, nextAttemptAt, createdAt, webhookId) VALUES(?, ?, ?, ?, 0, ?, ?, ?)",
+		eventID, string(event), payload, string(WebhookDeliveryPending), now.Unix(), now.Unix(), webhookID,
+	)
+	if err != nil {
+		return -1, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+
+	return int(id), nil
+}
+
+const webhookDeliverySelect = "SELECT id, eventId, event, payload, status, attempts, IFNULL(nextAttemptAt, -1), IFNULL(lastAttemptAt, -1), IFNULL(responseStatus, -1), IFNULL(responseBody, ''), IFNULL(error, ''), createdAt, webhookId FROM webhook_deliveries "
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]WebhookDelivery, error) {
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		delivery := WebhookDelivery{}
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.EventID,
+			&delivery.Event,
+			&delivery.Payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&delivery.LastAttemptAt,
+			&delivery.ResponseStatus,
+			&delivery.ResponseBody,
+			&delivery.Error,
+			&delivery.CreatedAt,
+			&delivery.WebhookID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// GetWebhookDelivery returns the delivery with the given ID.
+func GetWebhookDelivery(id int) (WebhookDelivery, error) {
+	rows, err := DB.Query(webhookDeliverySelect+"WHERE id = ?", id)
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+	defer rows.Close()
+
+	deliveries, err := scanWebhookDeliveries(rows)
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+
+	if len(deliveries) == 0 {
+		return WebhookDelivery{}, ErrNotFound
+	}
+
+	return deliveries[0], nil
+}
+
+// GetWebhookDeliveriesForWebhook returns the most recent deliveries to the given webhook, newest first.
+func GetWebhookDeliveriesForWebhook(webhookID int, limit int) ([]WebhookDelivery, error) {
+	rows, err := DB.Query(webhookDeliverySelect+"WHERE webhookId = ? ORDER BY id DESC LIMIT ?", webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanWebhookDeliveries(rows)
+}
+
+// GetDueWebhookDeliveries returns deliveries that should be attempted now, oldest first.
+// They still have to be claimed with ClaimWebhookDelivery before they're sent.
+func GetDueWebhookDeliveries(now time.Time, limit int) ([]WebhookDelivery, error) {
+	rows, err := DB.Query(webhookDeliverySelect+"WHERE status = ? AND nextAttemptAt <= ? ORDER BY nextAttemptAt ASC, id ASC LIMIT ?", string(WebhookDeliveryPending), now.Unix(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanWebhookDeliveries(rows)
+}
+
+// ClaimWebhookDelivery makes sure that nothing else is sending the given delivery, and returns false if something else got to it first.
+// If the delivery isn't finished with FinishWebhookDeliveryAttempt, it's tried again once the claim runs out.
+func ClaimWebhookDelivery(delivery WebhookDelivery, now time.Time) (bool, error) {
+	result, err := DB.Exec(
+		"UPDATE webhook_deliveries SET nextAttemptAt = ? WHERE id = ? AND status = ? AND nextAttemptAt = ?",
+		now.Add(webhookDeliveryClaimLength).Unix(), delivery.ID, string(WebhookDeliveryPending), delivery.NextAttemptAt,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return (rowsAffected == 1), nil
+}
+
+// FinishWebhookDeliveryAttempt records the result of trying to send the given delivery, and schedules a retry if it failed.
+// The responseStatus should be -1 if no response was received, in which case errorText should say why.
+func FinishWebhookDeliveryAttempt(delivery WebhookDelivery, succeeded bool, responseStatus int, responseBody string, errorText string, now time.Time) error {
+	status := WebhookDeliverySucceeded
+	var nextAttemptAt interface{}
+	if !succeeded {
+		delay, retry := GetWebhookRetryDelay(delivery.Attempts + 1)
+		if retry {
+			status = WebhookDeliveryPending
+			nextAttemptAt = now.Add(delay).Unix()
+		} else {
+			status = WebhookDeliveryFailed
+		}
+	}
+
+	return saveWebhookDeliveryAttempt(delivery, status, nextAttemptAt, responseStatus, responseBody, errorText, now)
+}
+
+// FailWebhookDelivery records that the given delivery can't be sent at all, like when its webhook was disabled, so that it isn't retried.
+func FailWebhookDelivery(delivery WebhookDelivery, errorText string, now time.Time) error {
+	return saveWebhookDeliveryAttempt(delivery, WebhookDeliveryFailed, nil, -1, "", errorText, now)
+}
+
+func saveWebhookDeliveryAttempt(delivery WebhookDelivery, status WebhookDeliveryStatus, nextAttemptAt interface{}, responseStatus int, responseBody string, errorText string, now time.Time) error {
+	var responseStatusValue interface{}
+	if responseStatus != -1 {
+		responseStatusValue = responseStatus
+	}
+
+	if len(responseBody) > webhookMaxResponseBodyLength {
+		responseBody = responseBody[:webhookMaxResponseBodyLength]
+	}
+	// the receiver can send back anything, and it has to fit in the database
+	responseBody = strings.ToValidUTF8(responseBody, "")
+
+	_, err := DB.Exec(
+		"UPDATE webhook_deliveries SET status = ?, attempts = ?, nextAttemptAt = ?, lastAttemptAt = ?, responseStatus = ?, responseBody = ?, error = ? WHERE id = ?",
+		string(status), delivery.Attempts+1, nextAttemptAt, now.Unix(), responseStatusValue, responseBody, errorText, delivery.ID,
+	)
+	return err
+}
+
+// RedeliverWebhookDelivery queues the given delivery to be sent again, as a new delivery with the same event ID and payload.
+func RedeliverWebhookDelivery(delivery WebhookDelivery, now time.Time) (int, error) {
+	return insertWebhookDelivery(delivery.WebhookID, delivery.EventID, delivery.Event, delivery.Payload, now)
+}
+
+// DeleteOldWebhookDeliveries removes finished deliveries created before the given time from the log, and returns how many there were.
+func DeleteOldWebhookDeliveries(before time.Time) (int64, error) {
+	result, err := DB.Exec("DELETE FROM webhook_deliveries WHERE status != ? AND createdAt < ?", string(WebhookDeliveryPending), before.Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,137 @@
+package data
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+)
+
+func TestWebhookEventScopes(t *testing.T) {
+	for _, event := range AllWebhookEvents {
+		if _, ok := WebhookEventScopes[event]; !ok {
+			t.Errorf("event %s has no scope", event)
+		}
+	}
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	// from running `printf '1600000000.{"a":1}' | openssl dgst -sha256 -hmac secret`
+	signature := SignWebhookPayload("secret", 1600000000, `{"a":1}`)
+	if signature != "4e107d82910257d43758070322323c95b92af39939824d6610e2c9809a43b8d5" {
+		t.Errorf("unexpected signature %s", signature)
+	}
+}
+
+func TestGetWebhookRetryDelay(t *testing.T) {
+	lastDelay, retry := GetWebhookRetryDelay(1)
+	if !retry {
+		t.Fatalf("expected the first failure to be retried")
+	}
+
+	for attempts := 2; attempts <= len(webhookRetryDelays); attempts++ {
+		delay, retry := GetWebhookRetryDelay(attempts)
+		if !retry || delay <= lastDelay {
+			t.Errorf("expected attempt %d to be retried after longer than %s, got %s (retry %t)", attempts, lastDelay, delay, retry)
+		}
+		lastDelay = delay
+	}
+
+	_, retry = GetWebhookRetryDelay(len(webhookRetryDelays) + 1)
+	if retry {
+		t.Errorf("expected the last failure to not be retried")
+	}
+}
+
+func TestWebhookWantsEvent(t *testing.T) {
+	events := []WebhookEvent{WebhookEventHomeworkCreated, WebhookEventClassCreated}
+	scopes := []Scope{ScopeHomeworkRead}
+
+	if !webhookWantsEvent(events, false, nil, WebhookEventClassCreated) {
+		t.Errorf("expected a webhook the user made to get every event it subscribed to")
+	}
+	if webhookWantsEvent(events, false, nil, WebhookEventHomeworkDeleted) {
+		t.Errorf("expected a webhook to not get events it didn't subscribe to")
+	}
+
+	if !webhookWantsEvent(events, true, scopes, WebhookEventHomeworkCreated) {
+		t.Errorf("expected a token's webhook to get events its scopes cover")
+	}
+	if webhookWantsEvent(events, true, scopes, WebhookEventClassCreated) {
+		t.Errorf("expected a token's webhook to not get events its scopes don't cover")
+	}
+	if webhookWantsEvent(events, true, nil, WebhookEventHomeworkCreated) {
+		t.Errorf("expected a webhook from a revoked token to not get anything")
+	}
+}
+
+func TestQueueWebhookEventScopes(t *testing.T) {
+	db, done := useTestDatabase(t)
+	defer done()
+
+	// id, events, applicationId, personalAccessTokenId, application scopes, token scopes
+	db.rows = [][]driver.Value{
+		{int64(1), "homework.created class.created", int64(-1), int64(-1), "", ""},
+		{int64(2), "class.created", int64(-1), int64(7), "", "grades:read"},
+		{int64(3), "class.created", int64(-1), int64(8), "", "classes:read"},
+		{int64(4), "class.created", int64(5), int64(-1), "homework:read", ""},
+		{int64(5), "class.created", int64(6), int64(-1), "classes:read classes:write", ""},
+		{int64(6), "homework.created", int64(-1), int64(-1), "", ""},
+	}
+	db.columns = []string{"id", "events", "applicationId", "personalAccessTokenId", "applicationScopes", "tokenScopes"}
+
+	err := QueueWebhookEvent(1, WebhookEventClassCreated, webhookTestItem{1, "Math"}, time.Unix(1600000000, 0))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	webhookIDs := []int64{}
+	for _, query := range db.queries[1:] {
+		webhookIDs = append(webhookIDs, query.args[len(query.args)-1].(int64))
+	}
+
+	expectedIDs := []int64{1, 3, 5}
+	if len(webhookIDs) != len(expectedIDs) {
+		t.Fatalf("expected deliveries to webhooks %v, got %v", expectedIDs, webhookIDs)
+	}
+	for i := range expectedIDs {
+		if webhookIDs[i] != expectedIDs[i] {
+			t.Fatalf("expected deliveries to webhooks %v, got %v", expectedIDs, webhookIDs)
+		}
+	}
+}
+
+func TestClaimWebhookDelivery(t *testing.T) {
+	db, done := useTestDatabase(t)
+	defer done()
+
+	now := time.Unix(1600000000, 0)
+	delivery := WebhookDelivery{ID: 12, Status: WebhookDeliveryPending, NextAttemptAt: 1599999990}
+
+	claimed, err := ClaimWebhookDelivery(delivery, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !claimed {
+		t.Errorf("expected the delivery to be claimed")
+	}
+
+	// the claim only works if nobody else has moved nextAttemptAt since the delivery was read
+	args := db.queries[0].args
+	if args[0] != now.Add(webhookDeliveryClaimLength).Unix() || args[1] != int64(12) || args[2] != string(WebhookDeliveryPending) || args[3] != int64(1599999990) {
+		t.Errorf("unexpected claim arguments %v", args)
+	}
+
+	db.rowsAffected = 0
+	claimed, err = ClaimWebhookDelivery(delivery, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if claimed {
+		t.Errorf("expected the delivery to not be claimed after something else got to it")
+	}
+}
+
+type webhookTestItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
@@ -76,6 +76,15 @@ func renderHTMLTemplate(basePath string, filePath string, data templateData) (st
 
 // Send sends the email with the given template name and data to the specified recipient. If recipient is blank, the user's email is used as the recipient.
 func Send(recipient string, user *data.User, templateName string, data map[string]interface{}) error {
+	return send(recipient, user, templateName, data, "")
+}
+
+// SendWithUnsubscribe works like Send, but also adds headers that let the recipient's email client unsubscribe them with one click by sending a POST request to unsubscribeURL.
+func SendWithUnsubscribe(recipient string, user *data.User, templateName string, data map[string]interface{}, unsubscribeURL string) error {
+	return send(recipient, user, templateName, data, "List-Unsubscribe: <"+unsubscribeURL+">\r\nList-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+}
+
+func send(recipient string, user *data.User, templateName string, data map[string]interface{}, extraHeaders string) error {
 	emailConfig := config.GetCurrent().Email
 
 	if !emailConfig.Enabled {
@@ -137,6 +146,7 @@ func Send(recipient string, user *data.User, templateName string, data map[strin
 			"Sender: " + emailConfig.FromDisplay + "\r\n" +
 			"Message-ID: <" + messageIDFirst + "@" + messageIDDomain + ">\r\n" +
 			"Date: " + time.Now().Format("Mon, 02 Jan 2006 15:04:05 -0700") + "\r\n" +
+			extraHeaders +
 			"MIME-Version: 1.0\r\n" +
 			"Content-Type: multipart/alternative; boundary=\"mimeboundary\"\r\n\r\n" +
 			"--mimeboundary\r\n" +
@@ -0,0 +1,128 @@
+package grades
+
+import (
+	"errors"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+)
+
+// ErrItemNotFound is returned when projecting a grade for an item that isn't in the class.
+var ErrItemNotFound = errors.New("grades: item not found")
+
+// ErrNoEffect is returned when projecting a grade for an item that can't change the class's grade, like one in a category with no weight.
+var ErrNoEffect = errors.New("grades: item has no effect on grade")
+
+// A CategoryGrade is the grade for a single GradeCategory.
+type CategoryGrade struct {
+	CategoryID int      `json:"categoryId"`
+	Weight     float64  `json:"weight"`
+	Earned     float64  `json:"earned"`
+	Possible   float64  `json:"possible"`
+	Percent    *float64 `json:"percent"` // nil if nothing in the category has been graded yet
+}
+
+// A Summary is the grade for a class, out of 100.
+type Summary struct {
+	Current    *float64        `json:"current"` // nil if nothing in the class has been graded yet
+	Categories []CategoryGrade `json:"categories"`
+}
+
+// A Projection describes what score is needed on an item to end up with a certain grade in its class.
+type Projection struct {
+	ItemID        int     `json:"itemId"`
+	Target        float64 `json:"target"`
+	NeededScore   float64 `json:"neededScore"`
+	NeededPercent float64 `json:"neededPercent"`
+
+	// Possible is false if the target can't be reached without getting more than the item's maximum score.
+	Possible bool `json:"possible"`
+}
+
+// Calculate finds the current grade in a class, given its categories and items.
+// Only items that have been graded count, and categories that have nothing graded in them are left out, with the others weighted as if they were all there was.
+func Calculate(categories []data.GradeCategory, items []data.GradeItem) Summary {
+	summary := Summary{
+		Categories: []CategoryGrade{},
+	}
+
+	totalWeight := 0.0
+	weightedSum := 0.0
+	for _, category := range categories {
+		categoryGrade := CategoryGrade{
+			CategoryID: category.ID,
+			Weight:     category.Weight,
+		}
+
+		for _, item := range items {
+			if item.CategoryID != category.ID || item.Score == nil {
+				continue
+			}
+
+			categoryGrade.Earned += *item.Score
+			categoryGrade.Possible += item.MaxScore
+		}
+
+		if categoryGrade.Possible > 0 {
+			percent := categoryGrade.Earned / categoryGrade.Possible * 100
+			categoryGrade.Percent = &percent
+
+			totalWeight += category.Weight
+			weightedSum += category.Weight * percent
+		}
+
+		summary.Categories = append(summary.Categories, categoryGrade)
+	}
+
+	if totalWeight > 0 {
+		current := weightedSum / totalWeight
+		summary.Current = &current
+	}
+
+	return summary
+}
+
+// Project finds the score needed on the given item to end up with the target grade in its class, assuming everything else stays the same.
+// If the item has already been graded, its current score is ignored.
+func Project(categories []data.GradeCategory, items []data.GradeItem, itemID int, target float64) (Projection, error) {
+	itemIndex := -1
+	for i, item := range items {
+		if item.ID == itemID {
+			itemIndex = i
+			break
+		}
+	}
+
+	if itemIndex == -1 {
+		return Projection{}, ErrItemNotFound
+	}
+
+	maxScore := items[itemIndex].MaxScore
+
+	// the grade changes linearly with the item's score, so we can find it from the grades with the lowest and highest scores
+	lowest := gradeWithScore(categories, items, itemIndex, 0)
+	highest := gradeWithScore(categories, items, itemIndex, maxScore)
+	if lowest == nil || highest == nil || *highest == *lowest {
+		return Projection{}, ErrNoEffect
+	}
+
+	neededScore := (target - *lowest) / (*highest - *lowest) * maxScore
+	if neededScore < 0 {
+		neededScore = 0
+	}
+
+	return Projection{
+		ItemID:        itemID,
+		Target:        target,
+		NeededScore:   neededScore,
+		NeededPercent: neededScore / maxScore * 100,
+		Possible:      (neededScore <= maxScore),
+	}, nil
+}
+
+func gradeWithScore(categories []data.GradeCategory, items []data.GradeItem, itemIndex int, score float64) *float64 {
+	itemsWithScore := make([]data.GradeItem, len(items))
+	copy(itemsWithScore, items)
+	itemsWithScore[itemIndex].Score = &score
+
+	return Calculate(categories, itemsWithScore).Current
+}
@@ -0,0 +1,110 @@
+package grades
+
+import (
+	"math"
+	"testing"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+)
+
+func score(value float64) *float64 {
+	return &value
+}
+
+func almostEqual(a float64, b float64) bool {
+	return math.Abs(a-b) < 0.0001
+}
+
+var testCategories = []data.GradeCategory{
+	{ID: 1, ClassID: 1, Name: "Problem sets", Weight: 40},
+	{ID: 2, ClassID: 1, Name: "Midterm", Weight: 25},
+	{ID: 3, ClassID: 1, Name: "Final", Weight: 35},
+}
+
+var testItems = []data.GradeItem{
+	{ID: 1, CategoryID: 1, ClassID: 1, Name: "Pset 1", Score: score(9), MaxScore: 10},
+	{ID: 2, CategoryID: 1, ClassID: 1, Name: "Pset 2", Score: score(7), MaxScore: 10},
+	{ID: 3, CategoryID: 1, ClassID: 1, Name: "Pset 3", Score: nil, MaxScore: 10},
+	{ID: 4, CategoryID: 2, ClassID: 1, Name: "Midterm", Score: score(70), MaxScore: 100},
+	{ID: 5, CategoryID: 3, ClassID: 1, Name: "Final", Score: nil, MaxScore: 100},
+}
+
+func TestCalculate(t *testing.T) {
+	summary := Calculate(testCategories, testItems)
+
+	// psets are at 80%, the midterm at 70%, and the final isn't graded yet
+	expected := (40*80.0 + 25*70.0) / 65
+	if summary.Current == nil || !almostEqual(*summary.Current, expected) {
+		t.Errorf("expected current grade %f, got %v", expected, summary.Current)
+	}
+
+	if len(summary.Categories) != 3 {
+		t.Fatalf("expected 3 categories, got %d", len(summary.Categories))
+	}
+	if summary.Categories[0].Percent == nil || !almostEqual(*summary.Categories[0].Percent, 80) {
+		t.Errorf("expected problem sets at 80, got %v", summary.Categories[0].Percent)
+	}
+	if summary.Categories[2].Percent != nil {
+		t.Errorf("expected no grade for the final, got %f", *summary.Categories[2].Percent)
+	}
+}
+
+func TestCalculateNothingGraded(t *testing.T) {
+	summary := Calculate(testCategories, []data.GradeItem{
+		{ID: 1, CategoryID: 1, ClassID: 1, Name: "Pset 1", Score: nil, MaxScore: 10},
+	})
+
+	if summary.Current != nil {
+		t.Errorf("expected no current grade, got %f", *summary.Current)
+	}
+}
+
+func TestProject(t *testing.T) {
+	projection, err := Project(testCategories, testItems, 5, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 0.4*80 + 0.25*70 + 0.35*x = 80
+	expected := (80 - 0.4*80 - 0.25*70) / 0.35
+	if !almostEqual(projection.NeededScore, expected) {
+		t.Errorf("expected needed score %f, got %f", expected, projection.NeededScore)
+	}
+	if !projection.Possible {
+		t.Errorf("expected projection to be possible")
+	}
+
+	projection, err = Project(testCategories, testItems, 5, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if projection.Possible {
+		t.Errorf("expected projection to be impossible, needed %f", projection.NeededScore)
+	}
+
+	projection, err = Project(testCategories, testItems, 5, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if projection.NeededScore != 0 {
+		t.Errorf("expected needed score 0, got %f", projection.NeededScore)
+	}
+}
+
+func TestProjectErrors(t *testing.T) {
+	_, err := Project(testCategories, testItems, 42, 90)
+	if err != ErrItemNotFound {
+		t.Errorf("expected ErrItemNotFound, got %v", err)
+	}
+
+	categories := []data.GradeCategory{
+		{ID: 1, ClassID: 1, Name: "Participation", Weight: 0},
+	}
+	items := []data.GradeItem{
+		{ID: 1, CategoryID: 1, ClassID: 1, Name: "Week 1", Score: nil, MaxScore: 5},
+	}
+	_, err = Project(categories, items, 1, 90)
+	if err != ErrNoEffect {
+		t.Errorf("expected ErrNoEffect, got %v", err)
+	}
+}
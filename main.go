@@ -19,6 +19,7 @@ import (
 	"github.com/MyHomeworkSpace/api-server/config"
 	"github.com/MyHomeworkSpace/api-server/data"
 	"github.com/MyHomeworkSpace/api-server/email"
+	"github.com/MyHomeworkSpace/api-server/search"
 )
 
 type errorResponse struct {
@@ -38,7 +39,7 @@ func main() {
 
 	initDatabase()
 	initRedis()
-	// initWebauthn()
+	initWebauthn()
 
 	migrationName := flag.String("migrate", "", "If specified, the API server will run the migration with the given name.")
 	flag.Parse()
@@ -51,6 +52,7 @@ func main() {
 	email.Init()
 
 	api.DB = DB
+	api.HomeworkIndex = search.NewMySQLIndex(DB)
 	api.MainRegistry = schools.MainRegistry
 	api.RedisClient = RedisClient
 	api.WebAuthnHandler = WebAuthnHandler
@@ -0,0 +1,9 @@
+-- Description: Add trash for deleted items
+-- Down migration
+
+ALTER TABLE `calendar_hwevents` DROP COLUMN `trashId`;
+ALTER TABLE `calendar_events` DROP COLUMN `trashId`;
+ALTER TABLE `classes` DROP COLUMN `trashId`;
+ALTER TABLE `homework` DROP COLUMN `trashId`;
+
+DROP TABLE IF EXISTS `trash`;
@@ -0,0 +1,26 @@
+-- Description: Add trash for deleted items
+-- Up migration
+
+CREATE TABLE `trash` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `type` tinyint(1) NOT NULL,
+  `itemId` int NOT NULL,
+  `name` text COLLATE utf8mb4_unicode_ci NOT NULL,
+  `deletedAt` int NOT NULL,
+  `userId` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `userId` (`userId`),
+  KEY `deletedAt` (`deletedAt`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+ALTER TABLE `homework`
+ADD `trashId` int DEFAULT NULL AFTER `userId`;
+
+ALTER TABLE `classes`
+ADD `trashId` int DEFAULT NULL AFTER `userId`;
+
+ALTER TABLE `calendar_events`
+ADD `trashId` int DEFAULT NULL AFTER `userId`;
+
+ALTER TABLE `calendar_hwevents`
+ADD `trashId` int DEFAULT NULL AFTER `userId`;
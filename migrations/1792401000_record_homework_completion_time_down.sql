@@ -0,0 +1,4 @@
+-- Description: Record homework completion time
+-- Down migration
+
+ALTER TABLE `homework` DROP COLUMN `completedAt`;
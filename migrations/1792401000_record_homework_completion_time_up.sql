@@ -0,0 +1,5 @@
+-- Description: Record homework completion time
+-- Up migration
+
+ALTER TABLE `homework`
+ADD `completedAt` int DEFAULT NULL AFTER `complete`;
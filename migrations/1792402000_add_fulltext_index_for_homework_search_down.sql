@@ -0,0 +1,5 @@
+-- Description: Add fulltext index for homework search
+-- Down migration
+
+ALTER TABLE `homework`
+DROP INDEX `name_desc`;
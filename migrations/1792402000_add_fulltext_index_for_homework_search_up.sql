@@ -0,0 +1,5 @@
+-- Description: Add fulltext index for homework search
+-- Up migration
+
+ALTER TABLE `homework`
+ADD FULLTEXT INDEX `name_desc` (`name`, `desc`);
@@ -0,0 +1,10 @@
+-- Description: Add homework sharing
+-- Down migration
+
+ALTER TABLE `homework`
+DROP KEY `shareId`,
+DROP KEY `sourceId`,
+DROP COLUMN `shareId`,
+DROP COLUMN `sourceId`;
+
+DROP TABLE IF EXISTS `shares`;
@@ -0,0 +1,25 @@
+-- Description: Add homework sharing
+-- Up migration
+
+CREATE TABLE `shares` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `type` tinyint(1) NOT NULL,
+  `itemId` int NOT NULL,
+  `ownerId` int NOT NULL,
+  `email` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `recipientId` int DEFAULT NULL,
+  `recipientClassId` int DEFAULT NULL,
+  `accepted` tinyint(1) NOT NULL DEFAULT '0',
+  `createdAt` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `item` (`type`, `itemId`),
+  KEY `ownerId` (`ownerId`),
+  KEY `recipientId` (`recipientId`),
+  KEY `email` (`email`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+ALTER TABLE `homework`
+ADD `sourceId` int DEFAULT NULL AFTER `trashId`,
+ADD `shareId` int DEFAULT NULL AFTER `sourceId`,
+ADD KEY `sourceId` (`sourceId`),
+ADD KEY `shareId` (`shareId`);
@@ -0,0 +1,8 @@
+-- Description: Add terms and class archiving
+-- Down migration
+
+ALTER TABLE `classes`
+DROP COLUMN `archived`,
+DROP COLUMN `termId`;
+
+DROP TABLE IF EXISTS `terms`;
@@ -0,0 +1,15 @@
+-- Description: Add terms and class archiving
+-- Up migration
+
+CREATE TABLE `terms` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `name` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `startedAt` int NOT NULL,
+  `userId` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `userId` (`userId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+ALTER TABLE `classes`
+ADD `termId` int DEFAULT NULL AFTER `sortIndex`,
+ADD `archived` tinyint(1) NOT NULL DEFAULT '0' AFTER `termId`;
@@ -0,0 +1,10 @@
+-- Description: Add school class sync
+-- Down migration
+
+ALTER TABLE `classes`
+DROP KEY `school`,
+DROP COLUMN `schoolClassId`,
+DROP COLUMN `schoolId`;
+
+ALTER TABLE `schools`
+DROP COLUMN `syncClasses`;
@@ -0,0 +1,10 @@
+-- Description: Add school class sync
+-- Up migration
+
+ALTER TABLE `schools`
+ADD `syncClasses` tinyint(1) NOT NULL DEFAULT '0' AFTER `enabled`;
+
+ALTER TABLE `classes`
+ADD `schoolId` varchar(32) COLLATE utf8mb4_unicode_ci DEFAULT NULL AFTER `archived`,
+ADD `schoolClassId` varchar(64) COLLATE utf8mb4_unicode_ci DEFAULT NULL AFTER `schoolId`,
+ADD KEY `school` (`userId`, `schoolId`, `schoolClassId`);
@@ -0,0 +1,8 @@
+-- Description: Add homework event links
+-- Down migration
+
+ALTER TABLE `calendar_event_changes`
+DROP COLUMN `end`,
+DROP COLUMN `start`;
+
+DROP TABLE IF EXISTS `homework_events`;
@@ -0,0 +1,15 @@
+-- Description: Add homework event links
+-- Up migration
+
+CREATE TABLE `homework_events` (
+  `homeworkId` int NOT NULL,
+  `eventId` varchar(180) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `eventDate` date NOT NULL,
+  `userId` int NOT NULL,
+  PRIMARY KEY (`homeworkId`),
+  KEY `userId_eventId` (`userId`, `eventId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+ALTER TABLE `calendar_event_changes`
+ADD `start` int DEFAULT NULL AFTER `cancel`,
+ADD `end` int DEFAULT NULL AFTER `start`;
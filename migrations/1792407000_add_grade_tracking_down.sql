@@ -0,0 +1,6 @@
+-- Description: Add grade tracking
+-- Down migration
+
+DROP TABLE IF EXISTS `grade_items`;
+
+DROP TABLE IF EXISTS `grade_categories`;
@@ -0,0 +1,28 @@
+-- Description: Add grade tracking
+-- Up migration
+
+CREATE TABLE `grade_categories` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `classId` int NOT NULL,
+  `name` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `weight` double NOT NULL,
+  `userId` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `classId` (`classId`),
+  KEY `userId` (`userId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE `grade_items` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `categoryId` int NOT NULL,
+  `classId` int NOT NULL,
+  `homeworkId` int DEFAULT NULL,
+  `name` text COLLATE utf8mb4_unicode_ci NOT NULL,
+  `score` double DEFAULT NULL,
+  `maxScore` double NOT NULL,
+  `userId` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `classId` (`classId`),
+  KEY `categoryId` (`categoryId`),
+  KEY `userId` (`userId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,11 @@
+-- Description: Add class details
+-- Down migration
+
+DROP TABLE IF EXISTS `class_office_hours`;
+
+DROP TABLE IF EXISTS `class_contacts`;
+
+ALTER TABLE `classes`
+DROP COLUMN `meetingLink`,
+DROP COLUMN `syllabus`,
+DROP COLUMN `website`;
@@ -0,0 +1,32 @@
+-- Description: Add class details
+-- Up migration
+
+ALTER TABLE `classes`
+ADD `website` varchar(512) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' AFTER `color`,
+ADD `syllabus` varchar(512) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' AFTER `website`,
+ADD `meetingLink` varchar(512) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' AFTER `syllabus`;
+
+CREATE TABLE `class_contacts` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `classId` int NOT NULL,
+  `name` varchar(128) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `role` tinyint NOT NULL,
+  `email` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `userId` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `classId` (`classId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE `class_office_hours` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `classId` int NOT NULL,
+  `contactId` int DEFAULT NULL,
+  `dayOfWeek` tinyint NOT NULL,
+  `start` int NOT NULL,
+  `end` int NOT NULL,
+  `location` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `userId` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `classId` (`classId`),
+  KEY `userId` (`userId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
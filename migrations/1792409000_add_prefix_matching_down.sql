@@ -0,0 +1,7 @@
+-- Description: Add prefix matching options
+-- Down migration
+
+ALTER TABLE `prefixes`
+DROP COLUMN `priority`,
+DROP COLUMN `classIds`,
+DROP COLUMN `isRegex`;
@@ -0,0 +1,7 @@
+-- Description: Add prefix matching options
+-- Up migration
+
+ALTER TABLE `prefixes`
+ADD `isRegex` tinyint NOT NULL DEFAULT 0 AFTER `isTimedEvent`,
+ADD `classIds` varchar(1024) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '[]' AFTER `isRegex`,
+ADD `priority` int NOT NULL DEFAULT 0 AFTER `classIds`;
@@ -0,0 +1,8 @@
+-- Description: Add prefix packs
+-- Down migration
+
+DROP TABLE IF EXISTS `prefix_pack_subscriptions`;
+
+DROP TABLE IF EXISTS `prefix_pack_items`;
+
+DROP TABLE IF EXISTS `prefix_packs`;
@@ -0,0 +1,32 @@
+-- Description: Add prefix packs
+-- Up migration
+
+CREATE TABLE `prefix_packs` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `name` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `description` varchar(512) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `authorId` int NOT NULL,
+  `createdAt` int NOT NULL,
+  `updatedAt` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `authorId` (`authorId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE `prefix_pack_items` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `packId` int NOT NULL,
+  `prefixId` int NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `packPrefix` (`packId`, `prefixId`),
+  KEY `prefixId` (`prefixId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE `prefix_pack_subscriptions` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `packId` int NOT NULL,
+  `userId` int NOT NULL,
+  `subscribedAt` int NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `packUser` (`packId`, `userId`),
+  KEY `userId` (`userId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
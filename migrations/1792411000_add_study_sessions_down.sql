@@ -0,0 +1,6 @@
+-- Description: Add study sessions
+-- Down migration
+
+DROP TABLE IF EXISTS `homework_estimates`;
+
+DROP TABLE IF EXISTS `study_sessions`;
@@ -0,0 +1,25 @@
+-- Description: Add study sessions
+-- Up migration
+
+CREATE TABLE `study_sessions` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `homeworkId` int NOT NULL,
+  `start` int NOT NULL,
+  `end` int DEFAULT NULL,
+  `duration` int NOT NULL DEFAULT 0,
+  `runningSince` int DEFAULT NULL,
+  `hwEventId` int DEFAULT NULL,
+  `userId` int NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `hwEventId` (`hwEventId`),
+  KEY `homeworkId` (`homeworkId`),
+  KEY `userId` (`userId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE `homework_estimates` (
+  `homeworkId` int NOT NULL,
+  `minutes` int NOT NULL,
+  `userId` int NOT NULL,
+  PRIMARY KEY (`homeworkId`),
+  KEY `userId` (`userId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,4 @@
+-- Description: Add digest settings
+-- Down migration
+
+DROP TABLE IF EXISTS `digest_settings`;
@@ -0,0 +1,16 @@
+-- Description: Add digest settings
+-- Up migration
+
+CREATE TABLE `digest_settings` (
+  `userId` int NOT NULL,
+  `daily` tinyint NOT NULL DEFAULT 0,
+  `weekly` tinyint NOT NULL DEFAULT 0,
+  `weeklyDay` tinyint NOT NULL DEFAULT 0,
+  `sendTime` int NOT NULL,
+  `timezone` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `unsubscribeToken` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `lastDailySent` date DEFAULT NULL,
+  `lastWeeklySent` date DEFAULT NULL,
+  PRIMARY KEY (`userId`),
+  UNIQUE KEY `unsubscribeToken` (`unsubscribeToken`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
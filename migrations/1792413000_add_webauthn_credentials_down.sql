@@ -0,0 +1,4 @@
+-- Description: Add WebAuthn credentials
+-- Down migration
+
+DROP TABLE IF EXISTS `webauthn_credentials`;
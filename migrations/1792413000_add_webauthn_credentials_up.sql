@@ -0,0 +1,18 @@
+-- Description: Add WebAuthn credentials
+-- Up migration
+
+CREATE TABLE `webauthn_credentials` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `name` varchar(100) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `credentialId` varbinary(1023) NOT NULL,
+  `publicKey` blob NOT NULL,
+  `attestationType` varchar(32) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `aaguid` varbinary(16) NOT NULL,
+  `signCount` int unsigned NOT NULL DEFAULT 0,
+  `createdAt` int NOT NULL,
+  `lastUsedAt` int DEFAULT NULL,
+  `userId` int NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `credentialId` (`credentialId`),
+  KEY `userId` (`userId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
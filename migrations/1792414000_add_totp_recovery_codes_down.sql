@@ -0,0 +1,4 @@
+-- Description: Add TOTP recovery codes
+-- Down migration
+
+DROP TABLE IF EXISTS `totp_recovery_codes`;
@@ -0,0 +1,12 @@
+-- Description: Add TOTP recovery codes
+-- Up migration
+
+CREATE TABLE `totp_recovery_codes` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `codeHash` char(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `createdAt` int NOT NULL,
+  `usedAt` int DEFAULT NULL,
+  `userId` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `userId` (`userId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
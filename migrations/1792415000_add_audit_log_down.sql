@@ -0,0 +1,4 @@
+-- Description: Add security audit log
+-- Down migration
+
+DROP TABLE IF EXISTS `audit_log`;
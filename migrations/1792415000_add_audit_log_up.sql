@@ -0,0 +1,16 @@
+-- Description: Add security audit log
+-- Up migration
+
+CREATE TABLE `audit_log` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `userId` int NOT NULL,
+  `event` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `details` text COLLATE utf8mb4_unicode_ci NOT NULL,
+  `ipAddress` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `userAgent` varchar(512) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `timestamp` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `userId` (`userId`, `id`),
+  KEY `event` (`event`, `id`),
+  KEY `ipAddress` (`ipAddress`, `id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
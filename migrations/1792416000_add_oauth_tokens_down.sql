@@ -0,0 +1,6 @@
+-- Description: Add OAuth client secrets and tokens
+-- Down migration
+
+DROP TABLE IF EXISTS `oauth_tokens`;
+
+ALTER TABLE `applications` DROP COLUMN `clientSecretHash`;
@@ -0,0 +1,18 @@
+-- Description: Add OAuth client secrets and tokens
+-- Up migration
+
+ALTER TABLE `applications` ADD COLUMN `clientSecretHash` char(64) COLLATE utf8mb4_unicode_ci DEFAULT NULL;
+
+CREATE TABLE `oauth_tokens` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `type` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `tokenHash` char(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `parentId` int DEFAULT NULL,
+  `createdAt` int NOT NULL,
+  `expiresAt` int NOT NULL,
+  `authorizationId` int NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `tokenHash` (`tokenHash`),
+  KEY `parentId` (`parentId`),
+  KEY `authorizationId` (`authorizationId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,5 @@
+-- Description: Add scopes to applications and authorizations
+-- Down migration
+
+ALTER TABLE `application_authorizations` DROP COLUMN `scopes`;
+ALTER TABLE `applications` DROP COLUMN `scopes`;
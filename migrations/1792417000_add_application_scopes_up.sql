@@ -0,0 +1,9 @@
+-- Description: Add scopes to applications and authorizations
+-- Up migration
+
+ALTER TABLE `applications` ADD COLUMN `scopes` varchar(512) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '';
+ALTER TABLE `application_authorizations` ADD COLUMN `scopes` varchar(512) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '';
+
+-- applications from before scopes existed keep access to everything but the account itself
+UPDATE `applications` SET `scopes` = 'homework:read homework:write calendar:read calendar:write classes:read classes:write grades:read grades:write prefs';
+UPDATE `application_authorizations` SET `scopes` = 'homework:read homework:write calendar:read calendar:write classes:read classes:write grades:read grades:write prefs';
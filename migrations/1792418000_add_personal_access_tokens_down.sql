@@ -0,0 +1,4 @@
+-- Description: Add personal access tokens
+-- Down migration
+
+DROP TABLE IF EXISTS `personal_access_tokens`;
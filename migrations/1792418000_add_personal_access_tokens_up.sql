@@ -0,0 +1,16 @@
+-- Description: Add personal access tokens
+-- Up migration
+
+CREATE TABLE `personal_access_tokens` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `name` varchar(100) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `tokenHash` char(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `scopes` varchar(512) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `createdAt` int NOT NULL,
+  `expiresAt` int DEFAULT NULL,
+  `lastUsedAt` int DEFAULT NULL,
+  `userId` int NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `tokenHash` (`tokenHash`),
+  KEY `userId` (`userId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,10 @@
+-- Description: Add redirect URIs, logos, descriptions and secret rotation to applications
+-- Down migration
+
+DROP TABLE IF EXISTS `application_redirect_uris`;
+
+ALTER TABLE `applications`
+  DROP COLUMN `description`,
+  DROP COLUMN `logo`,
+  DROP COLUMN `previousClientSecretHash`,
+  DROP COLUMN `previousClientSecretExpiresAt`;
@@ -0,0 +1,19 @@
+-- Description: Add redirect URIs, logos, descriptions and secret rotation to applications
+-- Up migration
+
+ALTER TABLE `applications`
+  ADD COLUMN `description` text COLLATE utf8mb4_unicode_ci,
+  ADD COLUMN `logo` mediumblob,
+  ADD COLUMN `previousClientSecretHash` char(64) COLLATE utf8mb4_unicode_ci DEFAULT NULL,
+  ADD COLUMN `previousClientSecretExpiresAt` int DEFAULT NULL;
+
+CREATE TABLE `application_redirect_uris` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `uri` varchar(2048) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `applicationId` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `applicationId` (`applicationId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- the old callback url becomes the first redirect uri
+INSERT INTO `application_redirect_uris`(`uri`, `applicationId`) SELECT `callbackUrl`, `id` FROM `applications` WHERE `callbackUrl` IS NOT NULL AND `callbackUrl` != '';
@@ -0,0 +1,5 @@
+-- Description: Add webhooks and their delivery log
+-- Down migration
+
+DROP TABLE IF EXISTS `webhook_deliveries`;
+DROP TABLE IF EXISTS `webhooks`;
@@ -0,0 +1,36 @@
+-- Description: Add webhooks and their delivery log
+-- Up migration
+
+CREATE TABLE `webhooks` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `url` varchar(2048) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `secret` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `events` varchar(1024) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `enabled` tinyint(1) NOT NULL DEFAULT 1,
+  `createdAt` int NOT NULL,
+  `applicationId` int DEFAULT NULL,
+  `userId` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `userId` (`userId`),
+  KEY `applicationId` (`applicationId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE `webhook_deliveries` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `eventId` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `event` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `payload` mediumtext COLLATE utf8mb4_unicode_ci NOT NULL,
+  `status` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `attempts` int NOT NULL DEFAULT 0,
+  `nextAttemptAt` int DEFAULT NULL,
+  `lastAttemptAt` int DEFAULT NULL,
+  `responseStatus` int DEFAULT NULL,
+  `responseBody` text COLLATE utf8mb4_unicode_ci,
+  `error` text COLLATE utf8mb4_unicode_ci,
+  `createdAt` int NOT NULL,
+  `webhookId` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `webhookId` (`webhookId`),
+  KEY `nextAttemptAt` (`nextAttemptAt`),
+  KEY `createdAt` (`createdAt`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,5 @@
+-- Description: Add names to prefixes
+-- Down migration
+
+ALTER TABLE `prefixes`
+DROP COLUMN `name`;
@@ -0,0 +1,5 @@
+-- Description: Add names to prefixes
+-- Up migration
+
+ALTER TABLE `prefixes`
+ADD `name` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' AFTER `id`;
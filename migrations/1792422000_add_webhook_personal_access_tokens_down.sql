@@ -0,0 +1,6 @@
+-- Description: Record the personal access token that made each webhook
+-- Down migration
+
+ALTER TABLE `webhooks`
+DROP KEY `personalAccessTokenId`,
+DROP COLUMN `personalAccessTokenId`;
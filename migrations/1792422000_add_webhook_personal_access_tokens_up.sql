@@ -0,0 +1,6 @@
+-- Description: Record the personal access token that made each webhook
+-- Up migration
+
+ALTER TABLE `webhooks`
+ADD `personalAccessTokenId` int DEFAULT NULL AFTER `applicationId`,
+ADD KEY `personalAccessTokenId` (`personalAccessTokenId`);
@@ -128,6 +128,31 @@ func (s *school) Unenroll(tx *sql.Tx, user *data.User) error {
 	return clearUserData(tx, user)
 }
 
+func (s *school) Classes(tx *sql.Tx, user *data.User) ([]data.SchoolClass, error) {
+	rows, err := tx.Query("SELECT department, number, MIN(instructorName) FROM columbia_classes WHERE userID = ? GROUP BY department, number ORDER BY department ASC, number ASC", user.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	classes := []data.SchoolClass{}
+	for rows.Next() {
+		department, number, instructorName := "", "", ""
+		err = rows.Scan(&department, &number, &instructorName)
+		if err != nil {
+			return nil, err
+		}
+
+		classes = append(classes, data.SchoolClass{
+			SourceID: department + "-" + number,
+			Name:     department + " " + number,
+			Teacher:  instructorName,
+		})
+	}
+
+	return classes, nil
+}
+
 func (s *school) NeedsUpdate(db *sql.DB) (bool, error) {
 	return (s.importStatus != schools.ImportStatusOK), nil
 }
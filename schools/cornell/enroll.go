@@ -305,6 +305,31 @@ func (s *school) Unenroll(tx *sql.Tx, user *data.User) error {
 	return clearUserData(tx, user)
 }
 
+func (s *school) Classes(tx *sql.Tx, user *data.User) ([]data.SchoolClass, error) {
+	rows, err := tx.Query("SELECT DISTINCT subject, catalogNum FROM cornell_courses WHERE userId = ? AND subject IS NOT NULL AND catalogNum IS NOT NULL ORDER BY subject ASC, catalogNum ASC", user.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	classes := []data.SchoolClass{}
+	for rows.Next() {
+		subject := ""
+		catalogNum := 0
+		err = rows.Scan(&subject, &catalogNum)
+		if err != nil {
+			return nil, err
+		}
+
+		classes = append(classes, data.SchoolClass{
+			SourceID: subject + strconv.Itoa(catalogNum),
+			Name:     subject + " " + strconv.Itoa(catalogNum),
+		})
+	}
+
+	return classes, nil
+}
+
 func clearUserData(tx *sql.Tx, user *data.User) error {
 	// clear away anything that is in the db
 	_, err := tx.Exec("DELETE FROM cornell_courses WHERE userId = ?", user.ID)
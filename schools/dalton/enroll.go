@@ -458,6 +458,33 @@ func (s *school) Unenroll(tx *sql.Tx, user *data.User) error {
 	return clearUserData(tx, user)
 }
 
+func (s *school) Classes(tx *sql.Tx, user *data.User) ([]data.SchoolClass, error) {
+	// a class shows up once for each term it runs in
+	rows, err := tx.Query("SELECT sectionId, IFNULL(MIN(name), ''), IFNULL(MIN(ownerName), '') FROM dalton_classes WHERE userId = ? AND sectionId IS NOT NULL GROUP BY sectionId ORDER BY MIN(name) ASC", user.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	classes := []data.SchoolClass{}
+	for rows.Next() {
+		sectionID := 0
+		name, ownerName := "", ""
+		err = rows.Scan(&sectionID, &name, &ownerName)
+		if err != nil {
+			return nil, err
+		}
+
+		classes = append(classes, data.SchoolClass{
+			SourceID: strconv.Itoa(sectionID),
+			Name:     name,
+			Teacher:  ownerName,
+		})
+	}
+
+	return classes, nil
+}
+
 func (s *school) NeedsUpdate(db *sql.DB) (bool, error) {
 	return (s.importStatus != schools.ImportStatusOK), nil
 }
@@ -212,6 +212,30 @@ func (s *school) Unenroll(tx *sql.Tx, user *data.User) error {
 	return clearUserData(tx, user)
 }
 
+func (s *school) Classes(tx *sql.Tx, user *data.User) ([]data.SchoolClass, error) {
+	rows, err := tx.Query("SELECT DISTINCT subjectID FROM mit_classes WHERE userID = ? ORDER BY subjectID ASC", user.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	classes := []data.SchoolClass{}
+	for rows.Next() {
+		subjectID := ""
+		err = rows.Scan(&subjectID)
+		if err != nil {
+			return nil, err
+		}
+
+		classes = append(classes, data.SchoolClass{
+			SourceID: subjectID,
+			Name:     subjectID,
+		})
+	}
+
+	return classes, nil
+}
+
 func (s *school) NeedsUpdate(db *sql.DB) (bool, error) {
 	return (s.importStatus != schools.ImportStatusOK), nil
 }
@@ -0,0 +1,161 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+)
+
+// matches in the name count for more than matches in the description
+const memoryNameWeight = 2
+
+// A MemoryIndex keeps homework in memory and searches through it directly. It's meant for tests.
+type MemoryIndex struct {
+	homework map[int]data.Homework
+}
+
+// NewMemoryIndex creates a MemoryIndex containing the given homework.
+func NewMemoryIndex(homework ...data.Homework) *MemoryIndex {
+	index := &MemoryIndex{map[int]data.Homework{}}
+	index.Add(homework...)
+	return index
+}
+
+// Add puts the given homework into the index, replacing any existing items with the same IDs.
+func (index *MemoryIndex) Add(homework ...data.Homework) {
+	for _, item := range homework {
+		index.homework[item.ID] = item
+	}
+}
+
+// Remove takes the homework with the given ID out of the index.
+func (index *MemoryIndex) Remove(id int) {
+	delete(index.homework, id)
+}
+
+func countMatches(text string, token string) int {
+	count := 0
+	for _, word := range Tokenize(text) {
+		if strings.HasPrefix(word, token) {
+			count++
+		}
+	}
+	return count
+}
+
+func (index *MemoryIndex) matchesFilters(query Query, homework data.Homework) bool {
+	if homework.UserID != query.UserID {
+		return false
+	}
+
+	if len(query.ClassIDs) > 0 {
+		found := false
+		for _, classID := range query.ClassIDs {
+			if homework.ClassID == classID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if query.DueStart != "" && homework.Due < query.DueStart {
+		return false
+	}
+	if query.DueEnd != "" && homework.Due > query.DueEnd {
+		return false
+	}
+
+	if query.Complete != nil && (homework.Complete == 1) != *query.Complete {
+		return false
+	}
+
+	if len(query.PrefixWords) > 0 {
+		firstWord := strings.SplitN(strings.TrimSpace(homework.Name), " ", 2)[0]
+		found := false
+		for _, word := range query.PrefixWords {
+			if strings.EqualFold(word, firstWord) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Search finds homework matching the given Query.
+func (index *MemoryIndex) Search(query Query) ([]Result, error) {
+	candidates := []data.Homework{}
+	for _, homework := range index.homework {
+		if index.matchesFilters(query, homework) {
+			candidates = append(candidates, homework)
+		}
+	}
+
+	tokens := Tokenize(query.Text)
+
+	// words that show up in fewer items are worth more
+	weights := map[string]float64{}
+	for _, token := range tokens {
+		itemsWithToken := 0
+		for _, homework := range candidates {
+			if countMatches(homework.Name, token)+countMatches(homework.Desc, token) > 0 {
+				itemsWithToken++
+			}
+		}
+		weights[token] = math.Log(1 + float64(len(candidates))/float64(itemsWithToken+1))
+	}
+
+	results := []Result{}
+	for _, homework := range candidates {
+		score := 0.0
+		matchesAll := true
+		for _, token := range tokens {
+			count := memoryNameWeight*countMatches(homework.Name, token) + countMatches(homework.Desc, token)
+			if count == 0 {
+				matchesAll = false
+				break
+			}
+			score += float64(count) * weights[token]
+		}
+		if !matchesAll {
+			continue
+		}
+
+		result := Result{Homework: homework, Score: score}
+		if query.Cursor != nil && !resultAfterCursor(result, *query.Cursor) {
+			continue
+		}
+
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return resultAfterCursor(results[j], Cursor{results[i].Score, results[i].Homework.Due, results[i].Homework.ID})
+	})
+
+	if query.Limit > 0 && len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+
+	return results, nil
+}
+
+// resultAfterCursor checks if the given result comes after the cursor, in the order that an Index returns results.
+func resultAfterCursor(result Result, cursor Cursor) bool {
+	if result.Score != cursor.Score {
+		return result.Score < cursor.Score
+	}
+	if result.Homework.Due != cursor.Due {
+		return result.Homework.Due < cursor.Due
+	}
+	return result.Homework.ID < cursor.ID
+}
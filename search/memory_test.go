@@ -0,0 +1,140 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+)
+
+var testHomework = []data.Homework{
+	{ID: 1, Name: "HW Chapter 5 problems", Due: "2020-10-01", Desc: "Odd problems only", Complete: 1, ClassID: 1, UserID: 1},
+	{ID: 2, Name: "Read chapter 6", Due: "2020-10-02", Desc: "", Complete: 0, ClassID: 2, UserID: 1},
+	{ID: 3, Name: "Test on chapters 5 and 6", Due: "2020-10-05", Desc: "Covers the chapter 5 problems and the chapter 6 reading", Complete: 0, ClassID: 1, UserID: 1},
+	{ID: 4, Name: "HW Chapter 5 problems", Due: "2020-10-01", Desc: "", Complete: 0, ClassID: 1, UserID: 2},
+	{ID: 5, Name: "Essay draft", Due: "2020-10-03", Desc: "First draft of the chapter essay", Complete: 0, ClassID: 2, UserID: 1},
+}
+
+func resultIDs(results []Result) []int {
+	ids := []int{}
+	for _, result := range results {
+		ids = append(ids, result.Homework.ID)
+	}
+	return ids
+}
+
+func compareIDs(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := 0; i < len(a); i++ {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func testSearch(t *testing.T, name string, query Query, expectedIDs []int) {
+	index := NewMemoryIndex(testHomework...)
+
+	page, err := Search(index, query)
+	if err != nil {
+		t.Errorf("%s: got error '%s'", name, err.Error())
+		return
+	}
+
+	ids := resultIDs(page.Results)
+	if !compareIDs(ids, expectedIDs) {
+		t.Errorf("%s: got %v, expected %v", name, ids, expectedIDs)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tokens := Tokenize("HW: Chapter 5, problems 1-10 (odd)")
+	expected := []string{"hw", "chapter", "5", "problems", "1", "10", "odd"}
+	if len(tokens) != len(expected) {
+		t.Fatalf("Tokenize: got %#v, expected %#v", tokens, expected)
+	}
+	for i := range tokens {
+		if tokens[i] != expected[i] {
+			t.Errorf("Tokenize: got %#v, expected %#v", tokens, expected)
+			break
+		}
+	}
+}
+
+func TestMemoryIndexSearch(t *testing.T) {
+	complete := true
+	incomplete := false
+
+	testSearch(t, "single word", Query{UserID: 1, Text: "problems"}, []int{1, 3})
+	testSearch(t, "all words required", Query{UserID: 1, Text: "chapter essay"}, []int{5})
+	testSearch(t, "word prefix", Query{UserID: 1, Text: "chap"}, []int{3, 2, 1, 5})
+	testSearch(t, "no text", Query{UserID: 1}, []int{3, 5, 2, 1})
+	testSearch(t, "other user", Query{UserID: 2, Text: "problems"}, []int{4})
+	testSearch(t, "class filter", Query{UserID: 1, Text: "chapter", ClassIDs: []int{2}}, []int{2, 5})
+	testSearch(t, "date filter", Query{UserID: 1, DueStart: "2020-10-02", DueEnd: "2020-10-03"}, []int{5, 2})
+	testSearch(t, "complete filter", Query{UserID: 1, Complete: &complete}, []int{1})
+	testSearch(t, "incomplete filter", Query{UserID: 1, Complete: &incomplete}, []int{3, 5, 2})
+	testSearch(t, "prefix filter", Query{UserID: 1, PrefixWords: []string{"hw", "read"}}, []int{2, 1})
+}
+
+func TestMemoryIndexPagination(t *testing.T) {
+	index := NewMemoryIndex(testHomework...)
+
+	ids := []int{}
+	cursor := (*Cursor)(nil)
+	for pages := 0; pages < 10; pages++ {
+		page, err := Search(index, Query{UserID: 1, Text: "chapter", Cursor: cursor, Limit: 1})
+		if err != nil {
+			t.Fatalf("Search: got error '%s'", err.Error())
+		}
+
+		ids = append(ids, resultIDs(page.Results)...)
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		nextCursor, err := DecodeCursor(page.NextCursor)
+		if err != nil {
+			t.Fatalf("DecodeCursor('%s'): got error '%s'", page.NextCursor, err.Error())
+		}
+		cursor = &nextCursor
+	}
+
+	expected := []int{3, 2, 1, 5}
+	if !compareIDs(ids, expected) {
+		t.Errorf("paginated search: got %v, expected %v", ids, expected)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	snippet, found := highlight("name", "HW Chapter 5 problems", []string{"chap", "5"})
+	if !found {
+		t.Fatalf("highlight: expected a match")
+	}
+
+	expected := []Fragment{
+		{"HW ", false},
+		{"Chapter", true},
+		{" ", false},
+		{"5", true},
+		{" problems", false},
+	}
+	if len(snippet.Fragments) != len(expected) {
+		t.Fatalf("highlight: got %#v, expected %#v", snippet.Fragments, expected)
+	}
+	for i := range expected {
+		if snippet.Fragments[i] != expected[i] {
+			t.Errorf("highlight: fragment %d: got %#v, expected %#v", i, snippet.Fragments[i], expected[i])
+		}
+	}
+
+	_, found = highlight("desc", "Nothing to see here", []string{"chapter"})
+	if found {
+		t.Errorf("highlight: expected no match")
+	}
+}
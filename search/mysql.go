@@ -0,0 +1,135 @@
+package search
+
+import (
+	"database/sql"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/MyHomeworkSpace/api-server/util"
+)
+
+// mysqlMinTokenLength is the shortest word that InnoDB's FULLTEXT index will store, by default.
+// Shorter tokens have to be matched without the index.
+const mysqlMinTokenLength = 3
+
+// A MySQLIndex searches homework using a FULLTEXT index on the homework table.
+type MySQLIndex struct {
+	db *sql.DB
+}
+
+// NewMySQLIndex creates a MySQLIndex that uses the given database.
+func NewMySQLIndex(db *sql.DB) *MySQLIndex {
+	return &MySQLIndex{db}
+}
+
+func placeholders(count int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", count), ", ")
+}
+
+// Search finds homework matching the given Query.
+func (index *MySQLIndex) Search(query Query) ([]Result, error) {
+	scoreExpression := "0"
+	scoreArgs := []interface{}{}
+
+	where := "userId = ? AND trashId IS NULL"
+	whereArgs := []interface{}{query.UserID}
+
+	indexedTerms := []string{}
+	for _, token := range Tokenize(query.Text) {
+		if utf8.RuneCountInString(token) < mysqlMinTokenLength {
+			// the index won't have this, so look for a word starting with it instead
+			where += " AND CONCAT_WS(' ', '', name, `desc`) LIKE ?"
+			whereArgs = append(whereArgs, "% "+util.EscapeLike(token)+"%")
+			continue
+		}
+
+		// require every word, and allow it to be the start of a longer one
+		indexedTerms = append(indexedTerms, "+"+token+"*")
+	}
+	if len(indexedTerms) > 0 {
+		booleanQuery := strings.Join(indexedTerms, " ")
+
+		scoreExpression = "MATCH(name, `desc`) AGAINST(? IN BOOLEAN MODE)"
+		scoreArgs = append(scoreArgs, booleanQuery)
+
+		where += " AND MATCH(name, `desc`) AGAINST(? IN BOOLEAN MODE)"
+		whereArgs = append(whereArgs, booleanQuery)
+	}
+
+	if len(query.ClassIDs) > 0 {
+		where += " AND classId IN (" + placeholders(len(query.ClassIDs)) + ")"
+		for _, classID := range query.ClassIDs {
+			whereArgs = append(whereArgs, classID)
+		}
+	}
+	if query.DueStart != "" {
+		where += " AND `due` >= ?"
+		whereArgs = append(whereArgs, query.DueStart)
+	}
+	if query.DueEnd != "" {
+		where += " AND `due` <= ?"
+		whereArgs = append(whereArgs, query.DueEnd)
+	}
+	if query.Complete != nil {
+		if *query.Complete {
+			where += " AND `complete` = 1"
+		} else {
+			where += " AND `complete` = 0"
+		}
+	}
+	if len(query.PrefixWords) > 0 {
+		// the collation is case-insensitive, so this doesn't need to worry about that
+		where += " AND SUBSTRING_INDEX(TRIM(name), ' ', 1) IN (" + placeholders(len(query.PrefixWords)) + ")"
+		for _, word := range query.PrefixWords {
+			whereArgs = append(whereArgs, word)
+		}
+	}
+
+	cursorWhere := ""
+	cursorArgs := []interface{}{}
+	if query.Cursor != nil {
+		cursorWhere = "WHERE score < ? OR (score = ? AND `due` < ?) OR (score = ? AND `due` = ? AND id < ?)"
+		cursorArgs = append(
+			cursorArgs,
+			query.Cursor.Score,
+			query.Cursor.Score, query.Cursor.Due,
+			query.Cursor.Score, query.Cursor.Due, query.Cursor.ID,
+		)
+	}
+
+	// the score is calculated in a subquery so that the cursor can refer to it
+	args := append(scoreArgs, whereArgs...)
+	args = append(args, cursorArgs...)
+	args = append(args, query.Limit)
+	rows, err := index.db.Query(
+		"SELECT id, name, `due`, `desc`, `complete`, classId, userId, score FROM ("+
+			"SELECT id, name, `due`, `desc`, `complete`, classId, userId, "+scoreExpression+" AS score FROM homework WHERE "+where+
+			") AS results "+cursorWhere+" ORDER BY score DESC, `due` DESC, id DESC LIMIT ?",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []Result{}
+	for rows.Next() {
+		result := Result{}
+		err = rows.Scan(
+			&result.Homework.ID,
+			&result.Homework.Name,
+			&result.Homework.Due,
+			&result.Homework.Desc,
+			&result.Homework.Complete,
+			&result.Homework.ClassID,
+			&result.Homework.UserID,
+			&result.Score,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
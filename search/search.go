@@ -0,0 +1,152 @@
+package search
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+)
+
+// DefaultLimit is the number of results returned in a page if no limit is given.
+const DefaultLimit = 25
+
+// MaxLimit is the largest number of results that can be returned in a single page.
+const MaxLimit = 100
+
+// ErrInvalidCursor is returned when a cursor can't be decoded.
+var ErrInvalidCursor = errors.New("search: invalid cursor")
+
+// A Query describes a search over a user's homework. Any filters left empty are not applied.
+type Query struct {
+	UserID int
+
+	// Text is matched against the name and description of each homework item. Every token in it must match.
+	Text string
+
+	ClassIDs    []int
+	DueStart    string // inclusive, in the form 2006-01-02
+	DueEnd      string // inclusive, in the form 2006-01-02
+	Complete    *bool
+	PrefixWords []string // the first word of the homework's name must be one of these
+
+	Cursor *Cursor
+	Limit  int
+}
+
+// A Cursor marks the position of the last result in a page, so that the next page can start after it.
+type Cursor struct {
+	Score float64 `json:"s"`
+	Due   string  `json:"d"`
+	ID    int     `json:"i"`
+}
+
+// A Fragment is a piece of a Snippet, which is either highlighted or not.
+type Fragment struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match"`
+}
+
+// A Snippet is an excerpt of a homework field, split into Fragments so that the matching words can be highlighted.
+type Snippet struct {
+	Field     string     `json:"field"`
+	Fragments []Fragment `json:"fragments"`
+}
+
+// A Result is a single homework item that matched a Query.
+type Result struct {
+	Homework data.Homework `json:"homework"`
+	Score    float64       `json:"score"`
+	Snippets []Snippet     `json:"snippets"`
+}
+
+// A Page is a set of Results, along with the cursor needed to get the next page.
+// NextCursor is empty if there are no more results.
+type Page struct {
+	Results    []Result
+	NextCursor string
+}
+
+// An Index is something that can find homework matching a Query.
+// Results must be ordered by score, then due date, then ID, all descending, and must start after the Query's Cursor.
+// An Index does not need to fill in Snippets.
+type Index interface {
+	Search(query Query) ([]Result, error)
+}
+
+// Tokenize splits the given text into lowercased words, ignoring punctuation.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// EncodeCursor turns the given Cursor into an opaque string that can be given to clients.
+func EncodeCursor(cursor Cursor) string {
+	cursorJSON, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(cursorJSON)
+}
+
+// DecodeCursor parses a string created by EncodeCursor.
+func DecodeCursor(cursorString string) (Cursor, error) {
+	cursorJSON, err := base64.RawURLEncoding.DecodeString(cursorString)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	cursor := Cursor{}
+	err = json.Unmarshal(cursorJSON, &cursor)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// Search runs the given Query against the given Index, and returns a single page of results with highlighted snippets.
+func Search(index Index, query Query) (Page, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultLimit
+	} else if query.Limit > MaxLimit {
+		query.Limit = MaxLimit
+	}
+
+	limit := query.Limit
+
+	// ask for one extra, so we know if there's another page
+	query.Limit = limit + 1
+
+	results, err := index.Search(query)
+	if err != nil {
+		return Page{}, err
+	}
+
+	page := Page{
+		Results: []Result{},
+	}
+
+	if len(results) > limit {
+		results = results[:limit]
+		last := results[limit-1]
+		page.NextCursor = EncodeCursor(Cursor{last.Score, last.Homework.Due, last.Homework.ID})
+	}
+
+	tokens := Tokenize(query.Text)
+	for _, result := range results {
+		result.Snippets = []Snippet{}
+		if len(tokens) > 0 {
+			if nameSnippet, found := highlight("name", result.Homework.Name, tokens); found {
+				result.Snippets = append(result.Snippets, nameSnippet)
+			}
+			if descSnippet, found := highlight("desc", result.Homework.Desc, tokens); found {
+				result.Snippets = append(result.Snippets, descSnippet)
+			}
+		}
+
+		page.Results = append(page.Results, result)
+	}
+
+	return page, nil
+}
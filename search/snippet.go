@@ -0,0 +1,114 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// how many characters of context to show before the first match in a snippet
+const snippetContextLength = 60
+
+// the longest a snippet can be, not counting the ellipses
+const snippetMaxLength = 200
+
+type span struct {
+	start int
+	end   int
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// wordMatches checks if the given word starts with any of the tokens, which is how both indices match words.
+func wordMatches(word string, tokens []string) bool {
+	word = strings.ToLower(word)
+	for _, token := range tokens {
+		if strings.HasPrefix(word, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// findMatches returns the positions, in runes, of every word in the text that matches one of the tokens.
+func findMatches(text []rune, tokens []string) []span {
+	matches := []span{}
+	wordStart := -1
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) && isWordRune(text[i]) {
+			if wordStart == -1 {
+				wordStart = i
+			}
+			continue
+		}
+
+		if wordStart != -1 {
+			if wordMatches(string(text[wordStart:i]), tokens) {
+				matches = append(matches, span{wordStart, i})
+			}
+			wordStart = -1
+		}
+	}
+	return matches
+}
+
+// highlight builds a Snippet of the given text around the words that match the tokens.
+// It returns false if nothing in the text matches.
+func highlight(field string, text string, tokens []string) (Snippet, bool) {
+	runes := []rune(text)
+	matches := findMatches(runes, tokens)
+	if len(matches) == 0 {
+		return Snippet{}, false
+	}
+
+	// figure out what part of the text to show
+	windowStart := 0
+	if matches[0].start > snippetContextLength {
+		windowStart = matches[0].start - snippetContextLength
+
+		// try not to start in the middle of a word
+		for i := windowStart; i < matches[0].start; i++ {
+			if unicode.IsSpace(runes[i]) {
+				windowStart = i + 1
+				break
+			}
+		}
+	}
+	windowEnd := len(runes)
+	if windowEnd-windowStart > snippetMaxLength {
+		windowEnd = windowStart + snippetMaxLength
+	}
+
+	fragments := []Fragment{}
+	position := windowStart
+	for _, match := range matches {
+		if match.start >= windowEnd {
+			break
+		}
+
+		if match.start > position {
+			fragments = append(fragments, Fragment{string(runes[position:match.start]), false})
+		}
+
+		end := match.end
+		if end > windowEnd {
+			end = windowEnd
+		}
+		fragments = append(fragments, Fragment{string(runes[match.start:end]), true})
+		position = end
+	}
+	if position < windowEnd {
+		fragments = append(fragments, Fragment{string(runes[position:windowEnd]), false})
+	}
+
+	// show that the text was cut off
+	if windowStart > 0 {
+		fragments = append([]Fragment{{"…", false}}, fragments...)
+	}
+	if windowEnd < len(runes) {
+		fragments = append(fragments, Fragment{"…", false})
+	}
+
+	return Snippet{field, fragments}, true
+}
@@ -0,0 +1,240 @@
+package tasks
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/calendar"
+	"github.com/MyHomeworkSpace/api-server/config"
+	"github.com/MyHomeworkSpace/api-server/data"
+	"github.com/MyHomeworkSpace/api-server/email"
+	"github.com/MyHomeworkSpace/api-server/errorlog"
+	"github.com/MyHomeworkSpace/api-server/util"
+)
+
+// how long after a user's chosen time their digest can still be sent, in case the task didn't run right on time
+const digestSendWindow = 2 * time.Hour
+
+type digestItem struct {
+	Name      string
+	ClassName string
+	Due       string
+}
+
+type digestEvent struct {
+	Time     string
+	Name     string
+	Location string
+}
+
+// StartDigestSend begins sending daily and weekly digest emails to everyone whose chosen time has come.
+// It's meant to be run often, like every 15 minutes, and only sends each digest once.
+func StartDigestSend(db *sql.DB) error {
+	go taskWatcher("digest_send", "Digest emails", digestSend, "", db)
+	return nil
+}
+
+func getDigestItems(homework []data.Homework, classNames map[int]string) []digestItem {
+	items := []digestItem{}
+	for _, item := range homework {
+		if item.Complete != 0 {
+			continue
+		}
+
+		due := item.Due
+		dueDate, err := time.Parse("2006-01-02", item.Due)
+		if err == nil {
+			due = dueDate.Format("Monday, January 2")
+		}
+
+		items = append(items, digestItem{item.Name, classNames[item.ClassID], due})
+	}
+	return items
+}
+
+// buildDigest gets everything that goes in the given user's digest, and whether there's anything worth sending.
+func buildDigest(db *sql.DB, user *data.User, location *time.Location, now time.Time, weekly bool) (map[string]interface{}, bool, error) {
+	hiddenClasses, err := data.GetHiddenClassesForUser(user.ID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	classes, err := data.GetClassesForUser(user)
+	if err != nil {
+		return nil, false, err
+	}
+
+	classNames := map[int]string{}
+	for _, class := range classes {
+		classNames[class.ID] = class.Name
+	}
+
+	// this matches what the homework page shows, which leaves out archived classes
+	rows, err := db.Query("SELECT homework.id, homework.name, homework.`due`, homework.`desc`, homework.`complete`, homework.classId, homework.userId FROM homework INNER JOIN classes ON homework.classId = classes.id WHERE homework.userId = ? AND homework.trashId IS NULL AND classes.archived = 0 AND (homework.`due` > (NOW() - INTERVAL 3 DAY) OR homework.`complete` != '1') ORDER BY homework.`due` ASC", user.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	homework := []data.Homework{}
+	for rows.Next() {
+		resp := data.Homework{}
+		err = rows.Scan(&resp.ID, &resp.Name, &resp.Due, &resp.Desc, &resp.Complete, &resp.ClassID, &resp.UserID)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if util.IntSliceContains(hiddenClasses, resp.ClassID) {
+			continue
+		}
+
+		homework = append(homework, resp)
+	}
+
+	view, err := data.SortHomeworkView(homework, now, true)
+	if err != nil {
+		return nil, false, err
+	}
+
+	upcoming := view.Soon
+	if weekly {
+		// a weekly digest has to cover the whole week
+		weekEnd := now.AddDate(0, 0, 7).Format("2006-01-02")
+		for _, item := range view.Longterm {
+			if item.Due <= weekEnd {
+				upcoming = append(upcoming, item)
+			}
+		}
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	calendarView, err := calendar.GetView(db, user, location, today, today.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, false, err
+	}
+
+	schedule := []digestEvent{}
+	for _, day := range calendarView.Days {
+		for _, event := range day.Events {
+			if cancelled, ok := event.Tags[data.EventTagCancelled].(bool); ok && cancelled {
+				continue
+			}
+
+			eventLocation, _ := event.Tags[data.EventTagLocation].(string)
+			schedule = append(schedule, digestEvent{
+				time.Unix(int64(event.Start), 0).In(location).Format("3:04 PM"),
+				event.Name,
+				eventLocation,
+			})
+		}
+	}
+
+	overdueItems := getDigestItems(view.Overdue, classNames)
+	todayItems := getDigestItems(view.Today, classNames)
+	tomorrowItems := getDigestItems(view.Tomorrow, classNames)
+	upcomingItems := getDigestItems(upcoming, classNames)
+
+	hasContent := len(overdueItems) > 0 || len(todayItems) > 0 || len(tomorrowItems) > 0 || len(upcomingItems) > 0 || len(schedule) > 0
+
+	return map[string]interface{}{
+		"weekly":       weekly,
+		"date":         now.Format("Monday, January 2"),
+		"tomorrowName": view.TomorrowName,
+		"overdue":      overdueItems,
+		"today":        todayItems,
+		"tomorrow":     tomorrowItems,
+		"upcoming":     upcomingItems,
+		"schedule":     schedule,
+		"appURL":       config.GetCurrent().Server.AppURLBase,
+	}, hasContent, nil
+}
+
+// sendDigest builds and sends the given user's digest, unless there's nothing in it. It returns whether an email was sent.
+func sendDigest(db *sql.DB, settings data.DigestSettings, location *time.Location, localNow time.Time, weekly bool) (bool, error) {
+	user, err := data.GetUserByID(settings.UserID)
+	if err != nil {
+		return false, err
+	}
+
+	digest, hasContent, err := buildDigest(db, &user, location, localNow, weekly)
+	if err != nil {
+		return false, err
+	}
+
+	// don't bother people with an empty email
+	if !hasContent {
+		return false, nil
+	}
+
+	unsubscribeURL := config.GetCurrent().Server.APIURLBase + "digest/unsubscribe/" + settings.UnsubscribeToken
+	digest["unsubscribeURL"] = unsubscribeURL
+
+	err = email.SendWithUnsubscribe("", &user, "digest", digest, unsubscribeURL)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func digestSend(lastCompletion *time.Time, source string, db *sql.DB) (taskResponse, error) {
+	allSettings, err := data.GetEnabledDigestSettings(db)
+	if err != nil {
+		return taskResponse{}, err
+	}
+
+	sent := int64(0)
+	now := time.Now()
+	for _, settings := range allSettings {
+		location, err := time.LoadLocation(settings.Timezone)
+		if err != nil {
+			errorlog.LogError("sending digest", err)
+			continue
+		}
+
+		localNow := now.In(location)
+		day := localNow.Format("2006-01-02")
+		sendAt := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), 0, 0, 0, 0, location).Add(time.Duration(settings.SendTime) * time.Minute)
+		if localNow.Before(sendAt) || localNow.Sub(sendAt) > digestSendWindow {
+			continue
+		}
+
+		weekly := settings.Weekly && localNow.Weekday() == settings.WeeklyDay && settings.LastWeeklySent != day
+		daily := settings.Daily && settings.LastDailySent != day
+		if !weekly && !daily {
+			continue
+		}
+
+		claimed, err := data.ClaimDigest(db, settings.UserID, day, weekly)
+		if err != nil {
+			return taskResponse{}, err
+		}
+		if !claimed {
+			// another run of the task got to it first
+			continue
+		}
+
+		emailSent, err := sendDigest(db, settings, location, localNow, weekly)
+		if err != nil {
+			releaseErr := data.ReleaseDigestClaim(db, settings)
+			if releaseErr != nil {
+				return taskResponse{}, releaseErr
+			}
+
+			if err == email.ErrDisabled {
+				return taskResponse{}, err
+			}
+
+			errorlog.LogError("sending digest", err)
+			continue
+		}
+
+		if emailSent {
+			sent++
+		}
+	}
+
+	return taskResponse{
+		RowsAffected: sent,
+	}, nil
+}
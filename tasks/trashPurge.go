@@ -0,0 +1,43 @@
+package tasks
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+)
+
+// StartTrashPurge begins permanently deleting items that have been in the trash for longer than the retention period.
+func StartTrashPurge(db *sql.DB) error {
+	go taskWatcher("trash_purge", "Trash purge", trashPurge, "", db)
+	return nil
+}
+
+func trashPurge(lastCompletion *time.Time, source string, db *sql.DB) (taskResponse, error) {
+	expiredItems, err := data.GetExpiredTrash(db, time.Now().Add(-data.TrashRetention))
+	if err != nil {
+		return taskResponse{}, err
+	}
+
+	for _, item := range expiredItems {
+		tx, err := db.Begin()
+		if err != nil {
+			return taskResponse{}, err
+		}
+
+		err = data.PurgeTrashItem(tx, item)
+		if err != nil {
+			tx.Rollback()
+			return taskResponse{}, err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return taskResponse{}, err
+		}
+	}
+
+	return taskResponse{
+		RowsAffected: int64(len(expiredItems)),
+	}, nil
+}
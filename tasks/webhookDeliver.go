@@ -0,0 +1,184 @@
+package tasks
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/MyHomeworkSpace/api-server/data"
+)
+
+// how many deliveries are sent each time the task runs, so that one run can't go on forever
+const webhookDeliveryBatchSize = 100
+const webhookDeliveryMaxBatches = 10
+
+const webhookUserAgent = "MyHomeworkSpace-Webhooks/1.0"
+
+var errWebhookAddressNotAllowed = errors.New("tasks: webhook address is not public")
+
+// webhooks can point anywhere, so they can't be allowed to reach things on our own network
+var webhookBlockedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("10.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("127.0.0.0/8"),
+	mustParseCIDR("169.254.0.0/16"),
+	mustParseCIDR("172.16.0.0/12"),
+	mustParseCIDR("192.168.0.0/16"),
+	mustParseCIDR("::1/128"),
+	mustParseCIDR("fc00::/7"),
+	mustParseCIDR("fe80::/10"),
+}
+
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: checkWebhookAddress,
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+	// a redirect could go somewhere that the user didn't register
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// checkWebhookAddress runs after the webhook's host has been resolved, so that a hostname pointing at a private address is caught too.
+func checkWebhookAddress(network string, address string, conn syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsUnspecified() || ip.IsMulticast() {
+		return errWebhookAddressNotAllowed
+	}
+
+	for _, blockedNetwork := range webhookBlockedNetworks {
+		if blockedNetwork.Contains(ip) {
+			return errWebhookAddressNotAllowed
+		}
+	}
+
+	return nil
+}
+
+// StartWebhookDeliver begins sending queued webhook deliveries, including retries of ones that failed before.
+func StartWebhookDeliver(db *sql.DB) error {
+	go taskWatcher("webhook_deliver", "Webhook delivery", webhookDeliver, "", db)
+	return nil
+}
+
+func webhookDeliver(lastCompletion *time.Time, source string, db *sql.DB) (taskResponse, error) {
+	// this is as good a time as any to clean up the log
+	_, err := data.DeleteOldWebhookDeliveries(time.Now().Add(-data.WebhookDeliveryRetention))
+	if err != nil {
+		return taskResponse{}, err
+	}
+
+	delivered := int64(0)
+	webhooks := map[int]data.Webhook{}
+
+	for batch := 0; batch < webhookDeliveryMaxBatches; batch++ {
+		deliveries, err := data.GetDueWebhookDeliveries(time.Now(), webhookDeliveryBatchSize)
+		if err != nil {
+			return taskResponse{}, err
+		}
+
+		for _, delivery := range deliveries {
+			claimed, err := data.ClaimWebhookDelivery(delivery, time.Now())
+			if err != nil {
+				return taskResponse{}, err
+			}
+			if !claimed {
+				// another run of the task got to it first
+				continue
+			}
+
+			webhook, cached := webhooks[delivery.WebhookID]
+			if !cached {
+				webhook, err = data.GetWebhook(delivery.WebhookID)
+				if err == data.ErrNotFound {
+					// the webhook was deleted while this was queued
+					continue
+				} else if err != nil {
+					return taskResponse{}, err
+				}
+				webhooks[delivery.WebhookID] = webhook
+			}
+
+			err = sendWebhookDelivery(webhook, delivery)
+			if err != nil {
+				return taskResponse{}, err
+			}
+
+			delivered++
+		}
+
+		if len(deliveries) < webhookDeliveryBatchSize {
+			break
+		}
+	}
+
+	return taskResponse{
+		RowsAffected: delivered,
+	}, nil
+}
+
+// sendWebhookDelivery makes one attempt at sending the given delivery, and records how it went.
+// Problems with the webhook itself are recorded in the delivery log, and only problems saving that are returned.
+func sendWebhookDelivery(webhook data.Webhook, delivery data.WebhookDelivery) error {
+	// retrying wouldn't help, since the webhook stays disabled until someone turns it back on, and then it should only get new events
+	if !webhook.Enabled {
+		return data.FailWebhookDelivery(delivery, "The webhook is disabled.", time.Now())
+	}
+
+	timestamp := time.Now().Unix()
+
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return data.FinishWebhookDeliveryAttempt(delivery, false, -1, "", err.Error(), time.Now())
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", webhookUserAgent)
+	request.Header.Set("X-MHS-Event", string(delivery.Event))
+	request.Header.Set("X-MHS-Event-ID", delivery.EventID)
+	request.Header.Set("X-MHS-Delivery-ID", strconv.Itoa(delivery.ID))
+	request.Header.Set("X-MHS-Signature", "t="+strconv.FormatInt(timestamp, 10)+",v1="+data.SignWebhookPayload(webhook.Secret, timestamp, delivery.Payload))
+
+	response, err := webhookClient.Do(request)
+	if err != nil {
+		return data.FinishWebhookDeliveryAttempt(delivery, false, -1, "", err.Error(), time.Now())
+	}
+	defer response.Body.Close()
+
+	// only the start of the body is kept, so there's no point reading all of it
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 4096))
+
+	succeeded := (response.StatusCode >= 200 && response.StatusCode < 300)
+	errorText := ""
+	if !succeeded {
+		errorText = "The webhook responded with " + response.Status + "."
+	}
+
+	return data.FinishWebhookDeliveryAttempt(delivery, succeeded, response.StatusCode, string(body), errorText, time.Now())
+}
@@ -0,0 +1,12 @@
+package util
+
+import "strings"
+
+// EscapeLike escapes the wildcards in the given text, so that it can be used in a LIKE pattern and only match itself.
+func EscapeLike(text string) string {
+	// see https://githubengineering.com/like-injection/ for details
+	text = strings.Replace(text, "\\", "\\\\", -1)
+	text = strings.Replace(text, "%", "\\%", -1)
+	text = strings.Replace(text, "_", "\\_", -1)
+	return text
+}